@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	authApp "github.com/mololab/alodb/internal/application/auth"
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	infraAuth "github.com/mololab/alodb/internal/infrastructure/auth"
+	"github.com/mololab/alodb/internal/infrastructure/storage"
+)
+
+func runAPIKeys(command string, args []string) error {
+	switch command {
+	case "create":
+		return createAPIKey(args)
+	case "list":
+		return listAPIKeys()
+	case "revoke":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: alodbctl apikeys revoke ID")
+		}
+		return revokeAPIKey(args[0])
+	default:
+		return fmt.Errorf("unknown apikeys command %q\n\n%s", command, usage)
+	}
+}
+
+func createAPIKey(args []string) error {
+	flags := flag.NewFlagSet("apikeys create", flag.ContinueOnError)
+	name := flags.String("name", "", "name describing the key")
+	scopes := flags.String("scopes", "", "comma separated scopes: chat, execute, admin")
+	user := flags.String("user", "", "user the key acts as, defaults to the anonymous user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*name) == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	req := domainAuth.CreateAPIKeyRequest{
+		Name:   strings.TrimSpace(*name),
+		UserID: strings.TrimSpace(*user),
+	}
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			req.Scopes = append(req.Scopes, domainAuth.Scope(scope))
+		}
+	}
+
+	service, store, err := openAuthService()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key, apiKey, err := service.CreateAPIKey(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "created API key %s for user %s, it is shown only once:\n", apiKey.ID, apiKey.UserID)
+	fmt.Println(key)
+	return nil
+}
+
+func listAPIKeys() error {
+	service, store, err := openAuthService()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	apiKeys, err := service.ListAPIKeys(context.Background())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tUSER\tSCOPES\tCREATED\tLAST USED\tSTATUS")
+	for _, k := range apiKeys {
+		scopes := make([]string, len(k.Scopes))
+		for i, scope := range k.Scopes {
+			scopes[i] = string(scope)
+		}
+		status := "active"
+		if k.RevokedAt != nil {
+			status = "revoked"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Name, k.Prefix, k.UserID, strings.Join(scopes, ","),
+			k.CreatedAt.Format(time.RFC3339), formatTime(k.LastUsedAt), status)
+	}
+	return w.Flush()
+}
+
+func revokeAPIKey(id string) error {
+	service, store, err := openAuthService()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := service.RevokeAPIKey(context.Background(), id); err != nil {
+		return err
+	}
+
+	fmt.Printf("revoked API key %s\n", id)
+	return nil
+}
+
+// openAuthService opens the configured storage, API keys cannot live in memory
+func openAuthService() (*authApp.Service, *storage.Store, error) {
+	_, store, err := openStore()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if store.Driver() == storage.DriverMemory {
+		store.Close()
+		return nil, nil, fmt.Errorf("API keys need persistent storage, set STORAGE_DRIVER")
+	}
+
+	repository, err := infraAuth.NewAPIKeyRepository(store.DB())
+	if err != nil {
+		store.Close()
+		return nil, nil, err
+	}
+
+	return authApp.NewService(repository, authApp.Config{}), store, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
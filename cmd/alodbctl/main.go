@@ -0,0 +1,62 @@
+// Command alodbctl performs administrative tasks against the AloDB configuration and storage.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mololab/alodb/internal/infrastructure/config"
+	"github.com/mololab/alodb/internal/infrastructure/storage"
+)
+
+const usage = `Usage: alodbctl <command> [arguments]
+
+Commands:
+  secrets generate-key   Print a new random master key
+  secrets encrypt        Encrypt a value read from stdin for use in app.env
+  secrets rotate         Re-encrypt stored secrets with the current master key
+  apikeys create         Create an API key: --name NAME --scopes chat,execute,admin [--user USER]
+  apikeys list           List API keys
+  apikeys revoke ID      Revoke an API key
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "secrets":
+		return runSecrets(args[1])
+	case "apikeys":
+		return runAPIKeys(args[1], args[2:])
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
+
+// openStore loads the configuration and opens the configured storage
+func openStore() (config.Config, *storage.Store, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.Config{}, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	store, err := storage.Open(storage.Config{
+		Driver: cfg.Storage.Driver,
+		DSN:    cfg.Storage.DSN,
+	})
+	if err != nil {
+		return config.Config{}, nil, err
+	}
+
+	return cfg, store, nil
+}
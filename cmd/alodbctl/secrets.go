@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mololab/alodb/internal/infrastructure/config"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
+	"github.com/mololab/alodb/internal/infrastructure/storage"
+)
+
+func runSecrets(command string) error {
+	switch command {
+	case "generate-key":
+		return generateKey()
+	case "encrypt":
+		return encryptValue()
+	case "rotate":
+		return rotateSecrets()
+	default:
+		return fmt.Errorf("unknown secrets command %q\n\n%s", command, usage)
+	}
+}
+
+func generateKey() error {
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(base64.StdEncoding.EncodeToString(key))
+	return nil
+}
+
+// encryptValue encrypts stdin with the current master key
+func encryptValue() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	keyring, err := secrets.LoadKeyring(cfg.Secrets.Keys())
+	if err != nil {
+		return err
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read value: %w", err)
+	}
+
+	value := strings.TrimRight(string(input), "\r\n")
+	if value == "" {
+		return fmt.Errorf("no value on stdin")
+	}
+
+	encrypted, err := secrets.NewCipher(keyring).EncryptValue(context.Background(), value)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(encrypted)
+	return nil
+}
+
+// rotateSecrets rewraps stored secrets that use a previous master key
+func rotateSecrets() error {
+	cfg, store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if store.Driver() == storage.DriverMemory {
+		return fmt.Errorf("nothing to rotate with in-memory storage")
+	}
+
+	keyring, err := secrets.LoadKeyring(cfg.Secrets.Keys())
+	if err != nil {
+		return err
+	}
+
+	connections, err := infraConnection.NewRepository(store.DB(), secrets.NewCipher(keyring))
+	if err != nil {
+		return err
+	}
+
+	rotated, err := connections.RotateKeys(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("rotated %d connection profiles to key %s\n", rotated, keyring.CurrentKeyID())
+	return nil
+}
@@ -20,7 +20,10 @@ func main() {
 
 	logger.Init(cfg.Server.Env == "development")
 
-	server := web.NewServer(&cfg)
+	server, err := web.NewServer(&cfg)
+	if err != nil {
+		panic("Failed to create server: " + err.Error())
+	}
 	logger.Info().Str("port", cfg.Server.Port).Msg("server starting")
 
 	go func() {
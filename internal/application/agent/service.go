@@ -5,26 +5,110 @@ import (
 	"fmt"
 
 	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	domainConnection "github.com/mololab/alodb/internal/domain/connection"
+	domainSession "github.com/mololab/alodb/internal/domain/session"
 	infraAgent "github.com/mololab/alodb/internal/infrastructure/agent"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/ratelimit"
 	"github.com/mololab/alodb/pkg/logger"
+
+	"google.golang.org/adk/session"
 )
 
 type Service struct {
-	config  domainAgent.AgentConfig
-	manager *infraAgent.Manager
+	config      domainAgent.AgentConfig
+	manager     *infraAgent.Manager
+	connections domainConnection.Resolver
+	limiter     *ratelimit.Limiter
+	policy      *infraConnection.Policy
 }
 
-func NewService(config domainAgent.AgentConfig) *Service {
+// NewService creates the agent service. The limiter, when set, records the
+// LLM tokens used by each chat against the caller's daily quota. The policy
+// restricts the databases the agent connects to.
+func NewService(config domainAgent.AgentConfig, sessionService session.Service, connections domainConnection.Resolver, limiter *ratelimit.Limiter, policy *infraConnection.Policy) *Service {
 	return &Service{
-		config:  config,
-		manager: infraAgent.NewManager(config.Providers, config.SchemaCacheTTL),
+		config:      config,
+		manager:     infraAgent.NewManager(config, sessionService, policy),
+		connections: connections,
+		limiter:     limiter,
+		policy:      policy,
 	}
 }
 
 func (s *Service) Chat(ctx context.Context, req domainAgent.ChatRequest) (*domainAgent.ChatResponse, error) {
+	usage := &domainAgent.TokenUsage{}
+	ctx = domainAgent.WithUsage(ctx, usage)
+	defer s.recordUsage(ctx, usage)
+
+	req.UserID = userOrDefault(req.UserID)
+	if err := s.resolveConnection(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	agent, err := s.agentFor(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return agent.Chat(ctx, req)
+}
+
+// ChatStream processes a chat request, reporting progress to emit while the agent runs
+func (s *Service) ChatStream(ctx context.Context, req domainAgent.ChatRequest, emit func(domainAgent.StreamEvent)) (*domainAgent.ChatResponse, error) {
+	usage := &domainAgent.TokenUsage{}
+	ctx = domainAgent.WithUsage(ctx, usage)
+	defer s.recordUsage(ctx, usage)
+
+	req.UserID = userOrDefault(req.UserID)
+	if err := s.resolveConnection(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	agent, err := s.agentFor(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return agent.ChatStream(ctx, req, emit)
+}
+
+// recordUsage counts the tokens a chat used, failed chats included, against the caller's quota
+func (s *Service) recordUsage(ctx context.Context, usage *domainAgent.TokenUsage) {
+	caller, ok := ratelimit.CallerFromContext(ctx)
+	if s.limiter == nil || !ok {
+		return
+	}
+
+	// the client may be gone, the tokens were used anyway
+	if err := s.limiter.RecordUsage(context.WithoutCancel(ctx), caller, usage.TotalTokens); err != nil {
+		logger.Error().Err(err).Msg("failed to record token usage")
+	}
+}
+
+// resolveConnection replaces a saved connection profile with its connection
+// string and checks the target against the connection policy before the agent
+// runs, the tools enforce the policy again when they connect
+func (s *Service) resolveConnection(ctx context.Context, req *domainAgent.ChatRequest) error {
+	if req.ConnectionID != "" {
+		connStr, err := s.connections.ConnectionString(ctx, req.UserID, req.ConnectionID)
+		if err != nil {
+			return err
+		}
+		req.ConnectionString = connStr
+	}
+
+	if req.ConnectionString == "" {
+		return nil
+	}
+	return s.policy.Check(ctx, req.ConnectionString)
+}
+
+// agentFor returns the agent of the requested model, or the default model
+func (s *Service) agentFor(ctx context.Context, req domainAgent.ChatRequest) (*infraAgent.DBAgent, error) {
 	modelSlug := req.Model
 	if modelSlug == "" {
-		modelSlug = domainAgent.GetDefaultModelSlug()
+		modelSlug = s.manager.DefaultModelSlug()
 	}
 
 	agent, err := s.manager.GetAgent(ctx, modelSlug)
@@ -38,16 +122,41 @@ func (s *Service) Chat(ctx context.Context, req domainAgent.ChatRequest) (*domai
 		Str("model", modelSlug).
 		Msg("processing chat request")
 
-	return agent.Chat(ctx, req)
+	return agent, nil
 }
 
 func (s *Service) GetAvailableModels() []domainAgent.Model {
 	return s.manager.GetAvailableModels()
 }
 
+func (s *Service) ListSessions(ctx context.Context, userID string) ([]domainSession.Summary, error) {
+	return s.manager.ListSessions(ctx, userOrDefault(userID))
+}
+
+func (s *Service) GetSession(ctx context.Context, userID, sessionID string) (*domainSession.Session, error) {
+	return s.manager.GetSession(ctx, userOrDefault(userID), sessionID)
+}
+
+func (s *Service) RenameSession(ctx context.Context, userID, sessionID, title string) (*domainSession.Summary, error) {
+	return s.manager.RenameSession(ctx, userOrDefault(userID), sessionID, title)
+}
+
+func (s *Service) DeleteSession(ctx context.Context, userID, sessionID string) error {
+	logger.Debug().Str("session_id", sessionID).Msg("deleting session")
+	return s.manager.DeleteSession(ctx, userOrDefault(userID), sessionID)
+}
+
 func (s *Service) Close() error {
 	if s.manager != nil {
 		return s.manager.Close()
 	}
 	return nil
 }
+
+// userOrDefault scopes requests without an authenticated user to the default user
+func userOrDefault(userID string) string {
+	if userID == "" {
+		return domainAgent.DefaultUserID
+	}
+	return userID
+}
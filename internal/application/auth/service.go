@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	infraAuth "github.com/mololab/alodb/internal/infrastructure/auth"
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+// Config selects the credentials accepted by Authenticate
+type Config struct {
+	APIKeys bool                      // accept API keys
+	Tokens  *infraAuth.TokenValidator // accept JWTs when set
+}
+
+type Service struct {
+	apiKeys *infraAuth.APIKeyRepository
+	config  Config
+}
+
+func NewService(apiKeys *infraAuth.APIKeyRepository, config Config) *Service {
+	return &Service{
+		apiKeys: apiKeys,
+		config:  config,
+	}
+}
+
+// CreateAPIKey issues a key, it is returned only once
+func (s *Service) CreateAPIKey(ctx context.Context, req domainAuth.CreateAPIKeyRequest) (string, *domainAuth.APIKey, error) {
+	if req.UserID == "" {
+		req.UserID = domainAgent.DefaultUserID
+	}
+
+	key, apiKey, err := s.apiKeys.Create(ctx, req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	logger.Info().Str("key_id", apiKey.ID).Str("user_id", apiKey.UserID).Msg("API key created")
+	return key, apiKey, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context) ([]domainAuth.APIKey, error) {
+	return s.apiKeys.List(ctx)
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, id string) error {
+	if err := s.apiKeys.Revoke(ctx, id); err != nil {
+		return err
+	}
+
+	logger.Info().Str("key_id", id).Msg("API key revoked")
+	return nil
+}
+
+// Authenticate resolves an API key or a JWT to its principal. API keys are
+// recognized by their prefix, anything else is validated as a JWT.
+func (s *Service) Authenticate(ctx context.Context, credential string) (*domainAuth.Principal, error) {
+	if strings.HasPrefix(credential, infraAuth.KeyPrefix) {
+		if !s.config.APIKeys {
+			return nil, infraAuth.ErrInvalidAPIKey
+		}
+		return s.apiKeys.Authenticate(ctx, credential)
+	}
+
+	if s.config.Tokens == nil {
+		return nil, infraAuth.ErrInvalidToken
+	}
+	return s.config.Tokens.Authenticate(ctx, credential)
+}
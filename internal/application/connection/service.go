@@ -0,0 +1,78 @@
+package connection
+
+import (
+	"context"
+
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	domainConnection "github.com/mololab/alodb/internal/domain/connection"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+type Service struct {
+	repository *infraConnection.Repository
+	policy     *infraConnection.Policy
+}
+
+func NewService(repository *infraConnection.Repository, policy *infraConnection.Policy) *Service {
+	return &Service{
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+// Create tests the connection and saves the profile when it succeeds
+func (s *Service) Create(ctx context.Context, req domainConnection.CreateRequest) (*domainConnection.Profile, error) {
+	req.UserID = userOrDefault(req.UserID)
+	if req.Port == 0 {
+		req.Port = domainConnection.DefaultPort
+	}
+	if req.SSLMode == "" {
+		req.SSLMode = domainConnection.DefaultSSLMode
+	}
+
+	profile := &domainConnection.Profile{
+		Host:     req.Host,
+		Port:     req.Port,
+		Database: req.Database,
+		User:     req.User,
+		SSLMode:  req.SSLMode,
+	}
+	if err := infraConnection.TestConnection(ctx, s.policy, infraConnection.BuildConnectionString(profile, req.Password)); err != nil {
+		logger.Debug().Err(err).Str("host", req.Host).Msg("connection test failed")
+		return nil, err
+	}
+
+	created, err := s.repository.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info().Str("connection_id", created.ID).Msg("connection profile created")
+	return created, nil
+}
+
+func (s *Service) List(ctx context.Context, userID string) ([]domainConnection.Profile, error) {
+	return s.repository.List(ctx, userOrDefault(userID))
+}
+
+func (s *Service) Get(ctx context.Context, userID, id string) (*domainConnection.Profile, error) {
+	return s.repository.Get(ctx, userOrDefault(userID), id)
+}
+
+func (s *Service) Delete(ctx context.Context, userID, id string) error {
+	return s.repository.Delete(ctx, userOrDefault(userID), id)
+}
+
+// ConnectionString resolves a saved profile to a connection string, it never leaves the server
+func (s *Service) ConnectionString(ctx context.Context, userID, id string) (string, error) {
+	return s.repository.ConnectionString(ctx, userOrDefault(userID), id)
+}
+
+// userOrDefault scopes requests without an authenticated user to the default user
+func userOrDefault(userID string) string {
+	if userID == "" {
+		return domainAgent.DefaultUserID
+	}
+	return userID
+}
@@ -0,0 +1,52 @@
+package query
+
+import (
+	"context"
+
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	domainConnection "github.com/mololab/alodb/internal/domain/connection"
+	domainQuery "github.com/mololab/alodb/internal/domain/query"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	infraQuery "github.com/mololab/alodb/internal/infrastructure/query"
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+type Service struct {
+	executor    *infraQuery.Executor
+	connections domainConnection.Resolver
+}
+
+func NewService(config infraQuery.Config, connections domainConnection.Resolver, policy *infraConnection.Policy) *Service {
+	return &Service{
+		executor:    infraQuery.NewExecutor(config, policy),
+		connections: connections,
+	}
+}
+
+func (s *Service) Execute(ctx context.Context, req domainQuery.ExecuteRequest) (*domainQuery.ResultPage, error) {
+	logger.Debug().
+		Bool("has_cursor", req.Cursor != "").
+		Int("page_size", req.PageSize).
+		Msg("processing execute request")
+
+	if req.UserID == "" {
+		req.UserID = domainAgent.DefaultUserID
+	}
+
+	if req.ConnectionID != "" && req.Cursor == "" {
+		connStr, err := s.connections.ConnectionString(ctx, req.UserID, req.ConnectionID)
+		if err != nil {
+			return nil, err
+		}
+		req.ConnectionString = connStr
+	}
+
+	return s.executor.Execute(ctx, req)
+}
+
+func (s *Service) Close() error {
+	if s.executor != nil {
+		return s.executor.Close()
+	}
+	return nil
+}
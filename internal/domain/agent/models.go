@@ -3,8 +3,11 @@ package agent
 type Provider string
 
 const (
-	ProviderGoogle Provider = "google"
-	ProviderOpenAI Provider = "openai"
+	ProviderGoogle           Provider = "google"
+	ProviderOpenAI           Provider = "openai"
+	ProviderOpenAICompatible Provider = "openai_compatible"
+	ProviderAnthropic        Provider = "anthropic"
+	ProviderFake             Provider = "fake"
 )
 
 type Model struct {
@@ -22,11 +25,35 @@ var GoogleModels = []Model{
 	{Slug: "gemini-2.5-pro", Name: "Gemini 2.5 Pro", Provider: ProviderGoogle},
 }
 
-var OpenAIModels = []Model{}
+var OpenAIModels = []Model{
+	{Slug: "gpt-4.1", Name: "GPT-4.1", Provider: ProviderOpenAI},
+	{Slug: "gpt-4.1-mini", Name: "GPT-4.1 Mini", Provider: ProviderOpenAI},
+	{Slug: "gpt-4.1-nano", Name: "GPT-4.1 Nano", Provider: ProviderOpenAI},
+	{Slug: "gpt-4o", Name: "GPT-4o", Provider: ProviderOpenAI},
+	{Slug: "gpt-4o-mini", Name: "GPT-4o Mini", Provider: ProviderOpenAI},
+}
+
+var AnthropicModels = []Model{
+	{Slug: "claude-sonnet-4-5", Name: "Claude Sonnet 4.5", Provider: ProviderAnthropic},
+	{Slug: "claude-opus-4-1", Name: "Claude Opus 4.1", Provider: ProviderAnthropic},
+	{Slug: "claude-haiku-4-5", Name: "Claude Haiku 4.5", Provider: ProviderAnthropic},
+	{Slug: "claude-sonnet-4-0", Name: "Claude Sonnet 4", Provider: ProviderAnthropic},
+}
+
+// FakeModels replay a scripted conversation, used for offline testing
+var FakeModels = []Model{
+	{Slug: "fake", Name: "Fake (scripted)", Provider: ProviderFake},
+}
 
 type ProviderConfig struct {
 	EnvKey string
 	Models []Model
+	// BaseURLEnvKey and ModelsEnvKey are set for self-hosted providers whose
+	// endpoint and model names come from configuration instead of Models
+	BaseURLEnvKey string
+	ModelsEnvKey  string
+	// ScriptEnvKey is set for the fake provider, which is enabled by a script path
+	ScriptEnvKey string
 }
 
 var ProviderRegistry = map[Provider]ProviderConfig{
@@ -38,6 +65,29 @@ var ProviderRegistry = map[Provider]ProviderConfig{
 		EnvKey: "OPENAI_API_KEY",
 		Models: OpenAIModels,
 	},
+	ProviderAnthropic: {
+		EnvKey: "ANTHROPIC_API_KEY",
+		Models: AnthropicModels,
+	},
+	ProviderOpenAICompatible: {
+		EnvKey:        "OPENAI_COMPATIBLE_API_KEY",
+		BaseURLEnvKey: "OPENAI_COMPATIBLE_BASE_URL",
+		ModelsEnvKey:  "OPENAI_COMPATIBLE_MODELS",
+	},
+	ProviderFake: {
+		ScriptEnvKey: "FAKE_LLM_SCRIPT",
+		Models:       FakeModels,
+	},
+}
+
+// IsConfigurable reports whether the provider's endpoint and models come from configuration
+func (c ProviderConfig) IsConfigurable() bool {
+	return c.BaseURLEnvKey != ""
+}
+
+// RequiresAPIKey reports whether the provider cannot be used without an API key
+func (c ProviderConfig) RequiresAPIKey() bool {
+	return !c.IsConfigurable() && c.ScriptEnvKey == ""
 }
 
 func GetDefaultModelSlug() string {
@@ -1,27 +1,106 @@
 package agent
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/mololab/alodb/internal/domain/database"
+)
+
+// DefaultUserID owns the sessions of requests without an authenticated user
+const DefaultUserID = "anonymous"
 
 type ChatRequest struct {
+	UserID           string
 	SessionID        string
 	Message          string
 	ConnectionString string
+	ConnectionID     string // saved profile, resolved to ConnectionString server-side
 	Model            string
+	SchemaFilter     database.SchemaFilter // schemas read_schema extracts
 }
 
 type Query struct {
-	Title       string `json:"title"`
-	Query       string `json:"query"`
-	Description string `json:"description"`
+	Title       string              `json:"title"`
+	Query       string              `json:"query"`
+	Description string              `json:"description"`
+	IsReadOnly  bool                `json:"is_read_only"`
+	Risk        *database.QueryRisk `json:"risk,omitempty"`
 }
 
 type ChatResponse struct {
-	SessionID string  `json:"session_id"`
-	Message   string  `json:"message"`
-	Queries   []Query `json:"queries,omitempty"`
+	SessionID string     `json:"session_id"`
+	Message   string     `json:"message"`
+	Queries   []Query    `json:"queries,omitempty"`
+	Usage     TokenUsage `json:"usage"`
+}
+
+// TokenUsage counts the LLM tokens used while processing a chat
+type TokenUsage struct {
+	PromptTokens int64 `json:"prompt_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+// Add counts the tokens of one model call
+func (u *TokenUsage) Add(promptTokens, outputTokens, totalTokens int64) {
+	if totalTokens == 0 {
+		totalTokens = promptTokens + outputTokens
+	}
+	u.PromptTokens += promptTokens
+	u.OutputTokens += outputTokens
+	u.TotalTokens += totalTokens
+}
+
+type usageKey struct{}
+
+// WithUsage returns a context in which the agent adds the tokens it uses to
+// usage, even when the chat fails
+func WithUsage(ctx context.Context, usage *TokenUsage) context.Context {
+	return context.WithValue(ctx, usageKey{}, usage)
+}
+
+// UsageFromContext returns the usage counter of the context, if any
+func UsageFromContext(ctx context.Context) *TokenUsage {
+	usage, _ := ctx.Value(usageKey{}).(*TokenUsage)
+	return usage
+}
+
+// StreamEventType identifies the progress reported while the agent runs
+type StreamEventType string
+
+const (
+	StreamEventToolCall   StreamEventType = "tool_call"
+	StreamEventToolResult StreamEventType = "tool_result"
+	StreamEventText       StreamEventType = "text"
+)
+
+// StreamEvent is a progress update of a streamed chat
+type StreamEvent struct {
+	Type    StreamEventType
+	Tool    string         // tool_call, tool_result: tool name
+	Args    map[string]any // tool_call: arguments chosen by the model
+	Status  string         // tool_result: status reported by the tool
+	Message string         // tool_result: message reported by the tool
+	Text    string         // text: model output chunk
+}
+
+// ProviderSettings is the runtime configuration of an enabled provider
+type ProviderSettings struct {
+	APIKey  string
+	BaseURL string
+	Models  []Model // configured models, replaces the registry models when set
+	// ScriptPath is the response script replayed by the fake provider
+	ScriptPath string
 }
 
 type AgentConfig struct {
 	SchemaCacheTTL time.Duration
-	Providers      map[Provider]string // Provider -> API Key
+	QueryMaxRows   int
+	QueryTimeout   time.Duration
+	// SchemaConnectTimeout, SchemaStatementTimeout and SchemaTimeout bound read_schema
+	SchemaConnectTimeout   time.Duration
+	SchemaStatementTimeout time.Duration
+	SchemaTimeout          time.Duration
+	Providers              map[Provider]ProviderSettings
 }
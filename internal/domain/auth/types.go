@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Scope grants access to a group of API routes
+type Scope string
+
+const (
+	ScopeChat    Scope = "chat"    // agent chat, sessions and saved connections
+	ScopeExecute Scope = "execute" // direct query execution
+	ScopeAdmin   Scope = "admin"   // API key management, implies every other scope
+)
+
+// AllScopes lists the known scopes
+var AllScopes = []Scope{ScopeChat, ScopeExecute, ScopeAdmin}
+
+// IsValidScope reports whether the scope is known
+func IsValidScope(scope Scope) bool {
+	return slices.Contains(AllScopes, scope)
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID string
+	// KeyID is set when the caller authenticated with an API key
+	KeyID  string
+	Scopes []Scope
+}
+
+// HasScope reports whether the principal was granted the scope
+func (p *Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// APIKey describes a stored API key. The key itself is only shown once, on creation.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	UserID     string     `json:"user_id"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest is a request to issue an API key
+type CreateAPIKeyRequest struct {
+	Name   string
+	UserID string
+	Scopes []Scope
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated principal, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
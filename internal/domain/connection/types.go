@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"context"
+	"time"
+)
+
+// SSL modes supported by the PostgreSQL driver
+const (
+	SSLModeDisable    = "disable"
+	SSLModeRequire    = "require"
+	SSLModeVerifyCA   = "verify-ca"
+	SSLModeVerifyFull = "verify-full"
+
+	DefaultPort    = 5432
+	DefaultSSLMode = SSLModeRequire
+)
+
+// Profile is a saved database connection. The password is stored encrypted
+// and never returned.
+type Profile struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	Database  string    `json:"database"`
+	User      string    `json:"user"`
+	SSLMode   string    `json:"sslmode"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateRequest is a request to save a connection profile
+type CreateRequest struct {
+	UserID   string
+	Name     string
+	Host     string
+	Port     int
+	Database string
+	User     string
+	Password string
+	SSLMode  string
+}
+
+// Resolver resolves a saved profile of a user to its connection string
+type Resolver interface {
+	ConnectionString(ctx context.Context, userID, id string) (string, error)
+}
+
+// IsValidSSLMode reports whether the PostgreSQL driver supports the SSL mode
+func IsValidSSLMode(mode string) bool {
+	switch mode {
+	case SSLModeDisable, SSLModeRequire, SSLModeVerifyCA, SSLModeVerifyFull:
+		return true
+	default:
+		return false
+	}
+}
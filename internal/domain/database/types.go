@@ -1,12 +1,36 @@
 package database
 
-// TableSchema represents a database table structure
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// RelationKind is the kind of relation a TableSchema describes
+type RelationKind string
+
+const (
+	RelationTable            RelationKind = "table"
+	RelationPartitionedTable RelationKind = "partitioned_table"
+	RelationView             RelationKind = "view"
+	RelationMaterializedView RelationKind = "materialized_view"
+	RelationForeignTable     RelationKind = "foreign_table"
+)
+
+// TableSchema represents a database table, view or other queryable relation
 type TableSchema struct {
-	Name        string         `json:"name"`
-	Columns     []ColumnSchema `json:"columns"`
-	PrimaryKey  []string       `json:"primary_key,omitempty"`
-	ForeignKeys []ForeignKey   `json:"foreign_keys,omitempty"`
-	Indexes     []IndexSchema  `json:"indexes,omitempty"`
+	Schema        string             `json:"schema"`
+	Name          string             `json:"name"`
+	QualifiedName string             `json:"qualified_name"` // quoted schema.table to use in queries
+	Kind          RelationKind       `json:"kind"`
+	Definition    string             `json:"definition,omitempty"` // SELECT of a view or materialized view
+	Columns       []ColumnSchema     `json:"columns"`
+	PrimaryKey    []string           `json:"primary_key,omitempty"`
+	ForeignKeys   []ForeignKey       `json:"foreign_keys,omitempty"`
+	Indexes       []IndexSchema      `json:"indexes,omitempty"`
+	Checks        []CheckConstraint  `json:"check_constraints,omitempty"`
+	Uniques       []UniqueConstraint `json:"unique_constraints,omitempty"`
 }
 
 // ColumnSchema represents a column in a table
@@ -16,27 +40,157 @@ type ColumnSchema struct {
 	IsNullable bool   `json:"is_nullable"`
 	Default    string `json:"default,omitempty"`
 	Comment    string `json:"comment,omitempty"`
+
+	UDTSchema         string   `json:"udt_schema,omitempty"` // empty for built-in types
+	UDTName           string   `json:"udt_name"`
+	TypeKind          TypeKind `json:"type_kind"`
+	ElementType       string   `json:"element_type,omitempty"` // arrays
+	MaxLength         *int     `json:"max_length,omitempty"`   // varchar, char and bit lengths
+	NumericPrecision  *int     `json:"numeric_precision,omitempty"`
+	NumericScale      *int     `json:"numeric_scale,omitempty"`
+	EnumLabels        []string `json:"enum_labels,omitempty"` // of an enum, an enum array or a domain over an enum
+	DomainBaseType    string   `json:"domain_base_type,omitempty"`
+	DomainConstraints []string `json:"domain_constraints,omitempty"`
+}
+
+// TypeKind is the kind of a PostgreSQL data type
+type TypeKind string
+
+const (
+	TypeKindBase       TypeKind = "base"
+	TypeKindArray      TypeKind = "array"
+	TypeKindEnum       TypeKind = "enum"
+	TypeKindDomain     TypeKind = "domain"
+	TypeKindComposite  TypeKind = "composite"
+	TypeKindRange      TypeKind = "range"
+	TypeKindMultirange TypeKind = "multirange"
+	TypeKindPseudo     TypeKind = "pseudo"
+)
+
+// TypeSchema describes a user-defined enum, domain, composite or range type
+type TypeSchema struct {
+	Schema        string          `json:"schema"`
+	Name          string          `json:"name"`
+	QualifiedName string          `json:"qualified_name"`
+	Kind          TypeKind        `json:"kind"`
+	Labels        []string        `json:"labels,omitempty"`      // enum labels in sort order
+	BaseType      string          `json:"base_type,omitempty"`   // domain base type or range subtype
+	NotNull       bool            `json:"not_null,omitempty"`    // domain
+	Default       string          `json:"default,omitempty"`     // domain
+	Constraints   []string        `json:"constraints,omitempty"` // domain CHECK constraints
+	Attributes    []TypeAttribute `json:"attributes,omitempty"`  // composite fields
+	Comment       string          `json:"comment,omitempty"`
+}
+
+// TypeAttribute is a field of a composite type
+type TypeAttribute struct {
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
 }
 
 // ForeignKey represents a foreign key relationship
 type ForeignKey struct {
-	Name             string   `json:"name"`
-	Columns          []string `json:"columns"`
-	ReferencedTable  string   `json:"referenced_table"`
-	ReferencedColumn []string `json:"referenced_columns"`
+	Name              string            `json:"name"`
+	Columns           []string          `json:"columns"`
+	ReferencedSchema  string            `json:"referenced_schema"`
+	ReferencedTable   string            `json:"referenced_table"`
+	ReferencedColumn  []string          `json:"referenced_columns"`
+	OnDelete          ReferentialAction `json:"on_delete"`
+	OnUpdate          ReferentialAction `json:"on_update"`
+	Deferrable        bool              `json:"deferrable,omitempty"`
+	InitiallyDeferred bool              `json:"initially_deferred,omitempty"`
+}
+
+// ReferentialAction is what a foreign key does when the referenced row is deleted or updated
+type ReferentialAction string
+
+const (
+	ActionNoAction   ReferentialAction = "NO ACTION"
+	ActionRestrict   ReferentialAction = "RESTRICT"
+	ActionCascade    ReferentialAction = "CASCADE"
+	ActionSetNull    ReferentialAction = "SET NULL"
+	ActionSetDefault ReferentialAction = "SET DEFAULT"
+)
+
+// CheckConstraint represents a CHECK constraint of a table
+type CheckConstraint struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns,omitempty"`
+	Definition string   `json:"definition"`          // e.g. CHECK ((price > 0))
+	NotValid   bool     `json:"not_valid,omitempty"` // existing rows were not checked
+}
+
+// UniqueConstraint represents a UNIQUE constraint of a table
+type UniqueConstraint struct {
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns"`
+	Deferrable        bool     `json:"deferrable,omitempty"`
+	InitiallyDeferred bool     `json:"initially_deferred,omitempty"`
 }
 
 // IndexSchema represents an index on a table
 type IndexSchema struct {
-	Name     string   `json:"name"`
-	Columns  []string `json:"columns"`
-	IsUnique bool     `json:"is_unique"`
+	Name        string   `json:"name"`
+	Columns     []string `json:"columns"` // key columns in order, an expression where the key is one
+	IsUnique    bool     `json:"is_unique"`
+	Method      string   `json:"method"`                // btree, hash, gin, gist, spgist or brin
+	Expressions []string `json:"expressions,omitempty"` // key expressions of an expression index
+	Include     []string `json:"include,omitempty"`     // INCLUDE columns, stored but not searchable
+	Predicate   string   `json:"predicate,omitempty"`   // WHERE clause of a partial index
+	IsValid     bool     `json:"is_valid"`              // false while being built or after a failed CREATE INDEX CONCURRENTLY
+	Definition  string   `json:"definition"`            // CREATE INDEX statement
 }
 
 // DatabaseSchema represents the complete database schema
 type DatabaseSchema struct {
 	DatabaseName string        `json:"database_name"`
+	SearchPath   []string      `json:"search_path,omitempty"` // schemas unqualified names resolve to, in order
+	Schemas      []string      `json:"schemas"`               // extracted schemas, those on the search path first
 	Tables       []TableSchema `json:"tables"`
+	Types        []TypeSchema  `json:"types,omitempty"` // user-defined types of the schemas and their columns
+}
+
+// SchemaFilter selects the schemas read from a database. Patterns match whole
+// schema names, "*" matches any characters and "?" a single one. Without
+// include patterns every schema is read. Exclusions win over inclusions.
+type SchemaFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Validate checks the syntax of the patterns
+func (f SchemaFilter) Validate() error {
+	for _, pattern := range slices.Concat(f.Include, f.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid schema pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the filter selects the schema
+func (f SchemaFilter) Matches(schema string) bool {
+	if matchAny(f.Exclude, schema) {
+		return false
+	}
+	return len(f.Include) == 0 || matchAny(f.Include, schema)
+}
+
+// Key identifies the filter, equal filters have equal keys regardless of pattern order
+func (f SchemaFilter) Key() string {
+	include := slices.Sorted(slices.Values(f.Include))
+	exclude := slices.Sorted(slices.Values(f.Exclude))
+	return "include=" + strings.Join(slices.Compact(include), ",") +
+		";exclude=" + strings.Join(slices.Compact(exclude), ",")
+}
+
+func matchAny(patterns []string, schema string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, schema); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // QueryResult represents the result of a query generation
@@ -45,3 +199,41 @@ type QueryResult struct {
 	Explanation string `json:"explanation"`
 	IsReadOnly  bool   `json:"is_read_only"`
 }
+
+// QueryKind classifies what a SQL text does
+type QueryKind string
+
+const (
+	QueryKindReadOnly       QueryKind = "read_only"
+	QueryKindDML            QueryKind = "dml"
+	QueryKindDDL            QueryKind = "ddl"
+	QueryKindMultiStatement QueryKind = "multi_statement"
+	// QueryKindOther covers transaction control, settings, maintenance and procedure calls
+	QueryKindOther   QueryKind = "other"
+	QueryKindUnknown QueryKind = "unknown"
+)
+
+// RiskLevel rates how much damage running a query could do
+type RiskLevel string
+
+const (
+	RiskNone   RiskLevel = "none"
+	RiskLow    RiskLevel = "low"
+	RiskMedium RiskLevel = "medium"
+	RiskHigh   RiskLevel = "high"
+)
+
+// RiskFlag is a dangerous pattern found in a query
+type RiskFlag struct {
+	Code     string    `json:"code"`
+	Severity RiskLevel `json:"severity"`
+	Message  string    `json:"message"`
+}
+
+// QueryRisk is the static analysis of a generated query
+type QueryRisk struct {
+	Kind       QueryKind  `json:"kind"`
+	Level      RiskLevel  `json:"level"`
+	Statements int        `json:"statements"`
+	Flags      []RiskFlag `json:"flags,omitempty"`
+}
@@ -0,0 +1,26 @@
+package query
+
+// ExecuteRequest is a request to run a query or continue reading an open cursor
+type ExecuteRequest struct {
+	UserID           string
+	ConnectionString string
+	ConnectionID     string // saved profile, resolved to ConnectionString server-side
+	Query            string
+	Cursor           string
+	PageSize         int
+}
+
+// Column describes a column in a query result
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ResultPage is one page of rows read from a query
+type ResultPage struct {
+	Columns    []Column `json:"columns"`
+	Rows       [][]any  `json:"rows"`
+	RowCount   int      `json:"row_count"`
+	HasMore    bool     `json:"has_more"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
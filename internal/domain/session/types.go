@@ -0,0 +1,36 @@
+package session
+
+import (
+	"time"
+
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+)
+
+// Message roles in a session history
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Summary describes a stored conversation
+type Summary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Message is a single user message or final assistant reply
+type Message struct {
+	Role      string              `json:"role"`
+	Content   string              `json:"content"`
+	Queries   []domainAgent.Query `json:"queries,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// Session is a conversation with its message history
+type Session struct {
+	Summary
+	Messages []Message `json:"messages"`
+}
@@ -14,6 +14,7 @@ import (
 const (
 	SchemaStateKey    = "cached_schema"
 	SchemaCachedAtKey = "schema_cached_at"
+	SchemaFilterKey   = "schema_filter"
 )
 
 // SchemaCache handles caching of database schemas in session state
@@ -26,8 +27,9 @@ func NewSchemaCache(ttl time.Duration) *SchemaCache {
 	return &SchemaCache{ttl: ttl}
 }
 
-// Get retrieves the cached schema if it exists and is not expired
-func (c *SchemaCache) Get(toolCtx tool.Context) *database.DatabaseSchema {
+// Get retrieves the cached schema if it exists, is not expired and was read
+// with the same schema filter
+func (c *SchemaCache) Get(toolCtx tool.Context, filter database.SchemaFilter) *database.DatabaseSchema {
 	callbackCtx, ok := toolCtx.(agent.CallbackContext)
 	if !ok {
 		return nil
@@ -45,6 +47,13 @@ func (c *SchemaCache) Get(toolCtx tool.Context) *database.DatabaseSchema {
 		return nil
 	}
 
+	// schemas cached without a filter key only cover the public schema
+	cachedFilter, err := state.Get(SchemaFilterKey)
+	if err != nil || cachedFilter != filter.Key() {
+		logger.Debug().Msg("cached schema was read with another schema filter")
+		return nil
+	}
+
 	cachedAtVal, err := state.Get(SchemaCachedAtKey)
 	if err != nil || cachedAtVal == nil {
 		return nil
@@ -75,8 +84,8 @@ func (c *SchemaCache) Get(toolCtx tool.Context) *database.DatabaseSchema {
 	return &schema
 }
 
-// Set stores the schema in session state with current timestamp
-func (c *SchemaCache) Set(toolCtx tool.Context, schema *database.DatabaseSchema) error {
+// Set stores the schema in session state with current timestamp and the filter it was read with
+func (c *SchemaCache) Set(toolCtx tool.Context, filter database.SchemaFilter, schema *database.DatabaseSchema) error {
 	callbackCtx, ok := toolCtx.(agent.CallbackContext)
 	if !ok {
 		return nil
@@ -93,6 +102,10 @@ func (c *SchemaCache) Set(toolCtx tool.Context, schema *database.DatabaseSchema)
 		return err
 	}
 
+	if err := state.Set(SchemaFilterKey, filter.Key()); err != nil {
+		return err
+	}
+
 	if err := state.Set(SchemaCachedAtKey, time.Now().Format(time.RFC3339)); err != nil {
 		return err
 	}
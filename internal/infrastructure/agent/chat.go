@@ -3,9 +3,11 @@ package agent
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	"github.com/mololab/alodb/internal/domain/database"
 	"github.com/mololab/alodb/internal/infrastructure/agent/response"
 	"github.com/mololab/alodb/pkg/logger"
 
@@ -16,34 +18,58 @@ import (
 
 // Chat processes a chat request and returns a response
 func (a *DBAgent) Chat(ctx context.Context, req domainAgent.ChatRequest) (*domainAgent.ChatResponse, error) {
+	return a.chat(ctx, req, nil)
+}
+
+// ChatStream processes a chat request like Chat, reporting tool calls, tool
+// results and model text to emit while the agent runs
+func (a *DBAgent) ChatStream(ctx context.Context, req domainAgent.ChatRequest, emit func(domainAgent.StreamEvent)) (*domainAgent.ChatResponse, error) {
+	return a.chat(ctx, req, emit)
+}
+
+func (a *DBAgent) chat(ctx context.Context, req domainAgent.ChatRequest, emit func(domainAgent.StreamEvent)) (*domainAgent.ChatResponse, error) {
 	logger.Debug().
-		Str("message", req.Message).
+		Int("message_length", len(req.Message)).
 		Bool("has_connection", req.ConnectionString != "").
 		Msg("processing chat request")
 
-	sessionID, err := a.getOrCreateSession(ctx, req.SessionID)
+	sessionID, err := a.getOrCreateSession(ctx, req.UserID, req.SessionID, req.Message)
 	if err != nil {
 		return nil, fmt.Errorf("failed to manage session: %w", err)
 	}
 
-	ctx = a.storeSecureContext(ctx, req.ConnectionString)
+	ctx = a.storeSecureContext(ctx, req.ConnectionString, req.SchemaFilter)
+
+	usage := domainAgent.UsageFromContext(ctx)
+	if usage == nil {
+		usage = &domainAgent.TokenUsage{}
+		ctx = domainAgent.WithUsage(ctx, usage)
+	}
 
-	responseText, err := a.runAgentToCompletion(ctx, sessionID, req.Message)
+	responseText, err := a.runAgentToCompletion(ctx, req.UserID, sessionID, req.Message, emit)
 	if err != nil {
 		return nil, fmt.Errorf("agent execution failed: %w", err)
 	}
 
-	logger.Debug().Str("response", truncateForLog(responseText, 100)).Msg("chat completed")
+	logger.Debug().
+		Str("response", truncateForLog(responseText, 100)).
+		Int64("total_tokens", usage.TotalTokens).
+		Msg("chat completed")
 
 	parser := response.NewParser()
-	return parser.Parse(sessionID, responseText)
+	resp, err := parser.Parse(sessionID, responseText)
+	if err != nil {
+		return nil, err
+	}
+	resp.Usage = *usage
+	return resp, nil
 }
 
 // getOrCreateSession returns existing session ID or creates a new one
-func (a *DBAgent) getOrCreateSession(ctx context.Context, existingID string) (string, error) {
+func (a *DBAgent) getOrCreateSession(ctx context.Context, userID, existingID, message string) (string, error) {
 	if existingID != "" {
 		logger.Debug().Str("session_id", existingID).Msg("continuing session")
-		if err := a.ensureSession(ctx, existingID); err != nil {
+		if err := a.ensureSession(ctx, userID, existingID, message); err != nil {
 			return "", err
 		}
 		return existingID, nil
@@ -51,25 +77,38 @@ func (a *DBAgent) getOrCreateSession(ctx context.Context, existingID string) (st
 
 	newID := uuid.New().String()
 	logger.Debug().Str("session_id", newID).Msg("new session created")
-	if err := a.ensureSession(ctx, newID); err != nil {
+	if err := a.ensureSession(ctx, userID, newID, message); err != nil {
 		return "", err
 	}
 	return newID, nil
 }
 
-// storeSecureContext adds secure data to context (connection string, cache TTL)
-func (a *DBAgent) storeSecureContext(ctx context.Context, connStr string) context.Context {
+// storeSecureContext adds secure data to context (connection string, cache TTL, query and schema limits, connection policy, schema filter)
+func (a *DBAgent) storeSecureContext(ctx context.Context, connStr string, filter database.SchemaFilter) context.Context {
 	if connStr != "" {
 		ctx = context.WithValue(ctx, connectionStringKey, connStr)
 	}
 	ctx = context.WithValue(ctx, schemaCacheTTLKey, a.schemaCacheTTL)
+	ctx = context.WithValue(ctx, queryLimitsKey, a.queryLimits)
+	ctx = context.WithValue(ctx, schemaLimitsKey, a.schemaLimits)
+	ctx = context.WithValue(ctx, policyKey, a.policy)
+	ctx = context.WithValue(ctx, schemaFilterKey, filter)
 	return ctx
 }
 
-// runAgentToCompletion runs the agent and waits for the final response after all tool calls
-func (a *DBAgent) runAgentToCompletion(ctx context.Context, sessionID, message string) (string, error) {
+// runAgentToCompletion runs the agent and waits for the final response after all tool calls.
+// When emit is set, the model output is streamed and progress is reported to it.
+func (a *DBAgent) runAgentToCompletion(ctx context.Context, userID, sessionID, message string, emit func(domainAgent.StreamEvent)) (string, error) {
 	content := genai.NewContentFromText(message, genai.RoleUser)
-	events := a.runner.Run(ctx, sessionID, sessionID, content, agent.RunConfig{})
+
+	runConfig := agent.RunConfig{}
+	var streamer *eventStreamer
+	if emit != nil {
+		runConfig.StreamingMode = agent.StreamingModeSSE
+		streamer = newEventStreamer(emit)
+	}
+
+	events := a.runner.Run(ctx, userID, sessionID, content, runConfig)
 
 	var finalResponse string
 	var lastModelResponse string
@@ -83,6 +122,20 @@ func (a *DBAgent) runAgentToCompletion(ctx context.Context, sessionID, message s
 		}
 
 		logEvent(event, eventCount)
+		if streamer != nil {
+			streamer.handle(event)
+		}
+
+		// partial events are chunks of the complete response that follows them
+		if event.Partial {
+			continue
+		}
+
+		if meta := event.UsageMetadata; meta != nil {
+			if usage := domainAgent.UsageFromContext(ctx); usage != nil {
+				usage.Add(int64(meta.PromptTokenCount), int64(meta.CandidatesTokenCount), int64(meta.TotalTokenCount))
+			}
+		}
 
 		if event.Content != nil && event.Content.Role == string(genai.RoleModel) {
 			text := ExtractTextFromEvent(event)
@@ -142,12 +195,13 @@ func truncateForLog(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-// ensureSession creates a session if it doesn't exist
-func (a *DBAgent) ensureSession(ctx context.Context, sessionID string) error {
+// ensureSession creates a session if it doesn't exist, titled after the first message
+func (a *DBAgent) ensureSession(ctx context.Context, userID, sessionID, message string) error {
 	_, err := a.sessionService.Get(ctx, &session.GetRequest{
-		AppName:   agentName,
-		UserID:    sessionID,
-		SessionID: sessionID,
+		AppName:         agentName,
+		UserID:          userID,
+		SessionID:       sessionID,
+		NumRecentEvents: 1,
 	})
 	if err == nil {
 		return nil // session exists
@@ -156,8 +210,13 @@ func (a *DBAgent) ensureSession(ctx context.Context, sessionID string) error {
 	// create new session
 	_, err = a.sessionService.Create(ctx, &session.CreateRequest{
 		AppName:   agentName,
-		UserID:    sessionID,
+		UserID:    userID,
 		SessionID: sessionID,
+		State: map[string]any{
+			sessionTitleKey:     titleFromMessage(message),
+			sessionCreatedAtKey: time.Now().UTC().Format(time.RFC3339Nano),
+			sessionModelKey:     a.modelSlug,
+		},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
@@ -7,6 +7,11 @@ import (
 	"time"
 
 	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	"github.com/mololab/alodb/internal/infrastructure/agent/tools"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/llm/anthropic"
+	"github.com/mololab/alodb/internal/infrastructure/llm/fake"
+	"github.com/mololab/alodb/internal/infrastructure/llm/openai"
 	"github.com/mololab/alodb/pkg/logger"
 
 	"google.golang.org/adk/agent/llmagent"
@@ -25,19 +30,22 @@ const (
 )
 
 type AgentParams struct {
-	ModelSlug      string
-	APIKey         string
+	Model          domainAgent.Model
+	Provider       domainAgent.ProviderSettings
 	SchemaCacheTTL time.Duration
+	QueryLimits    tools.QueryLimits
+	SchemaLimits   tools.SchemaLimits
+	Policy         *connection.Policy
 	SessionService session.Service
 }
 
 func NewDBAgent(ctx context.Context, params AgentParams) (*DBAgent, error) {
-	modelInfo, ok := domainAgent.GetModelBySlug(params.ModelSlug)
-	if !ok {
-		return nil, fmt.Errorf("unknown model: %s", params.ModelSlug)
+	modelInfo := params.Model
+	if modelInfo.Slug == "" {
+		return nil, fmt.Errorf("model is required")
 	}
 
-	if params.APIKey == "" {
+	if params.Provider.APIKey == "" && domainAgent.ProviderRegistry[modelInfo.Provider].RequiresAPIKey() {
 		return nil, fmt.Errorf("API key is required for provider: %s", modelInfo.Provider)
 	}
 
@@ -47,12 +55,12 @@ func NewDBAgent(ctx context.Context, params AgentParams) (*DBAgent, error) {
 	}
 
 	logger.Debug().
-		Str("model", params.ModelSlug).
+		Str("model", modelInfo.Slug).
 		Str("provider", string(modelInfo.Provider)).
 		Int("instruction_bytes", len(instruction)).
 		Msg("creating agent")
 
-	llmModel, err := createModel(ctx, modelInfo, params.APIKey)
+	llmModel, err := createModel(ctx, modelInfo, params.Provider)
 	if err != nil {
 		return nil, err
 	}
@@ -86,19 +94,35 @@ func NewDBAgent(ctx context.Context, params AgentParams) (*DBAgent, error) {
 		agent:          dbAgent,
 		runner:         agentRunner,
 		sessionService: params.SessionService,
-		modelSlug:      params.ModelSlug,
+		modelSlug:      modelInfo.Slug,
 		schemaCacheTTL: params.SchemaCacheTTL,
+		queryLimits:    params.QueryLimits,
+		schemaLimits:   params.SchemaLimits,
+		policy:         params.Policy,
 	}, nil
 }
 
-func createModel(ctx context.Context, m domainAgent.Model, apiKey string) (model.LLM, error) {
+func createModel(ctx context.Context, m domainAgent.Model, settings domainAgent.ProviderSettings) (model.LLM, error) {
 	switch m.Provider {
 	case domainAgent.ProviderGoogle:
 		return gemini.NewModel(ctx, m.Slug, &genai.ClientConfig{
-			APIKey: apiKey,
+			APIKey: settings.APIKey,
 		})
 	case domainAgent.ProviderOpenAI:
-		return nil, fmt.Errorf("OpenAI provider not yet supported")
+		return openai.NewModel(m.Slug, openai.Config{
+			APIKey: settings.APIKey,
+		})
+	case domainAgent.ProviderAnthropic:
+		return anthropic.NewModel(m.Slug, anthropic.Config{
+			APIKey: settings.APIKey,
+		})
+	case domainAgent.ProviderOpenAICompatible:
+		return openai.NewModel(m.Slug, openai.Config{
+			APIKey:  settings.APIKey,
+			BaseURL: settings.BaseURL,
+		})
+	case domainAgent.ProviderFake:
+		return fake.NewModelFromFile(m.Slug, settings.ScriptPath)
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", m.Provider)
 	}
@@ -118,8 +142,20 @@ func createTools() ([]tool.Tool, error) {
 		return nil, fmt.Errorf("failed to create schema reader tool: %w", err)
 	}
 
+	queryExecutorTool, err := createQueryExecutorTool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query executor tool: %w", err)
+	}
+
+	queryOptimizerTool, err := createQueryOptimizerTool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query optimizer tool: %w", err)
+	}
+
 	return []tool.Tool{
 		schemaReaderTool,
+		queryExecutorTool,
+		queryOptimizerTool,
 	}, nil
 }
 
@@ -3,10 +3,13 @@ package agent
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	"github.com/mololab/alodb/internal/infrastructure/agent/tools"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
 	"github.com/mololab/alodb/pkg/logger"
 
 	"google.golang.org/adk/session"
@@ -16,16 +19,29 @@ type Manager struct {
 	agents         map[string]*DBAgent
 	mu             sync.RWMutex
 	sessionService session.Service
-	providers      map[domainAgent.Provider]string
+	providers      map[domainAgent.Provider]domainAgent.ProviderSettings
 	schemaCacheTTL time.Duration
+	queryLimits    tools.QueryLimits
+	schemaLimits   tools.SchemaLimits
+	policy         *connection.Policy
 }
 
-func NewManager(providers map[domainAgent.Provider]string, schemaCacheTTL time.Duration) *Manager {
+func NewManager(config domainAgent.AgentConfig, sessionService session.Service, policy *connection.Policy) *Manager {
 	return &Manager{
 		agents:         make(map[string]*DBAgent),
-		sessionService: session.InMemoryService(),
-		providers:      providers,
-		schemaCacheTTL: schemaCacheTTL,
+		sessionService: sessionService,
+		providers:      config.Providers,
+		schemaCacheTTL: config.SchemaCacheTTL,
+		queryLimits: tools.QueryLimits{
+			MaxRows:          config.QueryMaxRows,
+			StatementTimeout: config.QueryTimeout,
+		},
+		schemaLimits: tools.SchemaLimits{
+			ConnectTimeout:   config.SchemaConnectTimeout,
+			StatementTimeout: config.SchemaStatementTimeout,
+			TotalTimeout:     config.SchemaTimeout,
+		},
+		policy: policy,
 	}
 }
 
@@ -48,25 +64,26 @@ func (m *Manager) createAgent(ctx context.Context, modelSlug string) (*DBAgent,
 		return agent, nil
 	}
 
-	model, ok := domainAgent.GetModelBySlug(modelSlug)
+	model, settings, ok := m.findModel(modelSlug)
 	if !ok {
+		if known, exists := domainAgent.GetModelBySlug(modelSlug); exists {
+			return nil, fmt.Errorf("provider %s is not configured", known.Provider)
+		}
 		return nil, fmt.Errorf("unknown model: %s", modelSlug)
 	}
 
-	apiKey, ok := m.providers[model.Provider]
-	if !ok || apiKey == "" {
-		return nil, fmt.Errorf("provider %s is not configured", model.Provider)
-	}
-
 	logger.Info().
 		Str("model", modelSlug).
 		Str("provider", string(model.Provider)).
 		Msg("initializing agent")
 
 	agent, err := NewDBAgent(ctx, AgentParams{
-		ModelSlug:      modelSlug,
-		APIKey:         apiKey,
+		Model:          model,
+		Provider:       settings,
 		SchemaCacheTTL: m.schemaCacheTTL,
+		QueryLimits:    m.queryLimits,
+		SchemaLimits:   m.schemaLimits,
+		Policy:         m.policy,
 		SessionService: m.sessionService,
 	})
 	if err != nil {
@@ -82,20 +99,56 @@ func (m *Manager) createAgent(ctx context.Context, modelSlug string) (*DBAgent,
 func (m *Manager) GetAvailableModels() []domainAgent.Model {
 	var models []domainAgent.Model
 
-	for provider, apiKey := range m.providers {
-		if apiKey == "" {
-			continue
-		}
+	for _, provider := range m.enabledProviders() {
+		models = append(models, m.providerModels(provider)...)
+	}
+
+	return models
+}
+
+// DefaultModelSlug returns the default Gemini model when Google is configured,
+// otherwise the first available model
+func (m *Manager) DefaultModelSlug() string {
+	if _, ok := m.providers[domainAgent.ProviderGoogle]; ok {
+		return domainAgent.GetDefaultModelSlug()
+	}
 
-		cfg, ok := domainAgent.ProviderRegistry[provider]
-		if !ok {
-			continue
+	if models := m.GetAvailableModels(); len(models) > 0 {
+		return models[0].Slug
+	}
+	return domainAgent.GetDefaultModelSlug()
+}
+
+// findModel looks up a model among the enabled providers
+func (m *Manager) findModel(slug string) (domainAgent.Model, domainAgent.ProviderSettings, bool) {
+	for _, provider := range m.enabledProviders() {
+		for _, model := range m.providerModels(provider) {
+			if model.Slug == slug {
+				return model, m.providers[provider], true
+			}
 		}
+	}
+	return domainAgent.Model{}, domainAgent.ProviderSettings{}, false
+}
 
-		models = append(models, cfg.Models...)
+// enabledProviders returns the configured providers in a stable order
+func (m *Manager) enabledProviders() []domainAgent.Provider {
+	providers := make([]domainAgent.Provider, 0, len(m.providers))
+	for provider := range m.providers {
+		if _, ok := domainAgent.ProviderRegistry[provider]; ok {
+			providers = append(providers, provider)
+		}
 	}
+	slices.Sort(providers)
+	return providers
+}
 
-	return models
+// providerModels returns the configured models of a provider, falling back to the registry
+func (m *Manager) providerModels(provider domainAgent.Provider) []domainAgent.Model {
+	if settings := m.providers[provider]; len(settings.Models) > 0 {
+		return settings.Models
+	}
+	return domainAgent.ProviderRegistry[provider].Models
 }
 
 func (m *Manager) Close() error {
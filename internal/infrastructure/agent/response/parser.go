@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	"github.com/mololab/alodb/internal/infrastructure/sqlguard"
 	"github.com/mololab/alodb/pkg/logger"
 )
 
@@ -65,7 +66,7 @@ func (p *Parser) cleanJSON(rawResponse string) string {
 	return strings.TrimSpace(cleaned)
 }
 
-// convertQueries converts parsed queries to domain queries
+// convertQueries converts parsed queries to domain queries, classifying each query
 func (p *Parser) convertQueries(parsedQueries []Query) []domainAgent.Query {
 	if len(parsedQueries) == 0 {
 		return nil
@@ -73,10 +74,13 @@ func (p *Parser) convertQueries(parsedQueries []Query) []domainAgent.Query {
 
 	queries := make([]domainAgent.Query, 0, len(parsedQueries))
 	for _, q := range parsedQueries {
+		analysis := sqlguard.Analyze(q.Query)
 		queries = append(queries, domainAgent.Query{
 			Title:       q.Title,
 			Query:       q.Query,
 			Description: q.Description,
+			IsReadOnly:  analysis.ReadOnly(),
+			Risk:        analysis.Risk(),
 		})
 	}
 	return queries
@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	domainSession "github.com/mololab/alodb/internal/domain/session"
+	"github.com/mololab/alodb/internal/infrastructure/agent/response"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// Session state keys for conversation metadata
+const (
+	sessionTitleKey     = "session_title"
+	sessionCreatedAtKey = "session_created_at"
+	sessionModelKey     = "session_model"
+
+	maxTitleLength = 80
+)
+
+// ErrSessionNotFound is returned when a session does not exist for the user
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns the user's sessions, most recently updated first
+func (m *Manager) ListSessions(ctx context.Context, userID string) ([]domainSession.Summary, error) {
+	resp, err := m.sessionService.List(ctx, &session.ListRequest{
+		AppName: agentName,
+		UserID:  userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	summaries := make([]domainSession.Summary, 0, len(resp.Sessions))
+	for _, s := range resp.Sessions {
+		summaries = append(summaries, summarize(s))
+	}
+
+	slices.SortFunc(summaries, func(a, b domainSession.Summary) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+
+	return summaries, nil
+}
+
+// GetSession returns a session with its message history
+func (m *Manager) GetSession(ctx context.Context, userID, sessionID string) (*domainSession.Session, error) {
+	s, err := m.getSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domainSession.Session{
+		Summary:  summarize(s),
+		Messages: history(s),
+	}, nil
+}
+
+// RenameSession sets the title of a session
+func (m *Manager) RenameSession(ctx context.Context, userID, sessionID, title string) (*domainSession.Summary, error) {
+	s, err := m.getSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// state changes are only persisted through events, the event has no
+	// content so it never reaches the model
+	event := session.NewEvent("rename-" + sessionID)
+	event.Author = "user"
+	event.Actions.StateDelta[sessionTitleKey] = title
+
+	if err := m.sessionService.AppendEvent(ctx, s, event); err != nil {
+		return nil, fmt.Errorf("failed to rename session: %w", err)
+	}
+
+	summary := summarize(s)
+	summary.Title = title
+	summary.UpdatedAt = event.Timestamp
+	return &summary, nil
+}
+
+// DeleteSession removes a session with its events and state
+func (m *Manager) DeleteSession(ctx context.Context, userID, sessionID string) error {
+	if _, err := m.getSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	err := m.sessionService.Delete(ctx, &session.DeleteRequest{
+		AppName:   agentName,
+		UserID:    userID,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// getSession loads a session, the session services do not distinguish a
+// missing session from other failures
+func (m *Manager) getSession(ctx context.Context, userID, sessionID string) (session.Session, error) {
+	resp, err := m.sessionService.Get(ctx, &session.GetRequest{
+		AppName:   agentName,
+		UserID:    userID,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrSessionNotFound
+	}
+	return resp.Session, nil
+}
+
+// summarize reads the conversation metadata from session state
+func summarize(s session.Session) domainSession.Summary {
+	summary := domainSession.Summary{
+		ID:        s.ID(),
+		Title:     stateString(s.State(), sessionTitleKey),
+		Model:     stateString(s.State(), sessionModelKey),
+		UpdatedAt: s.LastUpdateTime(),
+	}
+
+	if createdAt, err := time.Parse(time.RFC3339Nano, stateString(s.State(), sessionCreatedAtKey)); err == nil {
+		summary.CreatedAt = createdAt
+	} else {
+		summary.CreatedAt = summary.UpdatedAt
+	}
+
+	return summary
+}
+
+// history rebuilds the conversation from session events. Each turn is the
+// user message and the last model text, like the chat response.
+func history(s session.Session) []domainSession.Message {
+	messages := []domainSession.Message{}
+	parser := response.NewParser()
+
+	var reply *session.Event
+	flush := func() {
+		if reply == nil {
+			return
+		}
+		parsed, err := parser.Parse(s.ID(), ExtractTextFromEvent(reply))
+		if err == nil {
+			messages = append(messages, domainSession.Message{
+				Role:      domainSession.RoleAssistant,
+				Content:   parsed.Message,
+				Queries:   parsed.Queries,
+				CreatedAt: reply.Timestamp,
+			})
+		}
+		reply = nil
+	}
+
+	for event := range s.Events().All() {
+		if event.Content == nil {
+			continue
+		}
+
+		switch {
+		case event.Author == "user":
+			text := ExtractTextFromEvent(event)
+			if text == "" {
+				continue
+			}
+			flush()
+			messages = append(messages, domainSession.Message{
+				Role:      domainSession.RoleUser,
+				Content:   text,
+				CreatedAt: event.Timestamp,
+			})
+		case event.Content.Role == string(genai.RoleModel) && ExtractTextFromEvent(event) != "":
+			reply = event
+		}
+	}
+	flush()
+
+	return messages
+}
+
+// titleFromMessage derives a session title from its first message
+func titleFromMessage(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if utf8.RuneCountInString(title) <= maxTitleLength {
+		return title
+	}
+
+	runes := []rune(title)
+	return strings.TrimSpace(string(runes[:maxTitleLength])) + "..."
+}
+
+// stateString reads a string value from session state
+func stateString(state session.State, key string) string {
+	value, err := state.Get(key)
+	if err != nil {
+		return ""
+	}
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	domainSession "github.com/mololab/alodb/internal/domain/session"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func newTestManager() *Manager {
+	return NewManager(domainAgent.AgentConfig{}, session.InMemoryService(), nil)
+}
+
+// createSession stores a session like a chat does, then appends the events
+func createSession(t *testing.T, m *Manager, userID, sessionID, title string, events ...*session.Event) {
+	t.Helper()
+	ctx := context.Background()
+
+	resp, err := m.sessionService.Create(ctx, &session.CreateRequest{
+		AppName:   agentName,
+		UserID:    userID,
+		SessionID: sessionID,
+		State: map[string]any{
+			sessionTitleKey:     title,
+			sessionCreatedAtKey: "2026-10-17T12:00:00Z",
+			sessionModelKey:     "fake",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if err := m.sessionService.AppendEvent(ctx, resp.Session, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestEvent(author string, content *genai.Content) *session.Event {
+	event := session.NewEvent("invocation")
+	event.Author = author
+	event.Content = content
+	return event
+}
+
+func TestSessionHistory(t *testing.T) {
+	m := newTestManager()
+	createSession(t, m, "user-1", "s1", "Users",
+		newTestEvent("user", genai.NewContentFromText("How many users do we have?", genai.RoleUser)),
+		// tool calls and their results are not part of the conversation
+		newTestEvent(agentName, genai.NewContentFromFunctionCall("query_executor", map[string]any{"query": "SELECT count(*) FROM users"}, genai.RoleModel)),
+		newTestEvent(agentName, genai.NewContentFromFunctionResponse("query_executor", map[string]any{"row_count": 1}, genai.RoleUser)),
+		// only the last model text of a turn is the answer
+		newTestEvent(agentName, genai.NewContentFromText("Let me count them.", genai.RoleModel)),
+		newTestEvent(agentName, genai.NewContentFromText("```json\n"+`{"message": "There are 42 users.", "queries": [{"title": "Count users", "query": "SELECT count(*) FROM users"}]}`+"\n```", genai.RoleModel)),
+		// a rename has no content
+		newTestEvent("user", nil),
+		newTestEvent("user", genai.NewContentFromText("And orders?", genai.RoleUser)),
+		newTestEvent(agentName, genai.NewContentFromText("not JSON", genai.RoleModel)),
+		// a turn that failed before the model answered
+		newTestEvent("user", genai.NewContentFromText("Thanks", genai.RoleUser)),
+	)
+
+	s, err := m.GetSession(context.Background(), "user-1", "s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+
+	want := []struct{ role, content string }{
+		{domainSession.RoleUser, "How many users do we have?"},
+		{domainSession.RoleAssistant, "There are 42 users."},
+		{domainSession.RoleUser, "And orders?"},
+		{domainSession.RoleAssistant, "not JSON"},
+		{domainSession.RoleUser, "Thanks"},
+	}
+	if len(s.Messages) != len(want) {
+		t.Fatalf("messages = %+v, want %d", s.Messages, len(want))
+	}
+	for i, w := range want {
+		if got := s.Messages[i]; got.Role != w.role || got.Content != w.content {
+			t.Errorf("message %d = %s %q, want %s %q", i, got.Role, got.Content, w.role, w.content)
+		}
+	}
+	if queries := s.Messages[1].Queries; len(queries) != 1 || queries[0].Query != "SELECT count(*) FROM users" || !queries[0].IsReadOnly {
+		t.Errorf("queries = %+v", queries)
+	}
+
+	wantSummary := domainSession.Summary{ID: "s1", Title: "Users", Model: "fake", CreatedAt: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	if s.ID != wantSummary.ID || s.Title != wantSummary.Title || s.Model != wantSummary.Model || !s.CreatedAt.Equal(wantSummary.CreatedAt) {
+		t.Errorf("summary = %+v, want %+v", s.Summary, wantSummary)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	createSession(t, m, "user-1", "older", "Older")
+	createSession(t, m, "user-1", "newer", "Newer")
+	createSession(t, m, "user-2", "other", "Other")
+
+	// renaming updates the session, so it is listed first
+	if _, err := m.RenameSession(ctx, "user-1", "older", "Renamed"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		userID string
+		titles []string
+	}{
+		{"user-1", []string{"Renamed", "Newer"}},
+		{"user-2", []string{"Other"}},
+		{"user-3", nil},
+	}
+	for _, tt := range tests {
+		summaries, err := m.ListSessions(ctx, tt.userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, s := range summaries {
+			titles = append(titles, s.Title)
+		}
+		if !slices.Equal(titles, tt.titles) {
+			t.Errorf("%s: titles = %v, want %v", tt.userID, titles, tt.titles)
+		}
+	}
+}
+
+func TestRenameSession(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	createSession(t, m, "user-1", "s1", "Users",
+		newTestEvent("user", genai.NewContentFromText("How many users do we have?", genai.RoleUser)))
+
+	summary, err := m.RenameSession(ctx, "user-1", "s1", "User count")
+	if err != nil {
+		t.Fatalf("RenameSession: %v", err)
+	}
+	if summary.Title != "User count" {
+		t.Errorf("title = %q, want %q", summary.Title, "User count")
+	}
+
+	// the title is persisted, and the rename is not a message
+	s, err := m.GetSession(ctx, "user-1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "User count" || len(s.Messages) != 1 {
+		t.Errorf("session = %+v, want the new title and one message", s)
+	}
+
+	if _, err := m.RenameSession(ctx, "user-2", "s1", "Stolen"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("other user: err = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	createSession(t, m, "user-1", "s1", "Users")
+
+	if err := m.DeleteSession(ctx, "user-2", "s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("other user: err = %v, want %v", err, ErrSessionNotFound)
+	}
+	if err := m.DeleteSession(ctx, "user-1", "s1"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if _, err := m.GetSession(ctx, "user-1", "s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("after delete: err = %v, want %v", err, ErrSessionNotFound)
+	}
+	if err := m.DeleteSession(ctx, "user-1", "s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("delete again: err = %v, want %v", err, ErrSessionNotFound)
+	}
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// eventStreamer converts agent events into stream events
+type eventStreamer struct {
+	emit func(domainAgent.StreamEvent)
+	// streamedText is set once partial text of the current model response was emitted
+	streamedText bool
+}
+
+func newEventStreamer(emit func(domainAgent.StreamEvent)) *eventStreamer {
+	return &eventStreamer{emit: emit}
+}
+
+// handle emits the tool calls, tool results and text of an event. Text of a
+// complete response is only emitted when it was not streamed in chunks.
+func (s *eventStreamer) handle(event *session.Event) {
+	if event == nil || event.Content == nil {
+		return
+	}
+
+	isModel := event.Content.Role == string(genai.RoleModel)
+
+	for _, part := range event.Content.Parts {
+		switch {
+		case part.FunctionCall != nil && !event.Partial:
+			s.emit(domainAgent.StreamEvent{
+				Type: domainAgent.StreamEventToolCall,
+				Tool: part.FunctionCall.Name,
+				Args: part.FunctionCall.Args,
+			})
+		case part.FunctionResponse != nil:
+			s.emit(toolResultEvent(part.FunctionResponse))
+		case isModel && part.Text != "" && !part.Thought:
+			if event.Partial {
+				s.streamedText = true
+			} else if s.streamedText {
+				continue
+			}
+			s.emit(domainAgent.StreamEvent{
+				Type: domainAgent.StreamEventText,
+				Text: part.Text,
+			})
+		}
+	}
+
+	if isModel && !event.Partial {
+		s.streamedText = false
+	}
+}
+
+// toolResultEvent reports the status of a tool result without its payload
+func toolResultEvent(resp *genai.FunctionResponse) domainAgent.StreamEvent {
+	event := domainAgent.StreamEvent{
+		Type:   domainAgent.StreamEventToolResult,
+		Tool:   resp.Name,
+		Status: "success",
+	}
+
+	if errMsg, ok := resp.Response["error"].(string); ok {
+		event.Status = "error"
+		event.Message = errMsg
+		return event
+	}
+	if status, ok := resp.Response["status"].(string); ok && status != "" {
+		event.Status = status
+	}
+	if message, ok := resp.Response["message"].(string); ok {
+		event.Message = message
+	}
+
+	return event
+}
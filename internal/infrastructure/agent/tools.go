@@ -3,8 +3,10 @@ package agent
 import (
 	"time"
 
+	"github.com/mololab/alodb/internal/domain/database"
 	"github.com/mololab/alodb/internal/infrastructure/agent/cache"
 	"github.com/mololab/alodb/internal/infrastructure/agent/tools"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
 	"github.com/mololab/alodb/pkg/logger"
 
 	"google.golang.org/adk/tool"
@@ -19,7 +21,7 @@ func createSchemaReaderTool() (tool.Tool, error) {
 	return functiontool.New(
 		functiontool.Config{
 			Name:        "read_schema",
-			Description: "Reads and returns the complete database schema including all tables, columns, primary keys, foreign keys, and indexes. The database connection is already configured. Just call this tool to get the schema.",
+			Description: "Reads and returns the complete database schema including the search path, schemas, and all tables, views, materialized views and foreign tables with their schema-qualified names, kind, view definition, columns with resolved types and enum labels, primary keys, foreign keys with their ON DELETE/ON UPDATE actions, check and unique constraints, and indexes with their method, expressions, INCLUDE columns, partial predicate and validity, plus a catalog of user-defined types. The database connection is already configured. Just call this tool to get the schema.",
 		},
 		schemaReaderHandler,
 	)
@@ -38,10 +40,13 @@ func schemaReaderHandler(toolCtx tool.Context, input tools.SchemaReaderInput) (t
 		}, nil
 	}
 
+	filter, _ := toolCtx.Value(schemaFilterKey).(database.SchemaFilter)
+	limits, _ := toolCtx.Value(schemaLimitsKey).(tools.SchemaLimits)
+
 	cacheTTL := getCacheTTL(toolCtx)
 	schemaCache := cache.NewSchemaCache(cacheTTL)
 
-	if cachedSchema := schemaCache.Get(toolCtx); cachedSchema != nil {
+	if cachedSchema := schemaCache.Get(toolCtx, filter); cachedSchema != nil {
 		logger.Debug().Msg("returning cached schema")
 		return tools.SchemaReaderOutput{
 			Status:  "success",
@@ -51,13 +56,13 @@ func schemaReaderHandler(toolCtx tool.Context, input tools.SchemaReaderInput) (t
 	}
 
 	logger.Debug().Msg("cache miss, reading from database")
-	result, err := tools.ReadSchemaFromDatabase(connStr)
+	result, err := tools.ReadSchemaFromDatabase(toolCtx, policyFrom(toolCtx), connStr, filter, limits)
 	if err != nil {
 		return result, err
 	}
 
 	if result.Status == "success" && result.Schema != nil {
-		if err := schemaCache.Set(toolCtx, result.Schema); err != nil {
+		if err := schemaCache.Set(toolCtx, filter, result.Schema); err != nil {
 			logger.Warn().Err(err).Msg("failed to cache schema")
 		}
 	}
@@ -72,3 +77,67 @@ func getCacheTTL(toolCtx tool.Context) time.Duration {
 	}
 	return defaultSchemaCacheTTL
 }
+
+// policyFrom returns the connection policy of the agent from the tool context
+func policyFrom(toolCtx tool.Context) *connection.Policy {
+	policy, _ := toolCtx.Value(policyKey).(*connection.Policy)
+	return policy
+}
+
+// createQueryExecutorTool creates the read-only query executor tool for the agent
+func createQueryExecutorTool() (tool.Tool, error) {
+	return functiontool.New(
+		functiontool.Config{
+			Name:        "query_executor",
+			Description: "Executes a single read-only SELECT query against the configured database and returns the column names, column types and a limited number of result rows. Use it to verify that a generated query runs and returns the expected data. Writes are rejected.",
+		},
+		queryExecutorHandler,
+	)
+}
+
+// queryExecutorHandler handles the query executor tool invocation
+func queryExecutorHandler(toolCtx tool.Context, input tools.QueryExecutorInput) (tools.QueryExecutorOutput, error) {
+	logger.Debug().Msg("query_executor tool called")
+
+	connStr, ok := toolCtx.Value(connectionStringKey).(string)
+	if !ok || connStr == "" {
+		logger.Warn().Msg("no connection string in context")
+		return tools.QueryExecutorOutput{
+			Status:  "error",
+			Message: "No database connection configured for this session.",
+		}, nil
+	}
+
+	limits, _ := toolCtx.Value(queryLimitsKey).(tools.QueryLimits)
+
+	return tools.ExecuteReadOnlyQuery(toolCtx, policyFrom(toolCtx), connStr, input.Query, limits)
+}
+
+// createQueryOptimizerTool creates the EXPLAIN based query optimizer tool for the agent
+func createQueryOptimizerTool() (tool.Tool, error) {
+	return functiontool.New(
+		functiontool.Config{
+			Name:        "query_optimizer",
+			Description: "Runs EXPLAIN on a single read-only SELECT query and returns the plan steps, estimated cost and rows, and findings such as sequential scans on large tables, missing indexes, cartesian joins and sorts spilling to disk. Set analyze to true to execute the query and get actual row counts and timings.",
+		},
+		queryOptimizerHandler,
+	)
+}
+
+// queryOptimizerHandler handles the query optimizer tool invocation
+func queryOptimizerHandler(toolCtx tool.Context, input tools.QueryOptimizerInput) (tools.QueryOptimizerOutput, error) {
+	logger.Debug().Bool("analyze", input.Analyze).Msg("query_optimizer tool called")
+
+	connStr, ok := toolCtx.Value(connectionStringKey).(string)
+	if !ok || connStr == "" {
+		logger.Warn().Msg("no connection string in context")
+		return tools.QueryOptimizerOutput{
+			Status:  "error",
+			Message: "No database connection configured for this session.",
+		}, nil
+	}
+
+	limits, _ := toolCtx.Value(queryLimitsKey).(tools.QueryLimits)
+
+	return tools.ExplainQuery(toolCtx, policyFrom(toolCtx), connStr, input.Query, input.Analyze, limits)
+}
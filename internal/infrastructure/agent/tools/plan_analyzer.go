@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Thresholds used when analyzing plans
+const (
+	largeTableRows      = 10000
+	highPlanCost        = 1000000
+	misestimateFactor   = 10
+	misestimateMinRows  = 1000
+	maxSuggestedColumns = 3
+)
+
+// Finding severities
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+)
+
+// Finding kinds
+const (
+	FindingSeqScanLargeTable = "seq_scan_large_table"
+	FindingMissingIndex      = "missing_index"
+	FindingCartesianJoin     = "cartesian_join"
+	FindingDiskSort          = "disk_sort"
+	FindingRowMisestimate    = "row_misestimate"
+	FindingHighCost          = "high_cost"
+)
+
+// ExplainResult is a single entry of EXPLAIN (FORMAT JSON) output
+type ExplainResult struct {
+	Plan          PlanNode `json:"Plan"`
+	PlanningTime  float64  `json:"Planning Time"`
+	ExecutionTime float64  `json:"Execution Time"`
+}
+
+// PlanNode is a node of a PostgreSQL query plan
+type PlanNode struct {
+	NodeType           string     `json:"Node Type"`
+	ParentRelationship string     `json:"Parent Relationship"`
+	RelationName       string     `json:"Relation Name"`
+	Schema             string     `json:"Schema"`
+	Alias              string     `json:"Alias"`
+	IndexName          string     `json:"Index Name"`
+	JoinType           string     `json:"Join Type"`
+	StartupCost        float64    `json:"Startup Cost"`
+	TotalCost          float64    `json:"Total Cost"`
+	PlanRows           float64    `json:"Plan Rows"`
+	PlanWidth          int        `json:"Plan Width"`
+	ActualRows         float64    `json:"Actual Rows"`
+	ActualLoops        float64    `json:"Actual Loops"`
+	ActualTotalTime    float64    `json:"Actual Total Time"`
+	Filter             string     `json:"Filter"`
+	IndexCond          string     `json:"Index Cond"`
+	RecheckCond        string     `json:"Recheck Cond"`
+	HashCond           string     `json:"Hash Cond"`
+	MergeCond          string     `json:"Merge Cond"`
+	JoinFilter         string     `json:"Join Filter"`
+	SortKey            []string   `json:"Sort Key"`
+	SortSpaceType      string     `json:"Sort Space Type"`
+	Plans              []PlanNode `json:"Plans"`
+}
+
+// QualifiedRelation returns the schema-qualified relation name of a scan node
+func (n *PlanNode) QualifiedRelation() string {
+	if n.RelationName == "" {
+		return ""
+	}
+	if n.Schema == "" {
+		return n.RelationName
+	}
+	return n.Schema + "." + n.RelationName
+}
+
+// PlanStep is a flattened plan node as reported to the agent
+type PlanStep struct {
+	Depth         int     `json:"depth"`
+	NodeType      string  `json:"node_type"`
+	Relation      string  `json:"relation,omitempty"`
+	Index         string  `json:"index,omitempty"`
+	JoinType      string  `json:"join_type,omitempty"`
+	Condition     string  `json:"condition,omitempty"`
+	EstimatedRows float64 `json:"estimated_rows"`
+	ActualRows    float64 `json:"actual_rows,omitempty"`
+	TotalCost     float64 `json:"total_cost"`
+}
+
+// PlanFinding is a potential problem detected in a plan
+type PlanFinding struct {
+	Kind       string `json:"kind"`
+	Severity   string `json:"severity"`
+	Relation   string `json:"relation,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// FlattenPlan returns the plan nodes in depth-first order
+func FlattenPlan(root *PlanNode) []PlanStep {
+	var steps []PlanStep
+
+	var walk func(n *PlanNode, depth int)
+	walk = func(n *PlanNode, depth int) {
+		steps = append(steps, PlanStep{
+			Depth:         depth,
+			NodeType:      n.NodeType,
+			Relation:      n.QualifiedRelation(),
+			Index:         n.IndexName,
+			JoinType:      n.JoinType,
+			Condition:     firstNonEmpty(n.IndexCond, n.HashCond, n.MergeCond, n.JoinFilter, n.Filter, n.RecheckCond),
+			EstimatedRows: n.PlanRows,
+			ActualRows:    n.ActualRows,
+			TotalCost:     n.TotalCost,
+		})
+		for i := range n.Plans {
+			walk(&n.Plans[i], depth+1)
+		}
+	}
+	walk(root, 0)
+
+	return steps
+}
+
+// PlanRelations returns the distinct relations scanned by a plan
+func PlanRelations(root *PlanNode) []string {
+	seen := make(map[string]bool)
+	var relations []string
+
+	var walk func(n *PlanNode)
+	walk = func(n *PlanNode) {
+		if rel := n.QualifiedRelation(); rel != "" && !seen[rel] {
+			seen[rel] = true
+			relations = append(relations, rel)
+		}
+		for i := range n.Plans {
+			walk(&n.Plans[i])
+		}
+	}
+	walk(root)
+
+	return relations
+}
+
+// AnalyzePlan inspects a plan and returns findings, tableRows maps
+// schema-qualified relation names to their estimated row counts
+func AnalyzePlan(result *ExplainResult, tableRows map[string]float64, analyzed bool) []PlanFinding {
+	var findings []PlanFinding
+
+	if result.Plan.TotalCost > highPlanCost {
+		findings = append(findings, PlanFinding{
+			Kind:     FindingHighCost,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("Estimated total cost is %.0f, the query is likely slow.", result.Plan.TotalCost),
+		})
+	}
+
+	var walk func(n *PlanNode)
+	walk = func(n *PlanNode) {
+		findings = append(findings, analyzeNode(n, tableRows, analyzed)...)
+		for i := range n.Plans {
+			walk(&n.Plans[i])
+		}
+	}
+	walk(&result.Plan)
+
+	return findings
+}
+
+// analyzeNode returns the findings for a single plan node
+func analyzeNode(n *PlanNode, tableRows map[string]float64, analyzed bool) []PlanFinding {
+	var findings []PlanFinding
+	relation := n.QualifiedRelation()
+
+	if n.NodeType == "Seq Scan" {
+		rows := math.Max(tableRows[relation], n.PlanRows)
+		if rows >= largeTableRows {
+			if columns := filterColumns(n.Filter); len(columns) > 0 {
+				findings = append(findings, PlanFinding{
+					Kind:       FindingMissingIndex,
+					Severity:   SeverityWarning,
+					Relation:   relation,
+					Message:    fmt.Sprintf("Sequential scan on %s (~%.0f rows) filtered by %s.", relation, rows, n.Filter),
+					Suggestion: fmt.Sprintf("CREATE INDEX ON %s (%s);", relation, strings.Join(columns, ", ")),
+				})
+			} else {
+				findings = append(findings, PlanFinding{
+					Kind:     FindingSeqScanLargeTable,
+					Severity: SeverityInfo,
+					Relation: relation,
+					Message:  fmt.Sprintf("Full sequential scan on %s (~%.0f rows).", relation, rows),
+				})
+			}
+		}
+	}
+
+	if n.NodeType == "Nested Loop" && n.JoinFilter == "" && len(n.Plans) == 2 && !hasCondition(&n.Plans[1]) {
+		findings = append(findings, PlanFinding{
+			Kind:       FindingCartesianJoin,
+			Severity:   SeverityWarning,
+			Message:    fmt.Sprintf("Nested loop without a join condition produces a cartesian product (~%.0f rows).", n.PlanRows),
+			Suggestion: "Add the missing join condition, usually on a foreign key column.",
+		})
+	}
+
+	if n.SortSpaceType == "Disk" {
+		findings = append(findings, PlanFinding{
+			Kind:       FindingDiskSort,
+			Severity:   SeverityWarning,
+			Message:    fmt.Sprintf("Sort on %s spilled to disk.", strings.Join(n.SortKey, ", ")),
+			Suggestion: "Add an index matching the ORDER BY columns or reduce the number of sorted rows.",
+		})
+	}
+
+	if analyzed && n.ActualLoops > 0 && relation != "" {
+		actual := n.ActualRows
+		estimated := n.PlanRows
+		if math.Max(actual, estimated) >= misestimateMinRows &&
+			math.Max(actual, estimated) >= misestimateFactor*math.Max(math.Min(actual, estimated), 1) {
+			findings = append(findings, PlanFinding{
+				Kind:       FindingRowMisestimate,
+				Severity:   SeverityInfo,
+				Relation:   relation,
+				Message:    fmt.Sprintf("Planner estimated %.0f rows on %s but got %.0f.", estimated, relation, actual),
+				Suggestion: fmt.Sprintf("ANALYZE %s;", relation),
+			})
+		}
+	}
+
+	return findings
+}
+
+// hasCondition reports whether a plan subtree restricts rows by any condition
+func hasCondition(n *PlanNode) bool {
+	if n.IndexCond != "" || n.RecheckCond != "" || n.Filter != "" ||
+		n.HashCond != "" || n.MergeCond != "" || n.JoinFilter != "" {
+		return true
+	}
+	for i := range n.Plans {
+		if hasCondition(&n.Plans[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	castPattern          = regexp.MustCompile(`::[a-z ]+(\[\])?`)
+	literalPattern       = regexp.MustCompile(`'(?:[^']|'')*'`)
+	filterColumnPattern  = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\)*\s*(?:=|<>|!=|<=|>=|<|>|~~\*?|!~~\*?|IS\s|IN\s)`)
+	filterKeywordPattern = regexp.MustCompile(`^(?i:and|or|not|any|all|null|true|false)$`)
+)
+
+// filterColumns extracts the column names compared in a scan filter
+func filterColumns(filter string) []string {
+	if filter == "" {
+		return nil
+	}
+
+	cleaned := literalPattern.ReplaceAllString(filter, "''")
+	cleaned = castPattern.ReplaceAllString(cleaned, "")
+
+	seen := make(map[string]bool)
+	var columns []string
+	for _, match := range filterColumnPattern.FindAllStringSubmatch(cleaned, -1) {
+		column := match[1]
+		if filterKeywordPattern.MatchString(column) || seen[column] {
+			continue
+		}
+		seen[column] = true
+		columns = append(columns, column)
+		if len(columns) == maxSuggestedColumns {
+			break
+		}
+	}
+
+	return columns
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// loadPlan reads an EXPLAIN (FORMAT JSON) fixture from testdata/plans
+func loadPlan(t *testing.T, name string) *ExplainResult {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "plans", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	var results []ExplainResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("fixture %s has %d plans, want 1", name, len(results))
+	}
+	return &results[0]
+}
+
+func TestAnalyzePlan(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		tableRows  map[string]float64
+		analyzed   bool
+		kinds      []string
+		suggestion string
+	}{
+		{
+			fixture: "seq_scan_large_table.json",
+			kinds:   []string{FindingSeqScanLargeTable},
+		},
+		{
+			fixture:    "missing_index.json",
+			tableRows:  map[string]float64{"public.orders": 50000},
+			kinds:      []string{FindingMissingIndex},
+			suggestion: "CREATE INDEX ON public.orders (status, customer_id);",
+		},
+		{
+			fixture:    "cartesian_join.json",
+			kinds:      []string{FindingCartesianJoin},
+			suggestion: "Add the missing join condition, usually on a foreign key column.",
+		},
+		{
+			fixture:  "disk_sort.json",
+			analyzed: true,
+			kinds:    []string{FindingDiskSort},
+		},
+		{
+			fixture:    "row_misestimate.json",
+			analyzed:   true,
+			kinds:      []string{FindingRowMisestimate},
+			suggestion: "ANALYZE analytics.events;",
+		},
+		{
+			// the estimate is only compared with actual rows under ANALYZE
+			fixture: "row_misestimate.json",
+		},
+		{
+			fixture: "high_cost.json",
+			kinds:   []string{FindingHighCost},
+		},
+		{
+			fixture: "clean.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			findings := AnalyzePlan(loadPlan(t, tt.fixture), tt.tableRows, tt.analyzed)
+
+			var kinds []string
+			for _, f := range findings {
+				kinds = append(kinds, f.Kind)
+			}
+			if !slices.Equal(kinds, tt.kinds) {
+				t.Fatalf("kinds = %v, want %v", kinds, tt.kinds)
+			}
+			if tt.suggestion != "" && findings[0].Suggestion != tt.suggestion {
+				t.Errorf("suggestion = %q, want %q", findings[0].Suggestion, tt.suggestion)
+			}
+		})
+	}
+}
+
+func TestAnalyzePlanSmallTable(t *testing.T) {
+	result := loadPlan(t, "missing_index.json")
+
+	findings := AnalyzePlan(result, map[string]float64{"public.orders": 500}, false)
+	if len(findings) != 0 {
+		t.Errorf("findings = %v, want none for a small table", findings)
+	}
+}
+
+func TestFilterColumns(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"", nil},
+		{"(status = 'paid'::text)", []string{"status"}},
+		{"((o.status)::text = 'paid'::text)", []string{"status"}},
+		{"((total > 100) AND (created_at >= '2024-01-01'::date))", []string{"total", "created_at"}},
+		{"((name)::text ~~* '%a = b%'::text)", []string{"name"}},
+		{"(deleted_at IS NULL)", []string{"deleted_at"}},
+		{"((a = 1) OR (b = 2) OR (c = 3) OR (d = 4))", []string{"a", "b", "c"}},
+		{"((a = 1) AND (a < 5))", []string{"a"}},
+		{"(status = ANY ('{paid,shipped}'::text[]))", []string{"status"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			if got := filterColumns(tt.filter); !slices.Equal(got, tt.want) {
+				t.Errorf("filterColumns(%q) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenPlan(t *testing.T) {
+	steps := FlattenPlan(&loadPlan(t, "cartesian_join.json").Plan)
+
+	want := []struct {
+		depth    int
+		nodeType string
+		relation string
+	}{
+		{0, "Nested Loop", ""},
+		{1, "Seq Scan", "public.customers"},
+		{1, "Materialize", ""},
+		{2, "Seq Scan", "public.products"},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(steps), len(want))
+	}
+	for i, w := range want {
+		if steps[i].Depth != w.depth || steps[i].NodeType != w.nodeType || steps[i].Relation != w.relation {
+			t.Errorf("step %d = %+v, want %+v", i, steps[i], w)
+		}
+	}
+}
+
+func TestPlanRelations(t *testing.T) {
+	got := PlanRelations(&loadPlan(t, "high_cost.json").Plan)
+	want := []string{"public.orders", "public.customers"}
+	if !slices.Equal(got, want) {
+		t.Errorf("PlanRelations = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	domainQuery "github.com/mololab/alodb/internal/domain/query"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/query"
+	"github.com/mololab/alodb/internal/infrastructure/sqlguard"
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+// Default limits applied when the caller does not configure them
+const (
+	DefaultQueryMaxRows = 100
+	DefaultQueryTimeout = 10 * time.Second
+)
+
+// QueryLimits bounds how much work a single query execution may do
+type QueryLimits struct {
+	MaxRows          int
+	StatementTimeout time.Duration
+}
+
+// QueryExecutorInput represents the input for the query executor tool
+type QueryExecutorInput struct {
+	Query string `json:"query" jsonschema:"The single SELECT statement to execute"`
+}
+
+// QueryExecutorOutput represents the output from the query executor tool
+type QueryExecutorOutput struct {
+	Status    string               `json:"status"`
+	Columns   []domainQuery.Column `json:"columns,omitempty"`
+	Rows      [][]any              `json:"rows,omitempty"`
+	RowCount  int                  `json:"row_count"`
+	Truncated bool                 `json:"truncated"`
+	Message   string               `json:"message,omitempty"`
+}
+
+// ExecuteReadOnlyQuery runs a single statement inside a read-only transaction
+// and returns at most limits.MaxRows rows. The query is canceled when ctx is.
+func ExecuteReadOnlyQuery(ctx context.Context, policy *connection.Policy, connectionString, statement string, limits QueryLimits) (QueryExecutorOutput, error) {
+	if connectionString == "" {
+		return QueryExecutorOutput{
+			Status:  "error",
+			Message: "No database connection configured. Please provide a connection string.",
+		}, nil
+	}
+
+	statement = strings.TrimSpace(statement)
+	if err := sqlguard.CheckReadOnly(statement); err != nil {
+		return QueryExecutorOutput{
+			Status:  "error",
+			Message: "query rejected: " + logger.RedactError(err),
+		}, nil
+	}
+
+	limits = normalizeLimits(limits)
+
+	db, err := policy.Open(connectionString)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to open database")
+		return QueryExecutorOutput{
+			Status:  "error",
+			Message: "failed to connect to database: " + logger.RedactError(err),
+		}, nil
+	}
+	defer db.Close()
+
+	return queryReadOnly(ctx, db, statement, limits), nil
+}
+
+// queryReadOnly runs a checked statement on db inside a read-only transaction.
+// The transaction and the prepared statement still stop writes and multiple
+// statements that the check missed.
+func queryReadOnly(ctx context.Context, db *sql.DB, statement string, limits QueryLimits) QueryExecutorOutput {
+	tx, err := beginReadOnly(ctx, db, limits.StatementTimeout)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to begin read-only transaction")
+		return QueryExecutorOutput{
+			Status:  "error",
+			Message: logger.RedactError(err),
+		}
+	}
+	// read-only work never needs to be committed
+	defer tx.Rollback()
+
+	// preparing the statement makes PostgreSQL reject multiple commands,
+	// so a trailing "COMMIT; DELETE ..." cannot escape the read-only transaction
+	stmt, err := tx.PrepareContext(ctx, statement)
+	if err != nil {
+		return QueryExecutorOutput{
+			Status:  "error",
+			Message: "failed to prepare query: " + logger.RedactError(err),
+		}
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return QueryExecutorOutput{
+			Status:  "error",
+			Message: "query failed: " + logger.RedactError(err),
+		}
+	}
+	defer rows.Close()
+
+	output, err := collectRows(rows, limits.MaxRows)
+	if err != nil {
+		return QueryExecutorOutput{
+			Status:  "error",
+			Message: "failed to read results: " + logger.RedactError(err),
+		}
+	}
+
+	logger.Info().
+		Int("rows", output.RowCount).
+		Bool("truncated", output.Truncated).
+		Msg("query executed")
+	return output
+}
+
+// normalizeLimits fills in defaults for unset limits
+func normalizeLimits(limits QueryLimits) QueryLimits {
+	if limits.MaxRows <= 0 {
+		limits.MaxRows = DefaultQueryMaxRows
+	}
+	if limits.StatementTimeout <= 0 {
+		limits.StatementTimeout = DefaultQueryTimeout
+	}
+	return limits
+}
+
+// beginReadOnly starts a read-only transaction with a statement timeout
+func beginReadOnly(ctx context.Context, db *sql.DB, timeout time.Duration) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to set statement timeout: %w", err)
+	}
+
+	return tx, nil
+}
+
+// collectRows reads up to maxRows rows and reports whether more were available
+func collectRows(rows *sql.Rows, maxRows int) (QueryExecutorOutput, error) {
+	columns, err := query.ReadColumns(rows)
+	if err != nil {
+		return QueryExecutorOutput{}, err
+	}
+
+	result, truncated, err := query.ReadRows(rows, len(columns), maxRows)
+	if err != nil {
+		return QueryExecutorOutput{}, err
+	}
+
+	return QueryExecutorOutput{
+		Status:    "success",
+		Columns:   columns,
+		Rows:      result,
+		RowCount:  len(result),
+		Truncated: truncated,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readOnlyServer is a database/sql connector that behaves like PostgreSQL for
+// the checks queryReadOnly relies on: a prepared statement holds a single
+// command and a read-only transaction refuses writes
+type readOnlyServer struct {
+	mu         sync.Mutex
+	rows       int
+	statements []string // executed statements, prefixed with how they ran
+	readOnly   []bool
+	rollbacks  int
+	commits    int
+}
+
+func (s *readOnlyServer) Connect(context.Context) (driver.Conn, error) { return &readOnlyConn{s}, nil }
+func (s *readOnlyServer) Driver() driver.Driver                        { return s }
+func (s *readOnlyServer) Open(string) (driver.Conn, error)             { return &readOnlyConn{s}, nil }
+
+func (s *readOnlyServer) record(format string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, fmt.Sprintf(format, args...))
+}
+
+type readOnlyConn struct{ server *readOnlyServer }
+
+func (c *readOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	c.server.record("prepare %s", query)
+	if strings.Contains(strings.TrimRight(query, "; "), ";") {
+		return nil, errors.New("pq: cannot insert multiple commands into a prepared statement")
+	}
+	return &readOnlyStmt{conn: c, query: query}, nil
+}
+
+func (c *readOnlyConn) Close() error { return nil }
+
+func (c *readOnlyConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *readOnlyConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.readOnly = append(c.server.readOnly, opts.ReadOnly)
+	return readOnlyTx{c.server}, nil
+}
+
+func (c *readOnlyConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.server.record("exec %s", query)
+	return driver.RowsAffected(0), nil
+}
+
+type readOnlyStmt struct {
+	conn  *readOnlyConn
+	query string
+}
+
+func (s *readOnlyStmt) Close() error  { return nil }
+func (s *readOnlyStmt) NumInput() int { return -1 }
+
+func (s *readOnlyStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *readOnlyStmt) Query([]driver.Value) (driver.Rows, error) {
+	command := strings.ToUpper(strings.Fields(s.query)[0])
+	if command != "SELECT" {
+		return nil, fmt.Errorf("pq: cannot execute %s in a read-only transaction", command)
+	}
+	return &readOnlyRows{count: s.conn.server.rows}, nil
+}
+
+type readOnlyTx struct{ server *readOnlyServer }
+
+func (t readOnlyTx) Commit() error {
+	t.server.mu.Lock()
+	defer t.server.mu.Unlock()
+	t.server.commits++
+	return nil
+}
+
+func (t readOnlyTx) Rollback() error {
+	t.server.mu.Lock()
+	defer t.server.mu.Unlock()
+	t.server.rollbacks++
+	return nil
+}
+
+type readOnlyRows struct{ count, next int }
+
+func (r *readOnlyRows) Columns() []string { return []string{"id"} }
+func (r *readOnlyRows) Close() error      { return nil }
+
+func (r *readOnlyRows) Next(dest []driver.Value) error {
+	if r.next == r.count {
+		return io.EOF
+	}
+	r.next++
+	dest[0] = int64(r.next)
+	return nil
+}
+
+func TestQueryReadOnly(t *testing.T) {
+	server := &readOnlyServer{rows: 3}
+	db := sql.OpenDB(server)
+	defer db.Close()
+
+	out := queryReadOnly(context.Background(), db, "SELECT id FROM users", QueryLimits{MaxRows: 2, StatementTimeout: 5 * time.Second})
+	if out.Status != "success" || out.RowCount != 2 || !out.Truncated || out.Columns[0].Name != "id" {
+		t.Errorf("output = %+v, want 2 of 3 rows", out)
+	}
+
+	want := []string{"exec SET LOCAL statement_timeout = 5000", "prepare SELECT id FROM users"}
+	if !slices.Equal(server.statements, want) {
+		t.Errorf("statements = %q, want %q", server.statements, want)
+	}
+	if !slices.Equal(server.readOnly, []bool{true}) || server.rollbacks != 1 || server.commits != 0 {
+		t.Errorf("read-only = %v, rollbacks = %d, commits = %d, want one rolled back read-only transaction",
+			server.readOnly, server.rollbacks, server.commits)
+	}
+}
+
+// the transaction and the prepared statement are the second line of defense
+// for statements the SQL check would miss
+func TestQueryReadOnlyRejectsWrites(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{"multiple statements", "SELECT 1; COMMIT; DELETE FROM users", "failed to prepare query: pq: cannot insert multiple commands into a prepared statement"},
+		{"write", "DELETE FROM users", "query failed: pq: cannot execute DELETE in a read-only transaction"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &readOnlyServer{}
+			db := sql.OpenDB(server)
+			defer db.Close()
+
+			out := queryReadOnly(context.Background(), db, tt.statement, normalizeLimits(QueryLimits{}))
+			if out.Status != "error" || out.Message != tt.want {
+				t.Errorf("output = %+v, want error %q", out, tt.want)
+			}
+			if server.rollbacks != 1 || server.commits != 0 {
+				t.Errorf("rollbacks = %d, commits = %d", server.rollbacks, server.commits)
+			}
+		})
+	}
+}
+
+func TestExecuteReadOnlyQueryRejectsWrites(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{"multiple statements", "SELECT 1; DELETE FROM users", "query rejected: only a single statement is allowed"},
+		{"statement behind a comment", "SELECT 1 /* ; */; DROP TABLE users", "query rejected: only a single statement is allowed"},
+		{"write", "UPDATE users SET admin = true", "query rejected: only read-only SELECT queries are allowed"},
+		{"data-modifying CTE", "WITH d AS (DELETE FROM users RETURNING *) SELECT * FROM d", "query rejected: only read-only SELECT queries are allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// without a policy any connection attempt would fail, so the
+			// rejection must come before connecting
+			out, err := ExecuteReadOnlyQuery(context.Background(), nil, "postgres://db/app", tt.statement, QueryLimits{})
+			if err != nil || out.Status != "error" || out.Message != tt.want {
+				t.Errorf("output = %+v, err %v, want %q", out, err, tt.want)
+			}
+		})
+	}
+}
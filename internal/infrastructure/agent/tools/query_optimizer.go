@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/sqlguard"
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+// QueryOptimizerInput represents the input for the query optimizer tool
+type QueryOptimizerInput struct {
+	Query   string `json:"query" jsonschema:"The single SELECT statement to analyze"`
+	Analyze bool   `json:"analyze,omitempty" jsonschema:"Execute the query to collect actual row counts and timings (EXPLAIN ANALYZE)"`
+}
+
+// QueryOptimizerOutput represents the output from the query optimizer tool
+type QueryOptimizerOutput struct {
+	Status          string        `json:"status"`
+	TotalCost       float64       `json:"total_cost,omitempty"`
+	EstimatedRows   float64       `json:"estimated_rows,omitempty"`
+	PlanningTimeMs  float64       `json:"planning_time_ms,omitempty"`
+	ExecutionTimeMs float64       `json:"execution_time_ms,omitempty"`
+	Steps           []PlanStep    `json:"steps,omitempty"`
+	Findings        []PlanFinding `json:"findings,omitempty"`
+	Message         string        `json:"message,omitempty"`
+}
+
+// ExplainQuery runs EXPLAIN on a single read-only statement and analyzes the
+// resulting plan. With analyze the statement is executed and canceled when ctx is.
+func ExplainQuery(ctx context.Context, policy *connection.Policy, connectionString, statement string, analyze bool, limits QueryLimits) (QueryOptimizerOutput, error) {
+	if connectionString == "" {
+		return QueryOptimizerOutput{
+			Status:  "error",
+			Message: "No database connection configured. Please provide a connection string.",
+		}, nil
+	}
+
+	statement = strings.TrimSpace(statement)
+	if err := sqlguard.CheckReadOnly(statement); err != nil {
+		return QueryOptimizerOutput{
+			Status:  "error",
+			Message: "query rejected: " + logger.RedactError(err),
+		}, nil
+	}
+
+	limits = normalizeLimits(limits)
+
+	db, err := policy.Open(connectionString)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to open database")
+		return QueryOptimizerOutput{
+			Status:  "error",
+			Message: "failed to connect to database: " + logger.RedactError(err),
+		}, nil
+	}
+	defer db.Close()
+
+	// ANALYZE executes the statement, the read-only transaction keeps that safe
+	tx, err := beginReadOnly(ctx, db, limits.StatementTimeout)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to begin read-only transaction")
+		return QueryOptimizerOutput{
+			Status:  "error",
+			Message: logger.RedactError(err),
+		}, nil
+	}
+	defer tx.Rollback()
+
+	result, err := explain(ctx, tx, statement, analyze)
+	if err != nil {
+		return QueryOptimizerOutput{
+			Status:  "error",
+			Message: "explain failed: " + logger.RedactError(err),
+		}, nil
+	}
+
+	tableRows, err := relationRowEstimates(ctx, tx, PlanRelations(&result.Plan))
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to read table row estimates")
+	}
+
+	findings := AnalyzePlan(result, tableRows, analyze)
+	logger.Info().
+		Float64("cost", result.Plan.TotalCost).
+		Int("findings", len(findings)).
+		Msg("query plan analyzed")
+
+	return QueryOptimizerOutput{
+		Status:          "success",
+		TotalCost:       result.Plan.TotalCost,
+		EstimatedRows:   result.Plan.PlanRows,
+		PlanningTimeMs:  result.PlanningTime,
+		ExecutionTimeMs: result.ExecutionTime,
+		Steps:           FlattenPlan(&result.Plan),
+		Findings:        findings,
+	}, nil
+}
+
+// explain runs EXPLAIN (FORMAT JSON) and parses the plan
+func explain(ctx context.Context, tx *sql.Tx, statement string, analyze bool) (*ExplainResult, error) {
+	options := "VERBOSE, FORMAT JSON"
+	if analyze {
+		options = "ANALYZE, BUFFERS, " + options
+	}
+
+	// preparing the statement makes PostgreSQL reject multiple commands
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("EXPLAIN (%s) %s", options, statement))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var planJSON []byte
+	if err := stmt.QueryRowContext(ctx).Scan(&planJSON); err != nil {
+		return nil, err
+	}
+
+	var results []ExplainResult
+	if err := json.Unmarshal(planJSON, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("empty plan")
+	}
+
+	return &results[0], nil
+}
+
+// relationRowEstimates returns the planner row estimates for schema-qualified relations
+func relationRowEstimates(ctx context.Context, tx *sql.Tx, relations []string) (map[string]float64, error) {
+	estimates := make(map[string]float64)
+	if len(relations) == 0 {
+		return estimates, nil
+	}
+
+	query := `
+		SELECT n.nspname || '.' || c.relname, GREATEST(c.reltuples, 0)::float8
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname || '.' || c.relname = ANY($1)
+	`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(relations))
+	if err != nil {
+		return estimates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var reltuples float64
+		if err := rows.Scan(&name, &reltuples); err != nil {
+			return estimates, err
+		}
+		estimates[name] = reltuples
+	}
+
+	return estimates, rows.Err()
+}
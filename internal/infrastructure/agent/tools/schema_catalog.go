@@ -0,0 +1,396 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/mololab/alodb/internal/domain/database"
+)
+
+// catalogReader reads one kind of table detail for all tables at once and
+// adds it to the tables it belongs to, looked up by oid
+type catalogReader func(ctx context.Context, db querier, oids pq.Int64Array, tables map[uint32]*database.TableSchema) error
+
+// catalogReaders run in order, each with a single set-based query. clear
+// drops what a reader added, when it fails part way.
+var catalogReaders = []struct {
+	name  string
+	read  catalogReader
+	clear func(table *database.TableSchema)
+}{
+	{"columns", readColumns, func(t *database.TableSchema) { t.Columns = nil }},
+	{"primary keys", readPrimaryKeys, func(t *database.TableSchema) { t.PrimaryKey = nil }},
+	{"foreign keys", readForeignKeys, func(t *database.TableSchema) { t.ForeignKeys = nil }},
+	{"constraints", readConstraints, func(t *database.TableSchema) { t.Checks, t.Uniques = nil, nil }},
+	{"indexes", readIndexes, func(t *database.TableSchema) { t.Indexes = nil }},
+}
+
+// getTableSchemas reads the details of all tables with one query per catalog
+// and assembles them in Go. Tables keep the order of refs; columns are in
+// column order, primary key and index columns in key order, constraints and
+// indexes sorted by name. When a reader fails, the tables are returned with
+// the details of the readers before it and a *partialSchemaError.
+func getTableSchemas(ctx context.Context, db querier, refs []tableRef) ([]database.TableSchema, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	tables := make([]database.TableSchema, len(refs))
+	byOID := make(map[uint32]*database.TableSchema, len(refs))
+	oids := make(pq.Int64Array, len(refs))
+	for i, ref := range refs {
+		tables[i] = database.TableSchema{
+			Schema:        ref.schema,
+			Name:          ref.name,
+			QualifiedName: ref.qualifiedName,
+			Kind:          ref.kind,
+			Definition:    ref.definition,
+		}
+		byOID[ref.oid] = &tables[i]
+		oids[i] = int64(ref.oid)
+	}
+
+	for i, reader := range catalogReaders {
+		if err := reader.read(ctx, db, oids, byOID); err != nil {
+			partial := &partialSchemaError{err: fmt.Errorf("failed to get %s: %w", reader.name, err)}
+			for _, missing := range catalogReaders[i:] {
+				partial.missing = append(partial.missing, missing.name)
+			}
+			for j := range tables {
+				reader.clear(&tables[j])
+			}
+			return tables, partial
+		}
+	}
+
+	return tables, nil
+}
+
+// readColumns reads the columns of the tables with their resolved types.
+// pg_attribute is read instead of information_schema.columns, which leaves out
+// materialized views and reports enums and arrays only as USER-DEFINED and ARRAY.
+// Lengths, precision and scale are decoded from the type modifier of the column,
+// or of the base type for domains, as information_schema does without calling
+// its internal _pg_* functions, which are not a stable interface. The scale
+// keeps its sign, PostgreSQL 15 allows negative scales.
+func readColumns(ctx context.Context, db querier, oids pq.Int64Array, tables map[uint32]*database.TableSchema) error {
+	query := `
+		SELECT
+			a.attrelid,
+			a.attname,
+			format_type(a.atttypid, NULL) as data_type,
+			NOT a.attnotnull as is_nullable,
+			CASE WHEN a.attgenerated = '' THEN COALESCE(pg_get_expr(d.adbin, d.adrelid), '') ELSE '' END as column_default,
+			COALESCE(col_description(a.attrelid, a.attnum), '') as column_comment,
+			CASE WHEN tn.nspname = 'pg_catalog' THEN '' ELSE tn.nspname END as udt_schema,
+			t.typname as udt_name,
+			t.typtype,
+			t.typcategory,
+			CASE WHEN t.typcategory = 'A' THEN format_type(t.typelem, NULL) ELSE '' END as element_type,
+			CASE
+				WHEN tm.typmod < 0 THEN NULL
+				WHEN tm.typid IN ('bpchar'::regtype::oid, 'varchar'::regtype::oid) THEN tm.typmod - 4
+				WHEN tm.typid IN ('bit'::regtype::oid, 'varbit'::regtype::oid) THEN tm.typmod
+			END as max_length,
+			CASE WHEN tm.typid = 'numeric'::regtype::oid AND tm.typmod >= 0 THEN
+				((tm.typmod - 4) >> 16) & 65535
+			END as numeric_precision,
+			CASE WHEN tm.typid = 'numeric'::regtype::oid AND tm.typmod >= 0 THEN
+				(((tm.typmod - 4) & 2047) # 1024) - 1024
+			END as numeric_scale,
+			ARRAY(
+				SELECT e.enumlabel
+				FROM pg_enum e
+				WHERE e.enumtypid IN (t.oid, t.typelem, t.typbasetype)
+				ORDER BY e.enumsortorder
+			) as enum_labels,
+			CASE WHEN t.typtype = 'd' THEN format_type(t.typbasetype, t.typtypmod) ELSE '' END as domain_base_type,
+			ARRAY(
+				SELECT pg_get_constraintdef(con.oid, true)
+				FROM pg_constraint con
+				WHERE con.contypid = t.oid
+				ORDER BY con.conname
+			) as domain_constraints
+		FROM pg_attribute a
+		JOIN pg_type t ON t.oid = a.atttypid
+		JOIN pg_namespace tn ON tn.oid = t.typnamespace
+		CROSS JOIN LATERAL (
+			SELECT
+				CASE WHEN t.typtype = 'd' THEN t.typbasetype ELSE a.atttypid END as typid,
+				CASE WHEN t.typtype = 'd' THEN t.typtypmod ELSE a.atttypmod END as typmod
+		) tm
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = ANY($1::oid[])
+		AND a.attnum > 0
+		AND NOT a.attisdropped
+		ORDER BY a.attrelid, a.attnum
+	`
+
+	rows, err := db.QueryContext(ctx, query, oids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relid uint32
+		var col database.ColumnSchema
+		var typtype, typcategory string
+		var maxLength, precision, scale sql.NullInt64
+		if err := rows.Scan(
+			&relid, &col.Name, &col.DataType, &col.IsNullable, &col.Default, &col.Comment,
+			&col.UDTSchema, &col.UDTName, &typtype, &typcategory, &col.ElementType,
+			&maxLength, &precision, &scale,
+			pq.Array(&col.EnumLabels), &col.DomainBaseType, pq.Array(&col.DomainConstraints),
+		); err != nil {
+			return err
+		}
+		col.TypeKind = typeKind(typtype, typcategory)
+		col.MaxLength = nullInt(maxLength)
+		col.NumericPrecision = nullInt(precision)
+		col.NumericScale = nullInt(scale)
+
+		if table, ok := tables[relid]; ok {
+			table.Columns = append(table.Columns, col)
+		}
+	}
+
+	return rows.Err()
+}
+
+// readPrimaryKeys reads the primary key columns of the tables
+func readPrimaryKeys(ctx context.Context, db querier, oids pq.Int64Array, tables map[uint32]*database.TableSchema) error {
+	query := `
+		SELECT i.indrelid, a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = ANY($1::oid[])
+		AND i.indisprimary
+		ORDER BY i.indrelid, array_position(i.indkey, a.attnum)
+	`
+
+	rows, err := db.QueryContext(ctx, query, oids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relid uint32
+		var colName string
+		if err := rows.Scan(&relid, &colName); err != nil {
+			return err
+		}
+		if table, ok := tables[relid]; ok {
+			table.PrimaryKey = append(table.PrimaryKey, colName)
+		}
+	}
+
+	return rows.Err()
+}
+
+// referentialActions maps pg_constraint.confdeltype and confupdtype to actions
+var referentialActions = map[string]database.ReferentialAction{
+	"a": database.ActionNoAction,
+	"r": database.ActionRestrict,
+	"c": database.ActionCascade,
+	"n": database.ActionSetNull,
+	"d": database.ActionSetDefault,
+}
+
+// readForeignKeys reads the foreign key constraints of the tables. The
+// referenced table may live in another schema.
+func readForeignKeys(ctx context.Context, db querier, oids pq.Int64Array, tables map[uint32]*database.TableSchema) error {
+	query := `
+		SELECT
+			con.conrelid,
+			con.conname,
+			a.attname,
+			rn.nspname AS foreign_schema_name,
+			rc.relname AS foreign_table_name,
+			ra.attname AS foreign_column_name,
+			con.confdeltype,
+			con.confupdtype,
+			con.condeferrable,
+			con.condeferred
+		FROM pg_constraint con
+		CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refattnum, ord)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+		JOIN pg_class rc ON rc.oid = con.confrelid
+		JOIN pg_namespace rn ON rn.oid = rc.relnamespace
+		JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refattnum
+		WHERE con.contype = 'f'
+		AND con.conrelid = ANY($1::oid[])
+		ORDER BY con.conrelid, con.conname, k.ord
+	`
+
+	rows, err := db.QueryContext(ctx, query, oids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relid uint32
+		var constraintName, colName, refSchema, refTable, refCol, onDelete, onUpdate string
+		var deferrable, deferred bool
+		if err := rows.Scan(&relid, &constraintName, &colName, &refSchema, &refTable, &refCol,
+			&onDelete, &onUpdate, &deferrable, &deferred); err != nil {
+			return err
+		}
+
+		table, ok := tables[relid]
+		if !ok {
+			continue
+		}
+
+		// rows of one constraint are adjacent, constraint names are unique per table
+		if n := len(table.ForeignKeys); n > 0 && table.ForeignKeys[n-1].Name == constraintName {
+			fk := &table.ForeignKeys[n-1]
+			fk.Columns = append(fk.Columns, colName)
+			fk.ReferencedColumn = append(fk.ReferencedColumn, refCol)
+		} else {
+			table.ForeignKeys = append(table.ForeignKeys, database.ForeignKey{
+				Name:              constraintName,
+				Columns:           []string{colName},
+				ReferencedSchema:  refSchema,
+				ReferencedTable:   refTable,
+				ReferencedColumn:  []string{refCol},
+				OnDelete:          referentialActions[onDelete],
+				OnUpdate:          referentialActions[onUpdate],
+				Deferrable:        deferrable,
+				InitiallyDeferred: deferred,
+			})
+		}
+	}
+
+	return rows.Err()
+}
+
+// readConstraints reads the CHECK and UNIQUE constraints of the tables. NOT
+// NULL constraints are reported on the columns.
+func readConstraints(ctx context.Context, db querier, oids pq.Int64Array, tables map[uint32]*database.TableSchema) error {
+	query := `
+		SELECT
+			con.conrelid,
+			con.conname,
+			con.contype,
+			ARRAY(
+				SELECT a.attname
+				FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			) AS column_names,
+			pg_get_constraintdef(con.oid, true),
+			con.condeferrable,
+			con.condeferred,
+			con.convalidated
+		FROM pg_constraint con
+		WHERE con.conrelid = ANY($1::oid[])
+		AND con.contype IN ('c', 'u')
+		ORDER BY con.conrelid, con.conname
+	`
+
+	rows, err := db.QueryContext(ctx, query, oids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relid uint32
+		var name, contype, definition string
+		var columns []string
+		var deferrable, deferred, validated bool
+		if err := rows.Scan(&relid, &name, &contype, pq.Array(&columns), &definition, &deferrable, &deferred, &validated); err != nil {
+			return err
+		}
+
+		table, ok := tables[relid]
+		if !ok {
+			continue
+		}
+
+		if contype == "c" {
+			table.Checks = append(table.Checks, database.CheckConstraint{
+				Name:       name,
+				Columns:    columns,
+				Definition: definition,
+				NotValid:   !validated,
+			})
+			continue
+		}
+		table.Uniques = append(table.Uniques, database.UniqueConstraint{
+			Name:              name,
+			Columns:           columns,
+			Deferrable:        deferrable,
+			InitiallyDeferred: deferred,
+		})
+	}
+
+	return rows.Err()
+}
+
+// readIndexes reads the indexes of the tables except primary keys. Key
+// expressions are read with pg_get_indexdef, which is why the key columns are
+// not joined to pg_attribute: expression keys have no attribute.
+func readIndexes(ctx context.Context, db querier, oids pq.Int64Array, tables map[uint32]*database.TableSchema) error {
+	query := `
+		SELECT
+			ix.indrelid,
+			i.relname as index_name,
+			ARRAY(
+				SELECT CASE
+					WHEN ix.indkey[k - 1] = 0 THEN pg_get_indexdef(ix.indexrelid, k, true)
+					ELSE (SELECT a.attname FROM pg_attribute a WHERE a.attrelid = ix.indrelid AND a.attnum = ix.indkey[k - 1])
+				END
+				FROM generate_series(1, ix.indnkeyatts) AS k
+				ORDER BY k
+			) as column_names,
+			ix.indisunique as is_unique,
+			am.amname as method,
+			ARRAY(
+				SELECT pg_get_indexdef(ix.indexrelid, k, true)
+				FROM generate_series(1, ix.indnkeyatts) AS k
+				WHERE ix.indkey[k - 1] = 0
+				ORDER BY k
+			) as expressions,
+			ARRAY(
+				SELECT a.attname
+				FROM generate_series(ix.indnkeyatts + 1, ix.indnatts) AS k
+				JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = ix.indkey[k - 1]
+				ORDER BY k
+			) as include_columns,
+			COALESCE(pg_get_expr(ix.indpred, ix.indrelid, true), '') as predicate,
+			ix.indisvalid as is_valid,
+			pg_get_indexdef(ix.indexrelid, 0, true) as definition
+		FROM pg_index ix
+		JOIN pg_class i ON ix.indexrelid = i.oid
+		JOIN pg_am am ON am.oid = i.relam
+		WHERE ix.indrelid = ANY($1::oid[])
+		AND NOT ix.indisprimary
+		ORDER BY ix.indrelid, i.relname
+	`
+
+	rows, err := db.QueryContext(ctx, query, oids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relid uint32
+		var idx database.IndexSchema
+		if err := rows.Scan(&relid, &idx.Name, pq.Array(&idx.Columns), &idx.IsUnique, &idx.Method,
+			pq.Array(&idx.Expressions), pq.Array(&idx.Include), &idx.Predicate, &idx.IsValid, &idx.Definition); err != nil {
+			return err
+		}
+		if table, ok := tables[relid]; ok {
+			table.Indexes = append(table.Indexes, idx)
+		}
+	}
+
+	return rows.Err()
+}
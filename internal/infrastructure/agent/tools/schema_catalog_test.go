@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mololab/alodb/internal/domain/database"
+)
+
+// catalogRows are the rows a stubbed catalog query returns, in the order
+// PostgreSQL returns them for the query's ORDER BY
+type catalogRows struct {
+	marker string // text identifying the query
+	values [][]driver.Value
+	err    error
+}
+
+// stubCatalog is a database/sql connector answering the catalog queries of
+// getTableSchemas from memory
+type stubCatalog struct {
+	queries []catalogRows
+}
+
+func (c *stubCatalog) open(t testing.TB) *sql.DB {
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (c *stubCatalog) Connect(context.Context) (driver.Conn, error) { return stubConn{c}, nil }
+func (c *stubCatalog) Driver() driver.Driver                        { return c }
+func (c *stubCatalog) Open(string) (driver.Conn, error)             { return stubConn{c}, nil }
+
+type stubConn struct{ catalog *stubCatalog }
+
+func (stubConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (stubConn) Close() error                        { return nil }
+func (stubConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c stubConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	// the internal helpers of information_schema change between PostgreSQL versions
+	if strings.Contains(query, "information_schema._pg_") {
+		return nil, fmt.Errorf("query calls an internal information_schema function: %s", query)
+	}
+	for _, q := range c.catalog.queries {
+		if strings.Contains(query, q.marker) {
+			if q.err != nil {
+				return nil, q.err
+			}
+			return &stubRows{values: q.values}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected query: %s", query)
+}
+
+type stubRows struct {
+	values [][]driver.Value
+	next   int
+}
+
+func (r *stubRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *stubRows) Close() error { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.next == len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+// markers identify the query of each catalog reader
+const (
+	columnsQuery     = "format_type(a.atttypid, NULL) as data_type"
+	primaryKeysQuery = "AND i.indisprimary"
+	foreignKeysQuery = "con.contype = 'f'"
+	constraintsQuery = "con.contype IN ('c', 'u')"
+	indexesQuery     = "NOT ix.indisprimary"
+)
+
+func columnRow(relid int64, name, dataType string, nullable bool) []driver.Value {
+	return []driver.Value{relid, name, dataType, nullable, "", "", "", dataType, "b", "N", "", nil, nil, nil, "{}", "", "{}"}
+}
+
+func primaryKeyRow(relid int64, column string) []driver.Value {
+	return []driver.Value{relid, column}
+}
+
+func foreignKeyRow(relid int64, name, column, refTable, refColumn string) []driver.Value {
+	return []driver.Value{relid, name, column, "public", refTable, refColumn, "c", "a", false, false}
+}
+
+func constraintRow(relid int64, name, contype, columns, definition string) []driver.Value {
+	return []driver.Value{relid, name, contype, columns, definition, false, false, true}
+}
+
+func indexRow(relid int64, name, columns string, unique bool) []driver.Value {
+	return []driver.Value{relid, name, columns, unique, "btree", "{}", "{}", "", true, "CREATE INDEX " + name}
+}
+
+// orderingCatalog describes three tables whose oids are not in the order the
+// tables were listed in
+func orderingCatalog() ([]tableRef, *stubCatalog) {
+	refs := []tableRef{
+		{oid: 300, schema: "public", name: "orders", qualifiedName: "public.orders", kind: database.RelationTable},
+		{oid: 100, schema: "public", name: "customers", qualifiedName: "public.customers", kind: database.RelationTable},
+		{oid: 200, schema: "public", name: "order_items", qualifiedName: "public.order_items", kind: database.RelationTable},
+	}
+
+	return refs, &stubCatalog{queries: []catalogRows{
+		{marker: columnsQuery, values: [][]driver.Value{
+			columnRow(100, "id", "bigint", false),
+			columnRow(100, "email", "text", false),
+			columnRow(200, "order_id", "bigint", false),
+			columnRow(200, "line", "integer", false),
+			columnRow(200, "product_id", "bigint", false),
+			columnRow(300, "id", "bigint", false),
+			columnRow(300, "customer_id", "bigint", false),
+			columnRow(300, "created_at", "timestamp with time zone", true),
+			// a table that was not requested, e.g. listed by another session
+			columnRow(999, "id", "bigint", false),
+		}},
+		{marker: primaryKeysQuery, values: [][]driver.Value{
+			primaryKeyRow(100, "id"),
+			primaryKeyRow(200, "order_id"),
+			primaryKeyRow(200, "line"),
+			primaryKeyRow(300, "id"),
+		}},
+		{marker: foreignKeysQuery, values: [][]driver.Value{
+			foreignKeyRow(200, "order_items_order_fkey", "order_id", "orders", "id"),
+			foreignKeyRow(200, "order_items_product_fkey", "product_id", "products", "id"),
+			foreignKeyRow(200, "order_items_product_fkey", "line", "products", "line"),
+			foreignKeyRow(300, "orders_customer_fkey", "customer_id", "customers", "id"),
+		}},
+		{marker: constraintsQuery, values: [][]driver.Value{
+			constraintRow(100, "customers_email_key", "u", "{email}", "UNIQUE (email)"),
+			constraintRow(200, "order_items_line_check", "c", "{line}", "CHECK (line > 0)"),
+		}},
+		{marker: indexesQuery, values: [][]driver.Value{
+			indexRow(100, "customers_email_key", "{email}", true),
+			indexRow(300, "orders_created_at_idx", "{created_at}", false),
+			indexRow(300, "orders_customer_id_created_at_idx", "{customer_id,created_at}", false),
+		}},
+	}}
+}
+
+func TestGetTableSchemasOrder(t *testing.T) {
+	refs, catalog := orderingCatalog()
+	db := catalog.open(t)
+
+	tables, err := getTableSchemas(context.Background(), db, refs)
+	if err != nil {
+		t.Fatalf("getTableSchemas: %v", err)
+	}
+
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	if want := []string{"orders", "customers", "order_items"}; !slices.Equal(names, want) {
+		t.Fatalf("tables = %v, want the order of the refs %v", names, want)
+	}
+	orders, customers, items := tables[0], tables[1], tables[2]
+
+	var columns []string
+	for _, col := range orders.Columns {
+		columns = append(columns, col.Name)
+	}
+	if want := []string{"id", "customer_id", "created_at"}; !slices.Equal(columns, want) {
+		t.Errorf("orders columns = %v, want %v", columns, want)
+	}
+	if !slices.Equal(items.PrimaryKey, []string{"order_id", "line"}) {
+		t.Errorf("order_items primary key = %v", items.PrimaryKey)
+	}
+
+	if len(items.ForeignKeys) != 2 {
+		t.Fatalf("order_items foreign keys = %+v", items.ForeignKeys)
+	}
+	if fk := items.ForeignKeys[0]; fk.Name != "order_items_order_fkey" || !slices.Equal(fk.Columns, []string{"order_id"}) {
+		t.Errorf("foreign key 1 = %+v", fk)
+	}
+	fk := items.ForeignKeys[1]
+	if fk.Name != "order_items_product_fkey" || !slices.Equal(fk.Columns, []string{"product_id", "line"}) ||
+		!slices.Equal(fk.ReferencedColumn, []string{"id", "line"}) || fk.OnDelete != database.ActionCascade {
+		t.Errorf("foreign key 2 = %+v, want the columns paired in key order", fk)
+	}
+
+	var indexes []string
+	for _, idx := range orders.Indexes {
+		indexes = append(indexes, idx.Name)
+	}
+	if want := []string{"orders_created_at_idx", "orders_customer_id_created_at_idx"}; !slices.Equal(indexes, want) {
+		t.Errorf("orders indexes = %v, want %v", indexes, want)
+	}
+	if !slices.Equal(orders.Indexes[1].Columns, []string{"customer_id", "created_at"}) {
+		t.Errorf("index columns = %v", orders.Indexes[1].Columns)
+	}
+	if len(customers.Uniques) != 1 || len(items.Checks) != 1 || len(customers.Checks) != 0 {
+		t.Errorf("uniques = %+v, checks = %+v", customers.Uniques, items.Checks)
+	}
+
+	again, err := getTableSchemas(context.Background(), db, refs)
+	if err != nil || !reflect.DeepEqual(tables, again) {
+		t.Errorf("second read differs: %v", err)
+	}
+}
+
+func TestGetTableSchemasPartial(t *testing.T) {
+	refs, catalog := orderingCatalog()
+	catalog.queries[2].err = errors.New("canceling statement due to statement timeout")
+
+	tables, err := getTableSchemas(context.Background(), catalog.open(t), refs)
+
+	var partial *partialSchemaError
+	if !errors.As(err, &partial) {
+		t.Fatalf("err = %v, want a *partialSchemaError", err)
+	}
+	if want := []string{"foreign keys", "constraints", "indexes"}; !slices.Equal(partial.missing, want) {
+		t.Errorf("missing = %v, want %v", partial.missing, want)
+	}
+	if len(tables) != 3 || len(tables[0].Columns) != 3 || len(tables[2].PrimaryKey) != 2 {
+		t.Errorf("tables = %+v, want the columns and primary keys read before the failure", tables)
+	}
+	for _, table := range tables {
+		if table.ForeignKeys != nil || table.Indexes != nil {
+			t.Errorf("%s has details of the failed readers", table.Name)
+		}
+	}
+}
+
+// benchmarkCatalog stubs tables with 8 columns, a primary key, two foreign
+// keys, a unique constraint and three indexes each
+func benchmarkCatalog(tables int) ([]tableRef, *stubCatalog) {
+	refs := make([]tableRef, tables)
+	queries := []catalogRows{
+		{marker: columnsQuery},
+		{marker: primaryKeysQuery},
+		{marker: foreignKeysQuery},
+		{marker: constraintsQuery},
+		{marker: indexesQuery},
+	}
+
+	for i := range refs {
+		relid := int64(i + 1)
+		name := fmt.Sprintf("table_%04d", i)
+		refs[i] = tableRef{oid: uint32(relid), schema: "public", name: name, qualifiedName: "public." + name, kind: database.RelationTable}
+
+		for c := 0; c < 8; c++ {
+			queries[0].values = append(queries[0].values, columnRow(relid, fmt.Sprintf("column_%d", c), "integer", c > 0))
+		}
+		queries[1].values = append(queries[1].values, primaryKeyRow(relid, "column_0"))
+		queries[2].values = append(queries[2].values,
+			foreignKeyRow(relid, name+"_a_fkey", "column_1", "parent", "id"),
+			foreignKeyRow(relid, name+"_b_fkey", "column_2", "parent", "id"),
+			foreignKeyRow(relid, name+"_b_fkey", "column_3", "parent", "line"),
+		)
+		queries[3].values = append(queries[3].values, constraintRow(relid, name+"_key", "u", "{column_4}", "UNIQUE (column_4)"))
+		queries[4].values = append(queries[4].values,
+			indexRow(relid, name+"_a_idx", "{column_1}", false),
+			indexRow(relid, name+"_b_idx", "{column_2,column_3}", false),
+			indexRow(relid, name+"_key", "{column_4}", true),
+		)
+	}
+
+	return refs, &stubCatalog{queries: queries}
+}
+
+func BenchmarkGetTableSchemas(b *testing.B) {
+	// 500 tables are 8,000 catalog rows
+	refs, catalog := benchmarkCatalog(500)
+	db := catalog.open(b)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := getTableSchemas(context.Background(), db, refs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
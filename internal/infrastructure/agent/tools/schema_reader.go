@@ -1,28 +1,53 @@
 package tools
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/mololab/alodb/internal/domain/database"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
 	"github.com/mololab/alodb/pkg/logger"
 )
 
 // SchemaReaderInput represents the input for the schema reader tool
 type SchemaReaderInput struct{}
 
-// SchemaReaderOutput represents the output from the schema reader tool
+// Default schema extraction timeouts applied when the caller does not configure them
+const (
+	DefaultSchemaConnectTimeout   = 10 * time.Second
+	DefaultSchemaStatementTimeout = 30 * time.Second
+	DefaultSchemaTimeout          = 2 * time.Minute
+)
+
+// SchemaLimits bounds how long a schema extraction may take
+type SchemaLimits struct {
+	ConnectTimeout   time.Duration // connecting and authenticating
+	StatementTimeout time.Duration // each catalog query
+	TotalTimeout     time.Duration // the whole extraction including the connection
+}
+
+// SchemaReaderOutput represents the output from the schema reader tool.
+// A partial status carries the schema read before a timeout, Missing lists
+// the details left out for every table.
 type SchemaReaderOutput struct {
 	Status  string                   `json:"status"`
 	Schema  *database.DatabaseSchema `json:"schema,omitempty"`
+	Missing []string                 `json:"missing,omitempty"`
 	Message string                   `json:"message,omitempty"`
 }
 
-// ReadSchemaFromDatabase reads the database schema directly from PostgreSQL
-func ReadSchemaFromDatabase(connectionString string) (SchemaReaderOutput, error) {
+// ReadSchemaFromDatabase reads the schema of the schemas selected by the filter
+// directly from PostgreSQL, connecting through the connection policy. The
+// extraction stops when ctx is canceled or a timeout of limits fires.
+func ReadSchemaFromDatabase(ctx context.Context, policy *connection.Policy, connectionString string, filter database.SchemaFilter, limits SchemaLimits) (SchemaReaderOutput, error) {
 	if connectionString == "" {
 		return SchemaReaderOutput{
 			Status:  "error",
@@ -30,296 +55,303 @@ func ReadSchemaFromDatabase(connectionString string) (SchemaReaderOutput, error)
 		}, nil
 	}
 
-	ctx := context.Background()
+	limits = normalizeSchemaLimits(limits)
+	ctx, cancel := context.WithTimeout(ctx, limits.TotalTimeout)
+	defer cancel()
 
-	db, err := sql.Open("postgres", connectionString)
+	db, err := policy.Open(connectionString)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to open database")
 		return SchemaReaderOutput{
 			Status:  "error",
-			Message: fmt.Sprintf("failed to connect to database: %v", err),
+			Message: "failed to connect to database: " + logger.RedactError(err),
 		}, nil
 	}
 	defer db.Close()
 
-	if err := db.PingContext(ctx); err != nil {
+	if err := ping(ctx, db, limits.ConnectTimeout); err != nil {
 		logger.Error().Err(err).Msg("failed to ping database")
+		message := "failed to ping database: " + logger.RedactError(err)
+		if isTimeout(err) {
+			message = fmt.Sprintf("failed to connect to database within %s", limits.ConnectTimeout)
+		}
 		return SchemaReaderOutput{
 			Status:  "error",
-			Message: fmt.Sprintf("failed to ping database: %v", err),
+			Message: message,
 		}, nil
 	}
 
-	schema, err := extractPostgresSchema(ctx, db)
+	tx, err := beginReadOnly(ctx, db, limits.StatementTimeout)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to extract schema")
+		logger.Error().Err(err).Msg("failed to begin read-only transaction")
 		return SchemaReaderOutput{
 			Status:  "error",
-			Message: fmt.Sprintf("failed to extract schema: %v", err),
+			Message: logger.RedactError(err),
 		}, nil
 	}
+	// read-only work never needs to be committed
+	defer tx.Rollback()
 
-	logger.Info().Int("tables", len(schema.Tables)).Msg("schema extracted")
+	schema, err := extractPostgresSchema(ctx, tx, filter)
+	if err != nil {
+		return extractionFailure(schema, err, limits), nil
+	}
+
+	logger.Info().Int("schemas", len(schema.Schemas)).Int("tables", len(schema.Tables)).Msg("schema extracted")
 	return SchemaReaderOutput{
 		Status: "success",
 		Schema: schema,
 	}, nil
 }
 
-// GetSchemaAsJSON returns the schema as a formatted JSON string for LLM consumption
-func GetSchemaAsJSON(schema *database.DatabaseSchema) (string, error) {
-	data, err := json.MarshalIndent(schema, "", "  ")
-	if err != nil {
-		return "", err
+// extractionFailure reports a failed extraction. After a timeout the tables
+// read so far are returned as a partial schema.
+func extractionFailure(schema *database.DatabaseSchema, err error, limits SchemaLimits) SchemaReaderOutput {
+	if !isTimeout(err) {
+		logger.Error().Err(err).Msg("failed to extract schema")
+		return SchemaReaderOutput{
+			Status:  "error",
+			Message: "failed to extract schema: " + logger.RedactError(err),
+		}
 	}
-	return string(data), nil
-}
 
-// extractPostgresSchema extracts the complete schema from a PostgreSQL database
-func extractPostgresSchema(ctx context.Context, db *sql.DB) (*database.DatabaseSchema, error) {
-	schema := &database.DatabaseSchema{}
-
-	var dbName string
-	err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&dbName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get database name: %w", err)
-	}
-	schema.DatabaseName = dbName
+	logger.Warn().Err(err).Msg("schema extraction timed out")
+	limit := fmt.Sprintf("a catalog query exceeded %s or the extraction exceeded %s",
+		limits.StatementTimeout, limits.TotalTimeout)
 
-	tables, err := getTables(ctx, db)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get tables: %w", err)
+	var partial *partialSchemaError
+	if !errors.As(err, &partial) {
+		return SchemaReaderOutput{
+			Status:  "error",
+			Message: "schema extraction timed out before any table was read: " + limit,
+		}
 	}
 
-	for _, tableName := range tables {
-		tableSchema, err := getTableSchema(ctx, db, tableName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get schema for table %s: %w", tableName, err)
-		}
-		schema.Tables = append(schema.Tables, *tableSchema)
+	return SchemaReaderOutput{
+		Status:  "partial",
+		Schema:  schema,
+		Missing: partial.missing,
+		Message: fmt.Sprintf("Schema extraction timed out (%s). The schema is partial: %s are missing for every table. "+
+			"Do not assume they do not exist; ask the user to select fewer schemas if they are needed.",
+			limit, strings.Join(partial.missing, ", ")),
 	}
+}
 
-	return schema, nil
+// partialSchemaError reports an extraction that stopped after the tables were
+// listed, the schema returned with it holds the details read before
+type partialSchemaError struct {
+	missing []string
+	err     error
 }
 
-// getTables returns all user tables in the database
-func getTables(ctx context.Context, db *sql.DB) ([]string, error) {
-	query := `
-		SELECT table_name 
-		FROM information_schema.tables 
-		WHERE table_schema = 'public' 
-		AND table_type = 'BASE TABLE'
-		ORDER BY table_name
-	`
+func (e *partialSchemaError) Error() string { return e.err.Error() }
+func (e *partialSchemaError) Unwrap() error { return e.err }
 
-	rows, err := db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// ping connects to the database within the connect timeout
+func ping(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
 
-	var tables []string
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, err
-		}
-		tables = append(tables, tableName)
+// isTimeout reports whether err comes from a context deadline or a statement
+// timeout canceling a query
+func isTimeout(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "57014" {
+		return true
 	}
-
-	return tables, rows.Err()
+	return errors.Is(err, context.DeadlineExceeded)
 }
 
-// getTableSchema returns the schema for a specific table
-func getTableSchema(ctx context.Context, db *sql.DB, tableName string) (*database.TableSchema, error) {
-	tableSchema := &database.TableSchema{
-		Name: tableName,
+// normalizeSchemaLimits fills in defaults for unset timeouts
+func normalizeSchemaLimits(limits SchemaLimits) SchemaLimits {
+	if limits.ConnectTimeout <= 0 {
+		limits.ConnectTimeout = DefaultSchemaConnectTimeout
 	}
-
-	// columns
-	columns, err := getColumns(ctx, db, tableName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %w", err)
+	if limits.StatementTimeout <= 0 {
+		limits.StatementTimeout = DefaultSchemaStatementTimeout
 	}
-	tableSchema.Columns = columns
-
-	// primary key
-	primaryKey, err := getPrimaryKey(ctx, db, tableName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get primary key: %w", err)
+	if limits.TotalTimeout <= 0 {
+		limits.TotalTimeout = DefaultSchemaTimeout
 	}
-	tableSchema.PrimaryKey = primaryKey
+	return limits
+}
 
-	// foreign keys
-	foreignKeys, err := getForeignKeys(ctx, db, tableName)
+// GetSchemaAsJSON returns the schema as a formatted JSON string for LLM consumption
+func GetSchemaAsJSON(schema *database.DatabaseSchema) (string, error) {
+	data, err := json.MarshalIndent(schema, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get foreign keys: %w", err)
+		return "", err
 	}
-	tableSchema.ForeignKeys = foreignKeys
+	return string(data), nil
+}
 
-	// indexes
-	indexes, err := getIndexes(ctx, db, tableName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get indexes: %w", err)
-	}
-	tableSchema.Indexes = indexes
+// querier runs catalog queries, the extraction uses a read-only transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
-	return tableSchema, nil
+// tableRef identifies a relation selected for extraction
+type tableRef struct {
+	oid           uint32
+	schema        string
+	name          string
+	qualifiedName string
+	kind          database.RelationKind
+	definition    string
 }
 
-// getColumns returns all columns for a table
-func getColumns(ctx context.Context, db *sql.DB, tableName string) ([]database.ColumnSchema, error) {
-	query := `
-		SELECT 
-			column_name,
-			data_type,
-			is_nullable,
-			COALESCE(column_default, '') as column_default,
-			COALESCE(col_description((table_schema || '.' || table_name)::regclass::oid, ordinal_position), '') as column_comment
-		FROM information_schema.columns
-		WHERE table_schema = 'public' AND table_name = $1
-		ORDER BY ordinal_position
-	`
+// relationKinds maps pg_class.relkind to the relation kinds that are read.
+// Partitions are left out, they are queried through their partitioned table.
+var relationKinds = map[string]database.RelationKind{
+	"r": database.RelationTable,
+	"p": database.RelationPartitionedTable,
+	"v": database.RelationView,
+	"m": database.RelationMaterializedView,
+	"f": database.RelationForeignTable,
+}
+
+// extractPostgresSchema extracts the schema of the tables in the schemas selected
+// by the filter. The number of queries does not depend on the number of tables.
+// Once the tables are listed, a failure returns a *partialSchemaError together
+// with the schema read so far.
+func extractPostgresSchema(ctx context.Context, db querier, filter database.SchemaFilter) (*database.DatabaseSchema, error) {
+	schema := &database.DatabaseSchema{}
 
-	rows, err := db.QueryContext(ctx, query, tableName)
+	var dbName string
+	err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&dbName)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get database name: %w", err)
 	}
-	defer rows.Close()
+	schema.DatabaseName = dbName
 
-	var columns []database.ColumnSchema
-	for rows.Next() {
-		var col database.ColumnSchema
-		var isNullable string
-		if err := rows.Scan(&col.Name, &col.DataType, &isNullable, &col.Default, &col.Comment); err != nil {
-			return nil, err
-		}
-		col.IsNullable = isNullable == "YES"
-		columns = append(columns, col)
+	// current_schemas reflects a search_path set in the connection string or for the role
+	var searchPath []string
+	err = db.QueryRowContext(ctx, "SELECT current_schemas(false)").Scan(pq.Array(&searchPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search path: %w", err)
 	}
+	schema.SearchPath = searchPath
 
-	return columns, rows.Err()
-}
+	schemas, err := getSchemas(ctx, db, filter, searchPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schemas: %w", err)
+	}
+	schema.Schemas = schemas
 
-// getPrimaryKey returns the primary key columns for a table
-func getPrimaryKey(ctx context.Context, db *sql.DB, tableName string) ([]string, error) {
-	query := `
-		SELECT a.attname
-		FROM pg_index i
-		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
-		WHERE i.indrelid = ('public.' || $1)::regclass
-		AND i.indisprimary
-		ORDER BY array_position(i.indkey, a.attnum)
-	`
+	tables, err := getTables(ctx, db, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
 
-	rows, err := db.QueryContext(ctx, query, tableName)
+	schema.Tables, err = getTableSchemas(ctx, db, tables)
 	if err != nil {
-		return nil, err
+		var partial *partialSchemaError
+		if errors.As(err, &partial) {
+			partial.missing = append(partial.missing, "types")
+		}
+		return schema, err
 	}
-	defer rows.Close()
 
-	var columns []string
-	for rows.Next() {
-		var colName string
-		if err := rows.Scan(&colName); err != nil {
-			return nil, err
+	types, err := getTypes(ctx, db, schemas)
+	if err != nil {
+		return schema, &partialSchemaError{
+			missing: []string{"types"},
+			err:     fmt.Errorf("failed to get types: %w", err),
 		}
-		columns = append(columns, colName)
 	}
+	schema.Types = types
 
-	return columns, rows.Err()
+	return schema, nil
 }
 
-// getForeignKeys returns foreign key constraints for a table
-func getForeignKeys(ctx context.Context, db *sql.DB, tableName string) ([]database.ForeignKey, error) {
+// getSchemas returns the user schemas selected by the filter, those on the
+// search path first in search path order, then the rest by name
+func getSchemas(ctx context.Context, db querier, filter database.SchemaFilter, searchPath []string) ([]string, error) {
 	query := `
-		SELECT
-			tc.constraint_name,
-			kcu.column_name,
-			ccu.table_name AS foreign_table_name,
-			ccu.column_name AS foreign_column_name
-		FROM information_schema.table_constraints AS tc
-		JOIN information_schema.key_column_usage AS kcu
-			ON tc.constraint_name = kcu.constraint_name
-			AND tc.table_schema = kcu.table_schema
-		JOIN information_schema.constraint_column_usage AS ccu
-			ON ccu.constraint_name = tc.constraint_name
-			AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY'
-		AND tc.table_schema = 'public'
-		AND tc.table_name = $1
-		ORDER BY tc.constraint_name, kcu.ordinal_position
+		SELECT nspname
+		FROM pg_namespace
+		WHERE nspname <> 'information_schema'
+		AND nspname NOT LIKE 'pg\_%'
+		AND has_schema_privilege(oid, 'USAGE')
+		ORDER BY nspname
 	`
 
-	rows, err := db.QueryContext(ctx, query, tableName)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	fkMap := make(map[string]*database.ForeignKey)
+	var schemas []string
 	for rows.Next() {
-		var constraintName, colName, refTable, refCol string
-		if err := rows.Scan(&constraintName, &colName, &refTable, &refCol); err != nil {
+		var name string
+		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
-
-		if fk, ok := fkMap[constraintName]; ok {
-			fk.Columns = append(fk.Columns, colName)
-			fk.ReferencedColumn = append(fk.ReferencedColumn, refCol)
-		} else {
-			fkMap[constraintName] = &database.ForeignKey{
-				Name:             constraintName,
-				Columns:          []string{colName},
-				ReferencedTable:  refTable,
-				ReferencedColumn: []string{refCol},
-			}
+		if filter.Matches(name) {
+			schemas = append(schemas, name)
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	var foreignKeys []database.ForeignKey
-	for _, fk := range fkMap {
-		foreignKeys = append(foreignKeys, *fk)
+	rank := func(name string) int {
+		if i := slices.Index(searchPath, name); i >= 0 {
+			return i
+		}
+		return len(searchPath)
 	}
+	slices.SortStableFunc(schemas, func(a, b string) int {
+		return cmp.Compare(rank(a), rank(b))
+	})
 
-	return foreignKeys, rows.Err()
+	return schemas, nil
 }
 
-// getIndexes returns all indexes for a table
-func getIndexes(ctx context.Context, db *sql.DB, tableName string) ([]database.IndexSchema, error) {
+// getTables returns the tables, views and foreign tables of the schemas, in
+// schema order and then by name
+func getTables(ctx context.Context, db querier, schemas []string) ([]tableRef, error) {
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+
 	query := `
 		SELECT
-			i.relname as index_name,
-			array_agg(a.attname ORDER BY array_position(ix.indkey, a.attnum)) as column_names,
-			ix.indisunique as is_unique
-		FROM pg_index ix
-		JOIN pg_class i ON ix.indexrelid = i.oid
-		JOIN pg_class t ON ix.indrelid = t.oid
-		JOIN pg_namespace n ON t.relnamespace = n.oid
-		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
-		WHERE t.relname = $1
-		AND n.nspname = 'public'
-		AND NOT ix.indisprimary
-		GROUP BY i.relname, ix.indisunique
-		ORDER BY i.relname
+			c.oid,
+			n.nspname,
+			c.relname,
+			quote_ident(n.nspname) || '.' || quote_ident(c.relname),
+			c.relkind,
+			CASE WHEN c.relkind IN ('v', 'm') THEN pg_get_viewdef(c.oid, true) ELSE '' END
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = ANY($1::text[])
+		AND c.relkind IN ('r', 'p', 'v', 'm', 'f')
+		AND NOT c.relispartition
+		AND has_table_privilege(c.oid, 'SELECT, INSERT, UPDATE, DELETE, TRUNCATE, REFERENCES, TRIGGER')
+		ORDER BY array_position($1::text[], n.nspname::text), c.relname
 	`
 
-	rows, err := db.QueryContext(ctx, query, tableName)
+	rows, err := db.QueryContext(ctx, query, pq.Array(schemas))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var indexes []database.IndexSchema
+	var tables []tableRef
 	for rows.Next() {
-		var idx database.IndexSchema
-		var columns []string
-		if err := rows.Scan(&idx.Name, pq.Array(&columns), &idx.IsUnique); err != nil {
+		var table tableRef
+		var relkind string
+		if err := rows.Scan(&table.oid, &table.schema, &table.name, &table.qualifiedName, &relkind, &table.definition); err != nil {
 			return nil, err
 		}
-		idx.Columns = columns
-		indexes = append(indexes, idx)
+		table.kind = relationKinds[relkind]
+		tables = append(tables, table)
 	}
 
-	return indexes, rows.Err()
+	return tables, rows.Err()
 }
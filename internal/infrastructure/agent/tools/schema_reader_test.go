@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mololab/alodb/internal/domain/database"
+)
+
+// typesQuery identifies the query of getTypes
+const typesQuery = "WITH used AS"
+
+// extractionCatalog stubs the queries extractPostgresSchema runs before the
+// table details, with the ordering catalog's tables in the public schema
+func extractionCatalog() *stubCatalog {
+	refs, catalog := orderingCatalog()
+
+	var tables [][]driver.Value
+	for _, ref := range refs {
+		tables = append(tables, []driver.Value{int64(ref.oid), ref.schema, ref.name, ref.qualifiedName, "r", ""})
+	}
+	catalog.queries = append(catalog.queries,
+		catalogRows{marker: typesQuery},
+		catalogRows{marker: "current_database()", values: [][]driver.Value{{"shop"}}},
+		catalogRows{marker: "current_schemas(false)", values: [][]driver.Value{{"{public}"}}},
+		catalogRows{marker: "FROM pg_namespace\n", values: [][]driver.Value{{"audit"}, {"public"}}},
+		catalogRows{marker: "FROM pg_class c", values: tables},
+	)
+	return catalog
+}
+
+func TestExtractPostgresSchemaPartial(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing string // marker of the failing query
+		missing []string
+	}{
+		{"types", typesQuery, []string{"types"}},
+		{"indexes", indexesQuery, []string{"indexes", "types"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := extractionCatalog()
+			for i := range catalog.queries {
+				if catalog.queries[i].marker == tt.failing {
+					catalog.queries[i].err = &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"}
+				}
+			}
+
+			schema, err := extractPostgresSchema(context.Background(), catalog.open(t), database.SchemaFilter{})
+
+			var partial *partialSchemaError
+			if !errors.As(err, &partial) {
+				t.Fatalf("err = %v, want a *partialSchemaError", err)
+			}
+			if !slices.Equal(partial.missing, tt.missing) {
+				t.Errorf("missing = %v, want %v", partial.missing, tt.missing)
+			}
+			if schema.DatabaseName != "shop" || !slices.Equal(schema.Schemas, []string{"public", "audit"}) || len(schema.Tables) != 3 {
+				t.Errorf("schema = %+v, want the tables read before the failure", schema)
+			}
+
+			out := extractionFailure(schema, err, normalizeSchemaLimits(SchemaLimits{}))
+			if out.Status != "partial" || !slices.Equal(out.Missing, tt.missing) {
+				t.Errorf("output = %+v, want a partial schema", out)
+			}
+		})
+	}
+}
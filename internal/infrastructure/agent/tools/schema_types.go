@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/mololab/alodb/internal/domain/database"
+)
+
+// typeKinds maps pg_type.typtype to type kinds
+var typeKinds = map[string]database.TypeKind{
+	"b": database.TypeKindBase,
+	"c": database.TypeKindComposite,
+	"d": database.TypeKindDomain,
+	"e": database.TypeKindEnum,
+	"r": database.TypeKindRange,
+	"m": database.TypeKindMultirange,
+	"p": database.TypeKindPseudo,
+}
+
+// typeKind returns the kind of a type, arrays are base types of category A
+func typeKind(typtype, typcategory string) database.TypeKind {
+	if typtype == "b" && typcategory == "A" {
+		return database.TypeKindArray
+	}
+	if kind, ok := typeKinds[typtype]; ok {
+		return kind
+	}
+	return database.TypeKindBase
+}
+
+// getTypes returns the enum, domain, composite and range types defined in the
+// schemas or used by their columns, directly or as array element or domain
+// base type, ordered by schema and name
+func getTypes(ctx context.Context, db querier, schemas []string) ([]database.TypeSchema, error) {
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		WITH used AS (
+			SELECT t.oid, t.typelem, t.typbasetype
+			FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			JOIN pg_type t ON t.oid = a.atttypid
+			WHERE n.nspname = ANY($1::text[])
+			AND c.relkind IN ('r', 'p', 'v', 'm', 'f')
+			AND a.attnum > 0
+			AND NOT a.attisdropped
+		), used_oids AS (
+			SELECT oid FROM used
+			UNION SELECT typelem FROM used WHERE typelem <> 0
+			UNION SELECT typbasetype FROM used WHERE typbasetype <> 0
+		)
+		SELECT
+			n.nspname,
+			t.typname,
+			quote_ident(n.nspname) || '.' || quote_ident(t.typname),
+			t.typtype,
+			ARRAY(
+				SELECT e.enumlabel
+				FROM pg_enum e
+				WHERE e.enumtypid = t.oid
+				ORDER BY e.enumsortorder
+			),
+			CASE t.typtype
+				WHEN 'd' THEN format_type(t.typbasetype, t.typtypmod)
+				WHEN 'r' THEN format_type(rng.rngsubtype, NULL)
+				ELSE ''
+			END,
+			t.typnotnull,
+			COALESCE(t.typdefault, ''),
+			ARRAY(
+				SELECT pg_get_constraintdef(con.oid, true)
+				FROM pg_constraint con
+				WHERE con.contypid = t.oid
+				ORDER BY con.conname
+			),
+			ARRAY(
+				SELECT a.attname
+				FROM pg_attribute a
+				WHERE a.attrelid = t.typrelid AND a.attnum > 0 AND NOT a.attisdropped
+				ORDER BY a.attnum
+			),
+			ARRAY(
+				SELECT format_type(a.atttypid, a.atttypmod)
+				FROM pg_attribute a
+				WHERE a.attrelid = t.typrelid AND a.attnum > 0 AND NOT a.attisdropped
+				ORDER BY a.attnum
+			),
+			COALESCE(obj_description(t.oid, 'pg_type'), '')
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		LEFT JOIN pg_class r ON r.oid = t.typrelid
+		LEFT JOIN pg_range rng ON rng.rngtypid = t.oid
+		WHERE t.typtype IN ('e', 'd', 'c', 'r')
+		AND (t.typtype <> 'c' OR r.relkind = 'c')
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND (n.nspname = ANY($1::text[]) OR t.oid IN (SELECT oid FROM used_oids))
+		ORDER BY n.nspname, t.typname
+	`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(schemas))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var types []database.TypeSchema
+	for rows.Next() {
+		var typ database.TypeSchema
+		var typtype string
+		var attrNames, attrTypes []string
+		if err := rows.Scan(
+			&typ.Schema, &typ.Name, &typ.QualifiedName, &typtype,
+			pq.Array(&typ.Labels), &typ.BaseType, &typ.NotNull, &typ.Default,
+			pq.Array(&typ.Constraints), pq.Array(&attrNames), pq.Array(&attrTypes), &typ.Comment,
+		); err != nil {
+			return nil, err
+		}
+		typ.Kind = typeKind(typtype, "")
+		for i, name := range attrNames {
+			typ.Attributes = append(typ.Attributes, database.TypeAttribute{Name: name, DataType: attrTypes[i]})
+		}
+		types = append(types, typ)
+	}
+
+	return types, rows.Err()
+}
+
+func nullInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
@@ -3,6 +3,9 @@ package agent
 import (
 	"time"
 
+	"github.com/mololab/alodb/internal/infrastructure/agent/tools"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
+
 	"google.golang.org/adk/agent"
 	"google.golang.org/adk/runner"
 	"google.golang.org/adk/session"
@@ -13,6 +16,10 @@ type contextKey string
 const (
 	connectionStringKey contextKey = "db_connection_string"
 	schemaCacheTTLKey   contextKey = "schema_cache_ttl"
+	queryLimitsKey      contextKey = "query_limits"
+	policyKey           contextKey = "connection_policy"
+	schemaFilterKey     contextKey = "schema_filter"
+	schemaLimitsKey     contextKey = "schema_limits"
 )
 
 type DBAgent struct {
@@ -21,4 +28,7 @@ type DBAgent struct {
 	sessionService session.Service
 	modelSlug      string
 	schemaCacheTTL time.Duration
+	queryLimits    tools.QueryLimits
+	schemaLimits   tools.SchemaLimits
+	policy         *connection.Policy
 }
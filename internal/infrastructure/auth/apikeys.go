@@ -0,0 +1,196 @@
+// Package auth authenticates API callers.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// KeyPrefix starts every API key so leaked keys are easy to recognize
+	KeyPrefix = "alodb_"
+	keyBytes  = 32
+	// displayPrefixLength is the number of leading characters kept to identify a key
+	displayPrefixLength = len(KeyPrefix) + 6
+	// lastUsedInterval limits how often last_used_at is written for a key
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// apiKeyRecord is the stored form of an API key, only the key hash is kept
+type apiKeyRecord struct {
+	ID         string `gorm:"primaryKey;size:36"`
+	Name       string `gorm:"size:255;not null"`
+	Prefix     string `gorm:"size:32;not null"`
+	Hash       string `gorm:"size:64;not null;uniqueIndex"`
+	UserID     string `gorm:"size:255;not null;index"`
+	Scopes     string `gorm:"size:255;not null"`
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (apiKeyRecord) TableName() string {
+	return "api_keys"
+}
+
+// APIKeyRepository stores hashed API keys
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a repository and migrates its table
+func NewAPIKeyRepository(db *gorm.DB) (*APIKeyRepository, error) {
+	if err := db.AutoMigrate(&apiKeyRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate API keys: %w", err)
+	}
+	return &APIKeyRepository{db: db}, nil
+}
+
+// Create issues a new key and returns it together with its description.
+// The key is not stored and cannot be recovered later.
+func (r *APIKeyRepository) Create(ctx context.Context, req domainAuth.CreateAPIKeyRequest) (string, *domainAuth.APIKey, error) {
+	if len(req.Scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !domainAuth.IsValidScope(scope) {
+			return "", nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+
+	random := make([]byte, keyBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := KeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	record := apiKeyRecord{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Prefix:    key[:displayPrefixLength],
+		Hash:      hashKey(key),
+		UserID:    req.UserID,
+		Scopes:    joinScopes(req.Scopes),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := r.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	return key, toAPIKey(&record), nil
+}
+
+// Authenticate returns the principal of a valid, unrevoked key
+func (r *APIKeyRepository) Authenticate(ctx context.Context, key string) (*domainAuth.Principal, error) {
+	if !strings.HasPrefix(key, KeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var record apiKeyRecord
+	err := r.db.WithContext(ctx).
+		Where("hash = ? AND revoked_at IS NULL", hashKey(key)).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= lastUsedInterval {
+		// usage tracking is best effort and must not fail the request
+		r.db.WithContext(ctx).Model(&apiKeyRecord{}).
+			Where("id = ?", record.ID).
+			Update("last_used_at", now)
+	}
+
+	return &domainAuth.Principal{
+		UserID: record.UserID,
+		KeyID:  record.ID,
+		Scopes: splitScopes(record.Scopes),
+	}, nil
+}
+
+// List returns every key, newest first
+func (r *APIKeyRepository) List(ctx context.Context) ([]domainAuth.APIKey, error) {
+	var records []apiKeyRecord
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	keys := make([]domainAuth.APIKey, len(records))
+	for i := range records {
+		keys[i] = *toAPIKey(&records[i])
+	}
+	return keys, nil
+}
+
+// Revoke disables a key, revoked keys are kept for auditing
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Model(&apiKeyRecord{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke API key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// hashKey returns the hex SHA-256 of a key. Keys are random, so a plain hash
+// is enough to make the stored value useless for authentication.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func joinScopes(scopes []domainAuth.Scope) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitScopes(s string) []domainAuth.Scope {
+	var scopes []domainAuth.Scope
+	for _, part := range strings.Split(s, ",") {
+		if part != "" {
+			scopes = append(scopes, domainAuth.Scope(part))
+		}
+	}
+	return scopes
+}
+
+func toAPIKey(record *apiKeyRecord) *domainAuth.APIKey {
+	return &domainAuth.APIKey{
+		ID:         record.ID,
+		Name:       record.Name,
+		Prefix:     record.Prefix,
+		UserID:     record.UserID,
+		Scopes:     splitScopes(record.Scopes),
+		CreatedAt:  record.CreatedAt,
+		LastUsedAt: record.LastUsedAt,
+		RevokedAt:  record.RevokedAt,
+	}
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	"github.com/mololab/alodb/internal/infrastructure/storage"
+)
+
+func newTestAPIKeyRepository(t *testing.T) *APIKeyRepository {
+	t.Helper()
+
+	store, err := storage.Open(storage.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	repo, err := NewAPIKeyRepository(store.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestAPIKeyRepositoryCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []domainAuth.Scope
+		err    string
+	}{
+		{"one scope", []domainAuth.Scope{domainAuth.ScopeChat}, ""},
+		{"several scopes", []domainAuth.Scope{domainAuth.ScopeChat, domainAuth.ScopeExecute}, ""},
+		{"no scopes", nil, "at least one scope is required"},
+		{"unknown scope", []domainAuth.Scope{domainAuth.ScopeChat, "root"}, "unknown scope: root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestAPIKeyRepository(t)
+			key, apiKey, err := repo.Create(context.Background(), domainAuth.CreateAPIKeyRequest{
+				Name:   "ci",
+				UserID: "user-1",
+				Scopes: tt.scopes,
+			})
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if !strings.HasPrefix(key, KeyPrefix) || !strings.HasPrefix(key, apiKey.Prefix) || len(apiKey.Prefix) != displayPrefixLength {
+				t.Errorf("key = %q, prefix = %q", key, apiKey.Prefix)
+			}
+			if !slices.Equal(apiKey.Scopes, tt.scopes) {
+				t.Errorf("scopes = %v, want %v", apiKey.Scopes, tt.scopes)
+			}
+
+			// only the hash of the key is stored
+			var record apiKeyRecord
+			if err := repo.db.First(&record, "id = ?", apiKey.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if record.Hash != hashKey(key) || strings.Contains(record.Hash, key) {
+				t.Errorf("hash = %q, want the SHA-256 of the key", record.Hash)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRepositoryAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAPIKeyRepository(t)
+
+	create := func(scopes ...domainAuth.Scope) (string, *domainAuth.APIKey) {
+		key, apiKey, err := repo.Create(ctx, domainAuth.CreateAPIKeyRequest{Name: "ci", UserID: "user-1", Scopes: scopes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, apiKey
+	}
+	key, apiKey := create(domainAuth.ScopeChat, domainAuth.ScopeExecute)
+	revoked, revokedKey := create(domainAuth.ScopeAdmin)
+	if err := repo.Revoke(ctx, revokedKey.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		scopes []domainAuth.Scope
+		err    error
+	}{
+		{"valid", key, []domainAuth.Scope{domainAuth.ScopeChat, domainAuth.ScopeExecute}, nil},
+		{"revoked", revoked, nil, ErrInvalidAPIKey},
+		{"unknown", KeyPrefix + "unknown", nil, ErrInvalidAPIKey},
+		{"altered", key[:len(key)-1] + "x", nil, ErrInvalidAPIKey},
+		// a stored hash is not a credential
+		{"hash", hashKey(key), nil, ErrInvalidAPIKey},
+		{"without prefix", strings.TrimPrefix(key, KeyPrefix), nil, ErrInvalidAPIKey},
+		{"empty", "", nil, ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := repo.Authenticate(ctx, tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if principal.UserID != "user-1" || principal.KeyID != apiKey.ID || !slices.Equal(principal.Scopes, tt.scopes) {
+				t.Errorf("principal = %+v", principal)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRepositoryLastUsed(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAPIKeyRepository(t)
+
+	key, apiKey, err := repo.Create(ctx, domainAuth.CreateAPIKeyRequest{Name: "ci", UserID: "user-1", Scopes: []domainAuth.Scope{domainAuth.ScopeChat}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUsed := func() *time.Time {
+		t.Helper()
+		var record apiKeyRecord
+		if err := repo.db.First(&record, "id = ?", apiKey.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return record.LastUsedAt
+	}
+
+	if lastUsed() != nil {
+		t.Fatal("last used is set before the key was used")
+	}
+	if _, err := repo.Authenticate(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	first := lastUsed()
+	if first == nil {
+		t.Fatal("last used is not set after authenticating")
+	}
+
+	// within lastUsedInterval the timestamp is not rewritten
+	if _, err := repo.Authenticate(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if second := lastUsed(); !second.Equal(*first) {
+		t.Errorf("last used = %v, want %v", second, first)
+	}
+}
+
+func TestAPIKeyRepositoryRevoke(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAPIKeyRepository(t)
+
+	_, apiKey, err := repo.Create(ctx, domainAuth.CreateAPIKeyRequest{Name: "ci", UserID: "user-1", Scopes: []domainAuth.Scope{domainAuth.ScopeChat}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		err  error
+	}{
+		{"active", apiKey.ID, nil},
+		{"already revoked", apiKey.ID, ErrAPIKeyNotFound},
+		{"unknown", "00000000-0000-0000-0000-000000000000", ErrAPIKeyNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.Revoke(ctx, tt.id); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// revoked keys stay listed for auditing
+	keys, err := repo.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("keys = %+v, want the revoked key", keys)
+	}
+}
+
+func TestSplitScopes(t *testing.T) {
+	tests := []struct {
+		stored string
+		want   []domainAuth.Scope
+	}{
+		{"", nil},
+		{"chat", []domainAuth.Scope{domainAuth.ScopeChat}},
+		{"chat,execute", []domainAuth.Scope{domainAuth.ScopeChat, domainAuth.ScopeExecute}},
+		{",admin,,", []domainAuth.Scope{domainAuth.ScopeAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stored, func(t *testing.T) {
+			if got := splitScopes(tt.stored); !slices.Equal(got, tt.want) {
+				t.Errorf("scopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
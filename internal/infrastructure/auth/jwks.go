@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+const (
+	// jwksTTL is how long keys fetched from a URL are used before refetching
+	jwksTTL = time.Hour
+	// jwksMinRefresh limits refetches triggered by unknown key IDs
+	jwksMinRefresh = time.Minute
+	jwksTimeout    = 10 * time.Second
+	// maxJWKSSize bounds the size of a fetched key set or discovery document
+	maxJWKSSize = 1 << 20
+)
+
+var errUnknownSigningKey = errors.New("unknown signing key")
+
+// jsonWebKey is a public key of a JWKS document (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// signingKey is a parsed verification key
+type signingKey struct {
+	id  string
+	alg string // empty when the key does not restrict the algorithm
+	key crypto.PublicKey
+}
+
+// keySet holds the verification keys of a JWKS file or URL. Keys from a URL
+// are refetched when they expire or a token names an unknown key.
+type keySet struct {
+	file       string
+	url        string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      []signingKey
+	fetchedAt time.Time
+}
+
+func newKeySet(file, url string, httpClient *http.Client) (*keySet, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: jwksTimeout}
+	}
+	ks := &keySet{file: file, url: url, httpClient: httpClient}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS file: %w", err)
+		}
+		ks.keys = keys
+		return ks, nil
+	}
+
+	// an identity provider that is briefly down must not stop the server,
+	// keys are fetched again on first use
+	if err := ks.refresh(context.Background()); err != nil {
+		logger.Warn().Err(err).Str("url", url).Msg("failed to fetch JWKS")
+	}
+	return ks, nil
+}
+
+// find returns the key for a token header, refreshing URL keys when needed
+func (ks *keySet) find(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.url != "" && time.Since(ks.fetchedAt) > jwksTTL {
+		if err := ks.refreshLocked(ctx); err != nil {
+			logger.Warn().Err(err).Msg("failed to refresh JWKS, using cached keys")
+		}
+	}
+
+	if key, ok := ks.match(kid, alg); ok {
+		return key, nil
+	}
+
+	if ks.url == "" || time.Since(ks.fetchedAt) < jwksMinRefresh {
+		return nil, errUnknownSigningKey
+	}
+	if err := ks.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := ks.match(kid, alg); ok {
+		return key, nil
+	}
+	return nil, errUnknownSigningKey
+}
+
+// match finds a key by ID, or the only key usable for the algorithm when the token has no key ID
+func (ks *keySet) match(kid, alg string) (crypto.PublicKey, bool) {
+	var found crypto.PublicKey
+	matches := 0
+	for _, k := range ks.keys {
+		if k.alg != "" && k.alg != alg {
+			continue
+		}
+		if kid != "" {
+			if k.id == kid {
+				return k.key, true
+			}
+			continue
+		}
+		found = k.key
+		matches++
+	}
+	return found, matches == 1
+}
+
+func (ks *keySet) refresh(ctx context.Context) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.refreshLocked(ctx)
+}
+
+func (ks *keySet) refreshLocked(ctx context.Context) error {
+	// failed fetches count too, so an unreachable provider is not hammered
+	ks.fetchedAt = time.Now()
+
+	data, err := fetch(ctx, ks.httpClient, ks.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	ks.keys = keys
+	return nil
+}
+
+// DiscoverJWKSURL reads the jwks_uri from the OpenID Connect discovery document of an issuer
+func DiscoverJWKSURL(ctx context.Context, issuer string, httpClient *http.Client) (string, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: jwksTimeout}
+	}
+
+	data, err := fetch(ctx, httpClient, strings.TrimRight(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("OpenID discovery failed: %w", err)
+	}
+
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("invalid OpenID discovery document: %w", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OpenID discovery document has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+func fetch(ctx context.Context, httpClient *http.Client, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, jwksTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS parses the RSA and EC signature keys of a JWKS document, other keys are skipped
+func parseJWKS(data []byte) ([]signingKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var keys []signingKey
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = rsaKey(jwk)
+		case "EC":
+			key, err = ecKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+
+		keys = append(keys, signingKey{id: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA or EC signature keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := decodeBigInt(jwk.E)
+	if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+	if n.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA keys must have at least 2048 bits")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func ecKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+
+	x, err := decodeBigInt(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x coordinate: %w", err)
+	}
+	y, err := decodeBigInt(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y coordinate: %w", err)
+	}
+
+	key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	if _, err := key.ECDH(); err != nil {
+		return nil, fmt.Errorf("point is not on the curve")
+	}
+	return key, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+)
+
+const (
+	// clockSkew tolerates clock differences with the identity provider
+	clockSkew = time.Minute
+	// ScopePrefix marks token scopes granting AloDB scopes, e.g. "alodb:execute"
+	ScopePrefix = "alodb:"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenConfig configures JWT validation. One of JWKSFile, JWKSURL or Issuer,
+// used for OpenID Connect discovery, is required.
+type TokenConfig struct {
+	JWKSFile string
+	JWKSURL  string
+	Issuer   string
+	Audience string
+	// DefaultScopes are granted to every valid token
+	DefaultScopes []domainAuth.Scope
+	HTTPClient    *http.Client
+}
+
+// TokenValidator authenticates JWT bearer tokens signed by keys of a JWKS
+type TokenValidator struct {
+	keys          *keySet
+	issuer        string
+	audience      string
+	defaultScopes []domainAuth.Scope
+}
+
+// NewTokenValidator loads the signing keys and creates a validator
+func NewTokenValidator(ctx context.Context, cfg TokenConfig) (*TokenValidator, error) {
+	url := cfg.JWKSURL
+	if cfg.JWKSFile == "" && url == "" {
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("a JWKS file, JWKS URL or issuer is required")
+		}
+		discovered, err := DiscoverJWKSURL(ctx, cfg.Issuer, cfg.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+		url = discovered
+	}
+
+	keys, err := newKeySet(cfg.JWKSFile, url, cfg.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenValidator{
+		keys:          keys,
+		issuer:        cfg.Issuer,
+		audience:      cfg.Audience,
+		defaultScopes: cfg.DefaultScopes,
+	}, nil
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Subject   string       `json:"sub"`
+	Issuer    string       `json:"iss"`
+	Audience  audience     `json:"aud"`
+	ExpiresAt *json.Number `json:"exp"`
+	NotBefore *json.Number `json:"nbf"`
+	Scope     string       `json:"scope"`
+	Scp       scopeList    `json:"scp"`
+}
+
+// Authenticate verifies the token signature and claims and returns its
+// principal, the user is the sub claim
+func (v *TokenValidator) Authenticate(ctx context.Context, token string) (*domainAuth.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+
+	hash, ok := signatureHash(header.Alg)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	key, err := v.keys.find(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if err := verifySignature(header.Alg, hash, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if err := v.validateClaims(&claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return &domainAuth.Principal{
+		UserID: claims.Subject,
+		Scopes: v.scopes(&claims),
+	}, nil
+}
+
+// validateClaims checks the subject, expiry, issuer and audience
+func (v *TokenValidator) validateClaims(claims *tokenClaims, now time.Time) error {
+	if claims.Subject == "" {
+		return fmt.Errorf("missing sub claim")
+	}
+
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf("missing exp claim")
+	}
+	exp, err := numericDate(*claims.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("invalid exp claim")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+
+	if claims.NotBefore != nil {
+		nbf, err := numericDate(*claims.NotBefore)
+		if err != nil {
+			return fmt.Errorf("invalid nbf claim")
+		}
+		if now.Add(clockSkew).Before(nbf) {
+			return fmt.Errorf("token not valid yet")
+		}
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return fmt.Errorf("unexpected issuer")
+	}
+	if v.audience != "" && !slices.Contains(claims.Audience, v.audience) {
+		return fmt.Errorf("unexpected audience")
+	}
+	return nil
+}
+
+// scopes returns the default scopes plus the AloDB scopes named in the token
+func (v *TokenValidator) scopes(claims *tokenClaims) []domainAuth.Scope {
+	scopes := slices.Clone(v.defaultScopes)
+
+	names := append(strings.Fields(claims.Scope), claims.Scp...)
+	for _, name := range names {
+		scope, ok := strings.CutPrefix(name, ScopePrefix)
+		if !ok || !domainAuth.IsValidScope(domainAuth.Scope(scope)) {
+			continue
+		}
+		if !slices.Contains(scopes, domainAuth.Scope(scope)) {
+			scopes = append(scopes, domainAuth.Scope(scope))
+		}
+	}
+	return scopes
+}
+
+// signatureHash returns the hash of a supported algorithm. Symmetric
+// algorithms and "none" are not supported.
+func signatureHash(alg string) (crypto.Hash, bool) {
+	switch alg {
+	case "RS256", "ES256":
+		return crypto.SHA256, true
+	case "RS384", "ES384":
+		return crypto.SHA384, true
+	case "RS512", "ES512":
+		return crypto.SHA512, true
+	default:
+		return 0, false
+	}
+}
+
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, signed, signature []byte) error {
+	hashed := digest(hash, signed)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm does not match key")
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, hashed, signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		// each ES algorithm is defined for one curve (RFC 7518, section 3.4)
+		if curve, ok := ecdsaCurve(alg); !ok || k.Curve != curve {
+			return fmt.Errorf("algorithm does not match key")
+		}
+		// JWS ECDSA signatures are the fixed size concatenation of r and s
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, hashed, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type")
+	}
+}
+
+// ecdsaCurve returns the curve an ECDSA algorithm signs with
+func ecdsaCurve(alg string) (elliptic.Curve, bool) {
+	switch alg {
+	case "ES256":
+		return elliptic.P256(), true
+	case "ES384":
+		return elliptic.P384(), true
+	case "ES512":
+		return elliptic.P521(), true
+	default:
+		return nil, false
+	}
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// numericDate converts a JWT NumericDate, seconds since the epoch
+func numericDate(n json.Number) (time.Time, error) {
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(seconds), 0), nil
+}
+
+// audience is the aud claim, a single string or an array
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// scopeList is the scp claim, an array or a space separated string
+type scopeList []string
+
+func (s *scopeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = strings.Fields(single)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
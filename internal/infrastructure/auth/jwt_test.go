@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+)
+
+const (
+	testIssuer   = "https://login.example.com/realms/main"
+	testAudience = "alodb"
+)
+
+// testKeys are signing keys generated for a test run and published in a JWKS file
+type testKeys struct {
+	rsa   *rsa.PrivateKey
+	ec256 *ecdsa.PrivateKey
+	ec384 *ecdsa.PrivateKey
+	jwks  string
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	ecJWK := func(kid, crv string, k *ecdsa.PrivateKey) jsonWebKey {
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return jsonWebKey{Kty: "EC", Kid: kid, Use: "sig", Crv: crv,
+			X: b64(k.X.FillBytes(make([]byte, size))), Y: b64(k.Y.FillBytes(make([]byte, size)))}
+	}
+
+	doc := map[string][]jsonWebKey{"keys": {
+		{Kty: "RSA", Kid: "rsa", Use: "sig", Alg: "RS256",
+			N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		ecJWK("ec256", "P-256", ec256),
+		// no alg, so only the curve ties the key to ES384
+		ecJWK("ec384", "P-384", ec384),
+	}}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKeys{rsa: rsaKey, ec256: ec256, ec384: ec384, jwks: path}
+}
+
+// sign creates a token with the header alg and kid, signed by key
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+
+	hash, ok := signatureHash(alg)
+	if !ok {
+		hash = crypto.SHA256
+	}
+	hashed := digest(hash, []byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, hashed); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, hashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims accepted by the test validator
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "user-1",
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "openid alodb:execute alodb:unknown",
+	}
+}
+
+// with returns the valid claims with the given changes, a nil value removes a claim
+func with(changes map[string]any) map[string]any {
+	claims := validClaims()
+	for k, v := range changes {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func newTestValidator(t *testing.T, keys *testKeys) *TokenValidator {
+	t.Helper()
+
+	v, err := NewTokenValidator(context.Background(), TokenConfig{
+		JWKSFile:      keys.jwks,
+		Issuer:        testIssuer,
+		Audience:      testAudience,
+		DefaultScopes: []domainAuth.Scope{domainAuth.ScopeChat},
+	})
+	if err != nil {
+		t.Fatalf("NewTokenValidator: %v", err)
+	}
+	return v
+}
+
+func TestAuthenticateValidTokens(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newTestValidator(t, keys)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"RS256", sign(t, "RS256", "rsa", keys.rsa, validClaims())},
+		{"ES256", sign(t, "ES256", "ec256", keys.ec256, validClaims())},
+		{"ES384", sign(t, "ES384", "ec384", keys.ec384, validClaims())},
+		{"audience list", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"aud": []string{"other", testAudience}}))},
+		{"expired within the clock skew", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"exp": time.Now().Add(-30 * time.Second).Unix()}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := v.Authenticate(context.Background(), tt.token)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if principal.UserID != "user-1" {
+				t.Errorf("user = %q", principal.UserID)
+			}
+			want := []domainAuth.Scope{domainAuth.ScopeChat, domainAuth.ScopeExecute}
+			if !slices.Equal(principal.Scopes, want) {
+				t.Errorf("scopes = %v, want %v", principal.Scopes, want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejectsTokens(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newTestValidator(t, keys)
+
+	valid := sign(t, "RS256", "rsa", keys.rsa, validClaims())
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"expired", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})), "token expired"},
+		{"not valid yet", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})), "token not valid yet"},
+		{"missing exp", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"exp": nil})), "missing exp claim"},
+		{"missing sub", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"sub": nil})), "missing sub claim"},
+		{"wrong issuer", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"iss": "https://evil.example.com"})), "unexpected issuer"},
+		{"wrong audience", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"aud": "another-app"})), "unexpected audience"},
+		{"missing audience", sign(t, "RS256", "rsa", keys.rsa, with(map[string]any{"aud": nil})), "unexpected audience"},
+		{"unknown kid", sign(t, "RS256", "rotated", keys.rsa, validClaims()), "unknown signing key"},
+		{"alg none", encodeHeader(t, "none", "rsa") + "." + parts[1] + ".", "unsupported algorithm"},
+		{"alg HS256", encodeHeader(t, "HS256", "rsa") + "." + parts[1] + "." + parts[2], "unsupported algorithm"},
+		// the RSA key is restricted to RS256 by its JWKS alg
+		{"RS384 on an RS256 key", sign(t, "RS384", "rsa", keys.rsa, validClaims()), "unknown signing key"},
+		{"RS256 on an EC key", sign(t, "RS256", "ec256", keys.rsa, validClaims()), "algorithm does not match key"},
+		{"ES256 on a P-384 key", sign(t, "ES256", "ec384", keys.ec384, validClaims()), "algorithm does not match key"},
+		{"ES384 on a P-256 key", sign(t, "ES384", "ec256", keys.ec256, validClaims()), "algorithm does not match key"},
+		{"signed by another key", sign(t, "ES256", "ec256", mustECKey(t), validClaims()), "invalid signature"},
+		{"tampered claims", parts[0] + "." + encodeClaims(t, with(map[string]any{"sub": "admin"})) + "." + parts[2], "invalid signature"},
+		{"malformed", "not-a-token", "malformed token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Authenticate(context.Background(), tt.token)
+			if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithoutIssuerAudience(t *testing.T) {
+	keys := newTestKeys(t)
+	v, err := NewTokenValidator(context.Background(), TokenConfig{JWKSFile: keys.jwks})
+	if err != nil {
+		t.Fatalf("NewTokenValidator: %v", err)
+	}
+
+	token := sign(t, "ES256", "ec256", keys.ec256, with(map[string]any{"iss": "https://other.example.com", "aud": "another-app"}))
+	if _, err := v.Authenticate(context.Background(), token); err != nil {
+		t.Errorf("Authenticate: %v, want any issuer and audience accepted when not configured", err)
+	}
+}
+
+func encodeHeader(t *testing.T, alg, kid string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeClaims(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -1,30 +1,132 @@
 package config
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
 	"github.com/spf13/viper"
 )
 
 const (
 	DefaultSchemaCacheTTL = 1 * time.Hour
+	DefaultQueryMaxRows   = 100
+	DefaultQueryTimeout   = 10 * time.Second
+	DefaultMaxPageSize    = 1000
+	DefaultCursorTTL      = 5 * time.Minute
+	DefaultMaxUserCursors = 8
+	DefaultStorageDriver  = "memory"
+
+	DefaultSchemaConnectTimeout   = 10 * time.Second
+	DefaultSchemaStatementTimeout = 30 * time.Second
+	DefaultSchemaTimeout          = 2 * time.Minute
+
+	DefaultRateLimitStore        = "memory"
+	DefaultChatRequestsPerMinute = 20
+	DefaultChatBurst             = 5
+)
+
+const (
+	AuthModeNone   = "none"    // every request is served as the default user
+	AuthModeAPIKey = "api_key" // requests need an API key
+	AuthModeJWT    = "jwt"     // requests need a JWT from the configured identity provider
 )
 
+// DefaultJWTScopes are granted to every valid JWT
+var DefaultJWTScopes = []string{"chat"}
+
 type Config struct {
-	Server    ServerConfig
-	Agent     AgentConfig
-	Providers map[domainAgent.Provider]string
+	Server      ServerConfig
+	Agent       AgentConfig
+	Query       QueryConfig
+	Storage     StorageConfig
+	Secrets     SecretsConfig
+	Auth        AuthConfig
+	RateLimit   RateLimitConfig
+	Connections ConnectionPolicyConfig
+	Providers   map[domainAgent.Provider]domainAgent.ProviderSettings
 }
 
 type ServerConfig struct {
 	Port      string
 	UIBaseURL string
 	Env       string
+	// TrustedProxies may set the client IP through X-Forwarded-For
+	TrustedProxies []string
 }
 
 type AgentConfig struct {
 	SchemaCacheTTL time.Duration
+	QueryMaxRows   int
+	QueryTimeout   time.Duration
+	// SchemaConnectTimeout, SchemaStatementTimeout and SchemaTimeout bound read_schema
+	SchemaConnectTimeout   time.Duration
+	SchemaStatementTimeout time.Duration
+	SchemaTimeout          time.Duration
+}
+
+type QueryConfig struct {
+	MaxPageSize    int
+	CursorTTL      time.Duration
+	MaxUserCursors int
+}
+
+type StorageConfig struct {
+	Driver string
+	DSN    string
+}
+
+type SecretsConfig struct {
+	Key          string   // base64 encoded AES-256 master key
+	PreviousKeys []string // retired master keys, kept until secrets are rotated
+	KeyFile      string   // file with one key per line, the first is current
+}
+
+type RateLimitConfig struct {
+	Store                 string // memory or sql, the storage database shared by replicas
+	ChatRequestsPerMinute int    // 0 disables chat rate limiting
+	ChatBurst             int
+	DailyTokens           int64 // LLM tokens per caller and UTC day, 0 is unlimited
+}
+
+// ConnectionPolicyConfig restricts the databases user supplied connection strings may reach
+type ConnectionPolicyConfig struct {
+	AllowedHosts []string
+	AllowedCIDRs []string
+	DeniedCIDRs  []string // nil uses the default denied ranges
+	AllowedPorts []int
+	MinSSLMode   string
+}
+
+type AuthConfig struct {
+	Modes []string // accepted credentials, api_key and/or jwt
+	JWT   JWTConfig
+}
+
+type JWTConfig struct {
+	JWKSFile string
+	JWKSURL  string
+	Issuer   string
+	Audience string
+	Scopes   []string // granted to every valid token
+	// InsecureSkipIssuerAudience accepts tokens without checking iss or aud
+	// when they are not configured, including tokens issued for other applications
+	InsecureSkipIssuerAudience bool
+}
+
+// Enabled reports whether requests must authenticate
+func (c AuthConfig) Enabled() bool {
+	return len(c.Modes) > 0
+}
+
+// Has reports whether the credential type is accepted
+func (c AuthConfig) Has(mode string) bool {
+	return slices.Contains(c.Modes, mode)
 }
 
 func Load() (config Config, err error) {
@@ -51,27 +153,303 @@ func Load() (config Config, err error) {
 	if config.Server.Env == "" {
 		config.Server.Env = "production"
 	}
+	config.Server.TrustedProxies = splitList(viper.GetString("SERVER_TRUSTED_PROXIES"))
 
 	config.Agent.SchemaCacheTTL = parseDuration(
 		viper.GetString("SCHEMA_CACHE_TTL"),
 		DefaultSchemaCacheTTL,
 	)
+	config.Agent.QueryMaxRows = parseInt(
+		viper.GetString("QUERY_MAX_ROWS"),
+		DefaultQueryMaxRows,
+	)
+	config.Agent.QueryTimeout = parseDuration(
+		viper.GetString("QUERY_STATEMENT_TIMEOUT"),
+		DefaultQueryTimeout,
+	)
+	config.Agent.SchemaConnectTimeout = parseDuration(
+		viper.GetString("SCHEMA_CONNECT_TIMEOUT"),
+		DefaultSchemaConnectTimeout,
+	)
+	config.Agent.SchemaStatementTimeout = parseDuration(
+		viper.GetString("SCHEMA_STATEMENT_TIMEOUT"),
+		DefaultSchemaStatementTimeout,
+	)
+	config.Agent.SchemaTimeout = parseDuration(
+		viper.GetString("SCHEMA_TIMEOUT"),
+		DefaultSchemaTimeout,
+	)
+
+	config.Query.MaxPageSize = parseInt(
+		viper.GetString("QUERY_MAX_PAGE_SIZE"),
+		DefaultMaxPageSize,
+	)
+	config.Query.CursorTTL = parseDuration(
+		viper.GetString("QUERY_CURSOR_TTL"),
+		DefaultCursorTTL,
+	)
+	config.Query.MaxUserCursors = parseInt(
+		viper.GetString("QUERY_MAX_USER_CURSORS"),
+		DefaultMaxUserCursors,
+	)
 
-	config.Providers = loadProviders()
+	config.Storage.Driver = viper.GetString("STORAGE_DRIVER")
+	if config.Storage.Driver == "" {
+		config.Storage.Driver = DefaultStorageDriver
+	}
+	config.Storage.DSN = viper.GetString("STORAGE_DSN")
+
+	config.Secrets.Key = viper.GetString("SECRETS_KEY")
+	config.Secrets.PreviousKeys = splitList(viper.GetString("SECRETS_PREVIOUS_KEYS"))
+	config.Secrets.KeyFile = viper.GetString("SECRETS_KEY_FILE")
+
+	if config.Auth, err = loadAuth(); err != nil {
+		return Config{}, err
+	}
+
+	config.RateLimit.Store = viper.GetString("RATE_LIMIT_STORE")
+	if config.RateLimit.Store == "" {
+		config.RateLimit.Store = DefaultRateLimitStore
+	}
+	config.RateLimit.ChatRequestsPerMinute = parseLimit(
+		viper.GetString("RATE_LIMIT_CHAT_PER_MINUTE"),
+		DefaultChatRequestsPerMinute,
+	)
+	config.RateLimit.ChatBurst = parseInt(
+		viper.GetString("RATE_LIMIT_CHAT_BURST"),
+		DefaultChatBurst,
+	)
+	config.RateLimit.DailyTokens = int64(parseLimit(
+		viper.GetString("RATE_LIMIT_DAILY_TOKENS"),
+		0,
+	))
+
+	if config.Connections, err = loadConnectionPolicy(); err != nil {
+		return Config{}, err
+	}
+
+	if config.Providers, err = loadProviders(); err != nil {
+		return Config{}, err
+	}
+
+	if err := decryptValues(&config); err != nil {
+		return Config{}, err
+	}
 
 	return config, nil
 }
 
-func loadProviders() map[domainAgent.Provider]string {
-	providers := make(map[domainAgent.Provider]string)
+// loadAuth reads AUTH_MODE, a comma separated list of accepted credentials,
+// and the JWT settings
+func loadAuth() (AuthConfig, error) {
+	var auth AuthConfig
+	for _, mode := range splitList(strings.ToLower(viper.GetString("AUTH_MODE"))) {
+		switch mode {
+		case AuthModeNone:
+		case AuthModeAPIKey, AuthModeJWT:
+			if !slices.Contains(auth.Modes, mode) {
+				auth.Modes = append(auth.Modes, mode)
+			}
+		default:
+			return AuthConfig{}, fmt.Errorf("unknown AUTH_MODE %q, expected %s, %s or %s", mode, AuthModeNone, AuthModeAPIKey, AuthModeJWT)
+		}
+	}
+
+	auth.JWT = JWTConfig{
+		JWKSFile: viper.GetString("AUTH_JWT_JWKS_FILE"),
+		JWKSURL:  viper.GetString("AUTH_JWT_JWKS_URL"),
+		Issuer:   viper.GetString("AUTH_JWT_ISSUER"),
+		Audience: viper.GetString("AUTH_JWT_AUDIENCE"),
+		Scopes:   DefaultJWTScopes,
+
+		InsecureSkipIssuerAudience: viper.GetBool("AUTH_JWT_INSECURE_SKIP_ISSUER_AUDIENCE"),
+	}
+	if viper.IsSet("AUTH_JWT_SCOPES") {
+		auth.JWT.Scopes = splitList(viper.GetString("AUTH_JWT_SCOPES"))
+	}
+
+	if auth.Has(AuthModeJWT) && auth.JWT.JWKSFile == "" && auth.JWT.JWKSURL == "" && auth.JWT.Issuer == "" {
+		return AuthConfig{}, fmt.Errorf("AUTH_MODE=jwt requires AUTH_JWT_JWKS_FILE, AUTH_JWT_JWKS_URL or AUTH_JWT_ISSUER")
+	}
+	// without iss and aud checks, every token the identity provider issues for
+	// any application would be accepted
+	if auth.Has(AuthModeJWT) && (auth.JWT.Issuer == "" || auth.JWT.Audience == "") && !auth.JWT.InsecureSkipIssuerAudience {
+		return AuthConfig{}, fmt.Errorf("AUTH_MODE=jwt requires AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE, " +
+			"set AUTH_JWT_INSECURE_SKIP_ISSUER_AUDIENCE=true to accept tokens issued for other applications")
+	}
+
+	return auth, nil
+}
+
+// loadConnectionPolicy reads the CONNECTION_* settings. CONNECTION_DENIED_CIDRS
+// replaces the default denied ranges, "none" denies nothing.
+func loadConnectionPolicy() (ConnectionPolicyConfig, error) {
+	policy := ConnectionPolicyConfig{
+		AllowedHosts: splitList(viper.GetString("CONNECTION_ALLOWED_HOSTS")),
+		AllowedCIDRs: splitList(viper.GetString("CONNECTION_ALLOWED_CIDRS")),
+		MinSSLMode:   strings.ToLower(viper.GetString("CONNECTION_MIN_SSLMODE")),
+	}
+
+	if viper.IsSet("CONNECTION_DENIED_CIDRS") {
+		policy.DeniedCIDRs = []string{}
+		if denied := viper.GetString("CONNECTION_DENIED_CIDRS"); !strings.EqualFold(strings.TrimSpace(denied), "none") {
+			policy.DeniedCIDRs = splitList(denied)
+		}
+	}
+
+	for _, value := range splitList(viper.GetString("CONNECTION_ALLOWED_PORTS")) {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return ConnectionPolicyConfig{}, fmt.Errorf("invalid CONNECTION_ALLOWED_PORTS entry %q", value)
+		}
+		policy.AllowedPorts = append(policy.AllowedPorts, port)
+	}
+
+	return policy, nil
+}
+
+// decryptValues decrypts "enc:" values of credentials with the secrets master key
+func decryptValues(config *Config) error {
+	var cipher *secrets.Cipher
+	decrypt := func(value string) (string, error) {
+		if !secrets.IsEncryptedValue(value) {
+			return value, nil
+		}
+		if cipher == nil {
+			keyring, err := secrets.LoadKeyring(config.Secrets.Keys())
+			if err != nil {
+				return "", fmt.Errorf("failed to load secrets key for encrypted config: %w", err)
+			}
+			cipher = secrets.NewCipher(keyring)
+		}
+
+		plaintext, err := cipher.DecryptValue(context.Background(), value)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt config value: %w", err)
+		}
+		return plaintext, nil
+	}
+
+	dsn, err := decrypt(config.Storage.DSN)
+	if err != nil {
+		return err
+	}
+	config.Storage.DSN = dsn
+
+	for provider, settings := range config.Providers {
+		if settings.APIKey, err = decrypt(settings.APIKey); err != nil {
+			return err
+		}
+		config.Providers[provider] = settings
+	}
+
+	return nil
+}
+
+// Keys returns the key configuration of the secrets package
+func (c SecretsConfig) Keys() secrets.Config {
+	return secrets.Config{
+		Key:          c.Key,
+		PreviousKeys: c.PreviousKeys,
+		KeyFile:      c.KeyFile,
+	}
+}
+
+func loadProviders() (map[domainAgent.Provider]domainAgent.ProviderSettings, error) {
+	providers := make(map[domainAgent.Provider]domainAgent.ProviderSettings)
 
 	for provider, cfg := range domainAgent.ProviderRegistry {
-		if key := viper.GetString(cfg.EnvKey); key != "" {
-			providers[provider] = key
+		if cfg.ScriptEnvKey != "" {
+			if path := viper.GetString(cfg.ScriptEnvKey); path != "" {
+				providers[provider] = domainAgent.ProviderSettings{ScriptPath: path}
+			}
+			continue
+		}
+
+		key := viper.GetString(cfg.EnvKey)
+
+		if !cfg.IsConfigurable() {
+			if key != "" {
+				providers[provider] = domainAgent.ProviderSettings{APIKey: key}
+			}
+			continue
+		}
+
+		baseURL := viper.GetString(cfg.BaseURLEnvKey)
+		models := parseModels(provider, viper.GetString(cfg.ModelsEnvKey))
+		if baseURL == "" || len(models) == 0 {
+			continue
+		}
+		if err := checkModelSlugs(cfg.ModelsEnvKey, models); err != nil {
+			return nil, err
+		}
+
+		providers[provider] = domainAgent.ProviderSettings{
+			APIKey:  key,
+			BaseURL: baseURL,
+			Models:  models,
 		}
 	}
 
-	return providers
+	return providers, nil
+}
+
+// checkModelSlugs rejects configured models whose slug is listed twice or is
+// also the slug of a built-in model. A request for such a slug could otherwise
+// be routed to the hosted provider and send the schema off the network.
+func checkModelSlugs(envKey string, models []domainAgent.Model) error {
+	seen := make(map[string]bool, len(models))
+	for _, m := range models {
+		if seen[m.Slug] {
+			return fmt.Errorf("%s lists the model %q twice", envKey, m.Slug)
+		}
+		seen[m.Slug] = true
+
+		if builtin, ok := domainAgent.GetModelBySlug(m.Slug); ok {
+			return fmt.Errorf("%s model %q has the slug of a built-in %s model, rename it so requests cannot be routed to %s",
+				envKey, m.Slug, builtin.Provider, builtin.Provider)
+		}
+	}
+	return nil
+}
+
+// parseModels parses a comma separated list of model slugs, each optionally
+// followed by "=Display Name"
+func parseModels(provider domainAgent.Provider, s string) []domainAgent.Model {
+	var models []domainAgent.Model
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		slug, name, found := strings.Cut(entry, "=")
+		slug = strings.TrimSpace(slug)
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			name = slug
+		}
+
+		models = append(models, domainAgent.Model{
+			Slug:     slug,
+			Name:     name,
+			Provider: provider,
+		})
+	}
+
+	return models
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // parseDuration parses a duration string, returns default if invalid or empty
@@ -85,3 +463,27 @@ func parseDuration(s string, defaultVal time.Duration) time.Duration {
 	}
 	return d
 }
+
+// parseLimit parses a limit where 0 disables it, returns default if invalid or empty
+func parseLimit(s string, defaultVal int) int {
+	if s == "" {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return defaultVal
+	}
+	return n
+}
+
+// parseInt parses a positive integer string, returns default if invalid or empty
+func parseInt(s string, defaultVal int) int {
+	if s == "" {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return defaultVal
+	}
+	return n
+}
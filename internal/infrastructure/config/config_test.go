@@ -0,0 +1,90 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+)
+
+func TestCheckModelSlugs(t *testing.T) {
+	tests := []struct {
+		models string
+		want   string
+	}{
+		{"qwen2.5-coder:32b=Qwen 2.5 Coder 32B,llama3.1:70b", ""},
+		{"llama3.1:70b,llama3.1:70b=Llama", "lists the model \"llama3.1:70b\" twice"},
+		{"gpt-4o", "slug of a built-in openai model"},
+		{"local=Local,claude-haiku-4-5", "slug of a built-in anthropic model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.models, func(t *testing.T) {
+			models := parseModels(domainAgent.ProviderOpenAICompatible, tt.models)
+			err := checkModelSlugs("OPENAI_COMPATIBLE_MODELS", models)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkModelSlugs: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAuthRequiresIssuerAndAudience(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]any
+		want string
+	}{
+		{
+			name: "issuer and audience",
+			env:  map[string]any{"AUTH_MODE": "jwt", "AUTH_JWT_ISSUER": "https://login.example.com", "AUTH_JWT_AUDIENCE": "alodb"},
+		},
+		{
+			name: "missing audience",
+			env:  map[string]any{"AUTH_MODE": "jwt", "AUTH_JWT_ISSUER": "https://login.example.com"},
+			want: "requires AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE",
+		},
+		{
+			name: "missing issuer",
+			env:  map[string]any{"AUTH_MODE": "api_key,jwt", "AUTH_JWT_JWKS_FILE": "jwks.json", "AUTH_JWT_AUDIENCE": "alodb"},
+			want: "requires AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE",
+		},
+		{
+			name: "explicit opt-out",
+			env:  map[string]any{"AUTH_MODE": "jwt", "AUTH_JWT_JWKS_FILE": "jwks.json", "AUTH_JWT_INSECURE_SKIP_ISSUER_AUDIENCE": "true"},
+		},
+		{
+			name: "API keys only",
+			env:  map[string]any{"AUTH_MODE": "api_key"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			for k, v := range tt.env {
+				viper.Set(k, v)
+			}
+
+			_, err := loadAuth()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("loadAuth: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	domainConnection "github.com/mololab/alodb/internal/domain/connection"
+
+	"github.com/lib/pq"
+)
+
+const testTimeout = 10 * time.Second
+
+// BuildConnectionString returns the PostgreSQL URL of a profile
+func BuildConnectionString(profile *domainConnection.Profile, password string) string {
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(profile.Host, strconv.Itoa(profile.Port)),
+		Path:   "/" + profile.Database,
+	}
+	if password != "" {
+		u.User = url.UserPassword(profile.User, password)
+	} else {
+		u.User = url.User(profile.User)
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", profile.SSLMode)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// TestConnection connects to the database through the policy and pings it
+func TestConnection(ctx context.Context, policy *Policy, connectionString string) error {
+	ctx, cancel := context.WithTimeout(ctx, testTimeout)
+	defer cancel()
+
+	db, err := policy.Open(connectionString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrConnectionTestFailed, err)
+	}
+	return nil
+}
+
+// parseDSN parses a postgres:// URL or a key=value connection string into its
+// options, following the quoting rules of lib/pq
+func parseDSN(dsn string) (map[string]string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		converted, err := pq.ParseURL(dsn)
+		if err != nil {
+			// the error of url.Parse quotes the URL, password included
+			return nil, ErrInvalidDSN
+		}
+		dsn = converted
+	}
+
+	options := make(map[string]string)
+	rest := []rune(dsn)
+	skipSpace := func() {
+		for len(rest) > 0 && (rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r') {
+			rest = rest[1:]
+		}
+	}
+
+	for {
+		skipSpace()
+		if len(rest) == 0 {
+			return options, nil
+		}
+
+		var key []rune
+		for len(rest) > 0 && rest[0] != '=' && rest[0] != ' ' {
+			key = append(key, rest[0])
+			rest = rest[1:]
+		}
+		skipSpace()
+		if len(key) == 0 || len(rest) == 0 || rest[0] != '=' {
+			return nil, ErrInvalidDSN
+		}
+		rest = rest[1:]
+		skipSpace()
+
+		var value []rune
+		quoted := len(rest) > 0 && rest[0] == '\''
+		if quoted {
+			rest = rest[1:]
+		}
+		for {
+			if len(rest) == 0 {
+				if quoted {
+					return nil, ErrInvalidDSN
+				}
+				break
+			}
+			c := rest[0]
+			rest = rest[1:]
+			if quoted && c == '\'' || !quoted && (c == ' ' || c == '\t' || c == '\n' || c == '\r') {
+				break
+			}
+			if c == '\\' {
+				if len(rest) == 0 {
+					return nil, ErrInvalidDSN
+				}
+				c = rest[0]
+				rest = rest[1:]
+			}
+			value = append(value, c)
+		}
+
+		options[string(key)] = string(value)
+	}
+}
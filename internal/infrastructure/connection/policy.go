@@ -0,0 +1,316 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+const (
+	defaultHost = "localhost"
+	defaultPort = 5432
+)
+
+var (
+	ErrTargetNotAllowed = errors.New("connection target is not allowed")
+	ErrInvalidDSN       = errors.New("invalid connection string")
+	ErrHostNotFound     = errors.New("database host not found")
+)
+
+// DefaultDeniedCIDRs block loopback, link-local addresses including the cloud
+// metadata services, the unspecified addresses and the private ranges
+// (RFC 1918, shared address space and unique local addresses, which also holds
+// the IPv6 metadata address fd00:ec2::254). Databases on private networks are
+// reached by listing their hosts or ranges in the allow lists.
+var DefaultDeniedCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// sslModes orders the sslmode values from least to most strict
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// PolicyConfig restricts the databases user supplied connection strings may reach
+type PolicyConfig struct {
+	// AllowedHosts are host names, exact or "*.example.com", that are always allowed
+	AllowedHosts []string
+	// AllowedCIDRs are always allowed. When AllowedHosts or AllowedCIDRs is set,
+	// every other target is denied.
+	AllowedCIDRs []string
+	// DeniedCIDRs are checked after the allowed ranges, nil uses DefaultDeniedCIDRs
+	DeniedCIDRs []string
+	// AllowedPorts restricts the ports, empty allows any port
+	AllowedPorts []int
+	// MinSSLMode is the least strict accepted sslmode, empty accepts any
+	MinSSLMode string
+}
+
+// Policy decides which hosts, ports and sslmodes connection strings may use.
+// It is checked after DNS resolution and enforced by the dialer, so a host
+// name that changes its address between the check and the dial is caught.
+type Policy struct {
+	allowedHosts []string
+	allowedCIDRs []netip.Prefix
+	deniedCIDRs  []netip.Prefix
+	allowedPorts []int
+	minSSLMode   int
+	resolver     *net.Resolver
+}
+
+// NewPolicy validates the configuration and creates a policy
+func NewPolicy(cfg PolicyConfig) (*Policy, error) {
+	p := &Policy{
+		allowedPorts: cfg.AllowedPorts,
+		minSSLMode:   -1,
+		resolver:     net.DefaultResolver,
+	}
+
+	for _, host := range cfg.AllowedHosts {
+		p.allowedHosts = append(p.allowedHosts, normalizeHost(host))
+	}
+
+	var err error
+	if p.allowedCIDRs, err = parsePrefixes(cfg.AllowedCIDRs); err != nil {
+		return nil, err
+	}
+	denied := cfg.DeniedCIDRs
+	if denied == nil {
+		denied = DefaultDeniedCIDRs
+	}
+	if p.deniedCIDRs, err = parsePrefixes(denied); err != nil {
+		return nil, err
+	}
+
+	for _, port := range cfg.AllowedPorts {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+	}
+
+	if cfg.MinSSLMode != "" {
+		if p.minSSLMode = slices.Index(sslModes, cfg.MinSSLMode); p.minSSLMode < 0 {
+			return nil, fmt.Errorf("unknown sslmode %q", cfg.MinSSLMode)
+		}
+	}
+
+	return p, nil
+}
+
+// Open checks the sslmode and returns a database handle whose connections
+// are dialed through the policy. A nil policy refuses every connection.
+func (p *Policy) Open(connectionString string) (*sql.DB, error) {
+	if p == nil {
+		return nil, fmt.Errorf("%w: no connection policy configured", ErrTargetNotAllowed)
+	}
+	if _, err := p.checkOptions(connectionString); err != nil {
+		return nil, err
+	}
+
+	connector, err := pq.NewConnector(connectionString)
+	if err != nil {
+		return nil, ErrInvalidDSN
+	}
+	connector.Dialer(&policyDialer{policy: p})
+
+	return sql.OpenDB(connector), nil
+}
+
+// Check verifies a connection string without connecting: its sslmode, port
+// and the addresses its host resolves to
+func (p *Policy) Check(ctx context.Context, connectionString string) error {
+	if p == nil {
+		return fmt.Errorf("%w: no connection policy configured", ErrTargetNotAllowed)
+	}
+	options, err := p.checkOptions(connectionString)
+	if err != nil {
+		return err
+	}
+
+	host := firstNonEmpty(options["host"], os.Getenv("PGHOST"), defaultHost)
+	port := firstNonEmpty(options["port"], os.Getenv("PGPORT"), strconv.Itoa(defaultPort))
+
+	_, err = p.resolve(ctx, host, port)
+	return err
+}
+
+// checkOptions parses the connection string and checks its sslmode
+func (p *Policy) checkOptions(connectionString string) (map[string]string, error) {
+	options, err := parseDSN(connectionString)
+	if err != nil {
+		return nil, ErrInvalidDSN
+	}
+
+	if p.minSSLMode >= 0 {
+		// lib/pq requires TLS when no sslmode is given
+		mode := firstNonEmpty(options["sslmode"], os.Getenv("PGSSLMODE"), "require")
+		if slices.Index(sslModes, mode) < p.minSSLMode {
+			return nil, fmt.Errorf("%w: sslmode %s is not allowed, use %s or stricter",
+				ErrTargetNotAllowed, mode, sslModes[p.minSSLMode])
+		}
+	}
+
+	return options, nil
+}
+
+// resolve checks the port and host and returns the addresses that may be dialed
+func (p *Policy) resolve(ctx context.Context, host, port string) ([]netip.Addr, error) {
+	if strings.HasPrefix(host, "/") {
+		return nil, fmt.Errorf("%w: unix sockets are not allowed", ErrTargetNotAllowed)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return nil, ErrInvalidDSN
+	}
+	if len(p.allowedPorts) > 0 && !slices.Contains(p.allowedPorts, portNumber) {
+		return nil, fmt.Errorf("%w: port %d is not allowed", ErrTargetNotAllowed, portNumber)
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		resolved, err := p.resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil || len(resolved) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrHostNotFound, host)
+		}
+		addrs = resolved
+	}
+
+	hostAllowed := p.hostAllowed(host)
+	for i, addr := range addrs {
+		addrs[i] = addr.Unmap()
+		// a zoned address is in none of the ranges, so the zone is dropped
+		// for the check and kept for the dial
+		if !hostAllowed && !p.addrAllowed(addrs[i].WithZone("")) {
+			// the address stays in the server log, the client only learns the host is refused
+			logger.Warn().Str("host", host).Str("address", addrs[i].String()).Msg("connection target denied by policy")
+			return nil, fmt.Errorf("%w: host %s is not allowed", ErrTargetNotAllowed, host)
+		}
+	}
+	return addrs, nil
+}
+
+// hostAllowed reports whether the host name is on the allowlist
+func (p *Policy) hostAllowed(host string) bool {
+	host = normalizeHost(host)
+	for _, allowed := range p.allowedHosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// addrAllowed applies the CIDR rules, an allowed range wins over a denied one
+func (p *Policy) addrAllowed(addr netip.Addr) bool {
+	for _, prefix := range p.allowedCIDRs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range p.deniedCIDRs {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return len(p.allowedHosts) == 0 && len(p.allowedCIDRs) == 0
+}
+
+// policyDialer resolves and checks every address lib/pq dials and connects
+// to the checked IP, never resolving the host name a second time
+type policyDialer struct {
+	policy *Policy
+}
+
+func (d *policyDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d *policyDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+func (d *policyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != "tcp" {
+		return nil, fmt.Errorf("%w: unix sockets are not allowed", ErrTargetNotAllowed)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, ErrInvalidDSN
+	}
+
+	addrs, err := d.policy.resolve(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	// the dial error would tell which internal address refused or timed out
+	return nil, fmt.Errorf("could not connect to %s", net.JoinHostPort(host, port))
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			addr = addr.Unmap().WithZone("")
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,227 @@
+package connection
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeResolver answers DNS lookups from records, unknown names do not exist
+func fakeResolver(records map[string][]netip.Addr) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveDNS(server, records)
+			return client, nil
+		},
+	}
+}
+
+// serveDNS answers length-prefixed DNS queries, the framing Go uses on
+// connections that are not packet connections
+func serveDNS(conn net.Conn, records map[string][]netip.Addr) {
+	defer conn.Close()
+	for {
+		var size uint16
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		query := make([]byte, size)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+
+		var msg dnsmessage.Message
+		if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+			return
+		}
+		q := msg.Questions[0]
+		addrs, found := records[strings.TrimSuffix(q.Name.String(), ".")]
+
+		msg.Header.Response = true
+		msg.Header.Authoritative = true
+		if !found {
+			msg.Header.RCode = dnsmessage.RCodeNameError
+		}
+		for _, addr := range addrs {
+			header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60}
+			switch {
+			case q.Type == dnsmessage.TypeA && addr.Is4():
+				header.Type = dnsmessage.TypeA
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: addr.As4()}})
+			case q.Type == dnsmessage.TypeAAAA && addr.Is6():
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: addr.As16()}})
+			}
+		}
+
+		answer, err := msg.Pack()
+		if err != nil {
+			return
+		}
+		if err := binary.Write(conn, binary.BigEndian, uint16(len(answer))); err != nil {
+			return
+		}
+		if _, err := conn.Write(answer); err != nil {
+			return
+		}
+	}
+}
+
+// testRecords are the DNS names known to the fake resolver
+var testRecords = map[string][]netip.Addr{
+	"db.example.com":       {netip.MustParseAddr("203.0.113.10")},
+	"db6.example.com":      {netip.MustParseAddr("2001:db8::10")},
+	"internal.example.com": {netip.MustParseAddr("10.1.2.3")},
+	"rebind.example.com":   {netip.MustParseAddr("203.0.113.11"), netip.MustParseAddr("127.0.0.1")},
+	"metadata.example.com": {netip.MustParseAddr("169.254.169.254")},
+	"cgnat.example.com":    {netip.MustParseAddr("100.100.100.200")},
+	"ula.example.com":      {netip.MustParseAddr("fd00:ec2::254")},
+}
+
+func newTestPolicy(t *testing.T, cfg PolicyConfig) *Policy {
+	t.Helper()
+
+	p, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	p.resolver = fakeResolver(testRecords)
+	return p
+}
+
+func TestPolicyCheckDefaults(t *testing.T) {
+	p := newTestPolicy(t, PolicyConfig{})
+
+	tests := []struct {
+		host string
+		want error
+	}{
+		{"203.0.113.10", nil},
+		{"db.example.com", nil},
+		{"db6.example.com", nil},
+		{"127.0.0.1", ErrTargetNotAllowed},
+		{"[::1]", ErrTargetNotAllowed},
+		{"0.0.0.0", ErrTargetNotAllowed},
+		{"169.254.169.254", ErrTargetNotAllowed},
+		{"10.0.0.5", ErrTargetNotAllowed},
+		{"172.16.0.1", ErrTargetNotAllowed},
+		{"172.31.255.254", ErrTargetNotAllowed},
+		{"192.168.1.10", ErrTargetNotAllowed},
+		{"100.64.0.1", ErrTargetNotAllowed},
+		{"[fd12:3456::1]", ErrTargetNotAllowed},
+		{"[fe80::1]", ErrTargetNotAllowed},
+		{"[::ffff:10.0.0.5]", ErrTargetNotAllowed},
+		// zoned addresses are in no range unless the zone is dropped
+		{"[::1%25lo]", ErrTargetNotAllowed},
+		{"[fe80::1%25eth0]", ErrTargetNotAllowed},
+		// the check runs on the resolved addresses, not on the name
+		{"internal.example.com", ErrTargetNotAllowed},
+		{"metadata.example.com", ErrTargetNotAllowed},
+		{"cgnat.example.com", ErrTargetNotAllowed},
+		{"ula.example.com", ErrTargetNotAllowed},
+		// every address of a name must be allowed
+		{"rebind.example.com", ErrTargetNotAllowed},
+		{"missing.example.com", ErrHostNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			err := p.Check(context.Background(), "postgres://u:p@"+tt.host+":5432/app")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckAllowLists(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  PolicyConfig
+		host string
+		want error
+	}{
+		{"allowed range wins over the private ranges", PolicyConfig{AllowedCIDRs: []string{"10.0.0.0/8"}}, "10.1.2.3", nil},
+		{"allowed range applies after resolution", PolicyConfig{AllowedCIDRs: []string{"10.1.0.0/16"}}, "internal.example.com", nil},
+		{"allowed single address", PolicyConfig{AllowedCIDRs: []string{"192.168.1.10"}}, "192.168.1.10", nil},
+		{"allowed range with a zoned address", PolicyConfig{AllowedCIDRs: []string{"fe80::/10"}}, "[fe80::1%25eth0]", nil},
+		{"allowed host", PolicyConfig{AllowedHosts: []string{"internal.example.com"}}, "internal.example.com", nil},
+		{"allowed host wildcard", PolicyConfig{AllowedHosts: []string{"*.example.com"}}, "internal.example.com", nil},
+		{"allowlist refuses public addresses", PolicyConfig{AllowedCIDRs: []string{"10.0.0.0/8"}}, "db.example.com", ErrTargetNotAllowed},
+		{"allowlist refuses other private ranges", PolicyConfig{AllowedCIDRs: []string{"10.0.0.0/8"}}, "192.168.1.10", ErrTargetNotAllowed},
+		{"allowed host does not allow its address", PolicyConfig{AllowedHosts: []string{"internal.example.com"}}, "10.1.2.3", ErrTargetNotAllowed},
+		{"no denied ranges", PolicyConfig{DeniedCIDRs: []string{}}, "127.0.0.1", nil},
+		{"custom denied ranges replace the defaults", PolicyConfig{DeniedCIDRs: []string{"203.0.113.0/24"}}, "db.example.com", ErrTargetNotAllowed},
+		{"port not allowed", PolicyConfig{AllowedPorts: []int{6432}}, "db.example.com", ErrTargetNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPolicy(t, tt.cfg)
+			err := p.Check(context.Background(), "postgres://u:p@"+tt.host+":5432/app")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckOptions(t *testing.T) {
+	p := newTestPolicy(t, PolicyConfig{MinSSLMode: "require"})
+
+	tests := []struct {
+		name string
+		dsn  string
+		want error
+	}{
+		{"require", "postgres://u:p@db.example.com/app?sslmode=require", nil},
+		{"verify-full", "host=db.example.com sslmode=verify-full", nil},
+		{"lib/pq default", "postgres://u:p@db.example.com/app", nil},
+		{"disable", "postgres://u:p@db.example.com/app?sslmode=disable", ErrTargetNotAllowed},
+		{"unix socket", "host=/var/run/postgresql sslmode=require", ErrTargetNotAllowed},
+		{"zoned loopback", "host=::1%lo sslmode=require", ErrTargetNotAllowed},
+		{"zoned link-local", "host=fe80::1%eth0 sslmode=require", ErrTargetNotAllowed},
+		{"invalid", "postgres://u:p@db.example.com:99999/app", ErrInvalidDSN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.Check(context.Background(), tt.dsn); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyDialerChecksResolvedAddresses(t *testing.T) {
+	p := newTestPolicy(t, PolicyConfig{})
+	dialer := &policyDialer{policy: p}
+
+	for _, address := range []string{"internal.example.com:5432", "rebind.example.com:5432", "10.0.0.5:5432", "[::1%lo]:5432", "[fe80::1%eth0]:5432"} {
+		if _, err := dialer.DialContext(context.Background(), "tcp", address); !errors.Is(err, ErrTargetNotAllowed) {
+			t.Errorf("dial %s: err = %v, want ErrTargetNotAllowed", address, err)
+		}
+	}
+}
+
+func TestNewPolicyErrors(t *testing.T) {
+	tests := []PolicyConfig{
+		{AllowedCIDRs: []string{"10.0.0.0/33"}},
+		{DeniedCIDRs: []string{"not-a-cidr"}},
+		{AllowedPorts: []int{0}},
+		{MinSSLMode: "strict"},
+	}
+	for _, cfg := range tests {
+		if _, err := NewPolicy(cfg); err == nil {
+			t.Errorf("NewPolicy(%+v) succeeded", cfg)
+		}
+	}
+}
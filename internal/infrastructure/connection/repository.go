@@ -0,0 +1,218 @@
+// Package connection persists connection profiles with encrypted passwords.
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	domainConnection "github.com/mololab/alodb/internal/domain/connection"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProfileNotFound      = errors.New("connection profile not found")
+	ErrDuplicateName        = errors.New("a connection profile with this name already exists")
+	ErrConnectionTestFailed = errors.New("connection test failed")
+)
+
+// profileRecord is the stored form of a connection profile
+type profileRecord struct {
+	ID        string `gorm:"primaryKey;size:36"`
+	UserID    string `gorm:"size:255;not null;uniqueIndex:idx_connection_profiles_user_name"`
+	Name      string `gorm:"size:255;not null;uniqueIndex:idx_connection_profiles_user_name"`
+	Host      string `gorm:"size:255;not null"`
+	Port      int    `gorm:"not null"`
+	Database  string `gorm:"size:255;not null"`
+	User      string `gorm:"size:255;not null"`
+	SSLMode   string `gorm:"size:32;not null"`
+	Password  []byte
+	CreatedAt time.Time
+}
+
+func (profileRecord) TableName() string {
+	return "connection_profiles"
+}
+
+// Repository stores connection profiles, encrypting passwords with the cipher
+type Repository struct {
+	db     *gorm.DB
+	cipher *secrets.Cipher
+}
+
+// NewRepository creates a repository and migrates its table. Without a
+// cipher, profiles can be listed and deleted but not created or used.
+func NewRepository(db *gorm.DB, cipher *secrets.Cipher) (*Repository, error) {
+	if err := db.AutoMigrate(&profileRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate connection profiles: %w", err)
+	}
+	return &Repository{db: db, cipher: cipher}, nil
+}
+
+// Create stores a new profile for the user
+func (r *Repository) Create(ctx context.Context, req domainConnection.CreateRequest) (*domainConnection.Profile, error) {
+	if r.cipher == nil {
+		return nil, secrets.ErrNotConfigured
+	}
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&profileRecord{}).
+		Where("user_id = ? AND name = ?", req.UserID, req.Name).
+		Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check connection profiles: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrDuplicateName
+	}
+
+	id := uuid.New().String()
+	password, err := r.cipher.Encrypt(ctx, []byte(req.Password), []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	record := profileRecord{
+		ID:        id,
+		UserID:    req.UserID,
+		Name:      req.Name,
+		Host:      req.Host,
+		Port:      req.Port,
+		Database:  req.Database,
+		User:      req.User,
+		SSLMode:   req.SSLMode,
+		Password:  password,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := r.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save connection profile: %w", err)
+	}
+
+	return toProfile(&record), nil
+}
+
+// List returns the user's profiles ordered by name
+func (r *Repository) List(ctx context.Context, userID string) ([]domainConnection.Profile, error) {
+	var records []profileRecord
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("name").
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list connection profiles: %w", err)
+	}
+
+	profiles := make([]domainConnection.Profile, len(records))
+	for i := range records {
+		profiles[i] = *toProfile(&records[i])
+	}
+	return profiles, nil
+}
+
+// Get returns a profile of the user
+func (r *Repository) Get(ctx context.Context, userID, id string) (*domainConnection.Profile, error) {
+	record, err := r.find(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return toProfile(record), nil
+}
+
+// Delete removes a profile of the user
+func (r *Repository) Delete(ctx context.Context, userID, id string) error {
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND id = ?", userID, id).
+		Delete(&profileRecord{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete connection profile: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrProfileNotFound
+	}
+	return nil
+}
+
+// ConnectionString decrypts the password of a profile and returns its connection string
+func (r *Repository) ConnectionString(ctx context.Context, userID, id string) (string, error) {
+	if r.cipher == nil {
+		return "", secrets.ErrNotConfigured
+	}
+
+	record, err := r.find(ctx, userID, id)
+	if err != nil {
+		return "", err
+	}
+
+	password, err := r.cipher.Decrypt(ctx, record.Password, []byte(record.ID))
+	if err != nil {
+		return "", err
+	}
+
+	return BuildConnectionString(toProfile(record), string(password)), nil
+}
+
+// RotateKeys rewraps every password not encrypted with the current master
+// key and returns the number of rotated profiles
+func (r *Repository) RotateKeys(ctx context.Context) (int, error) {
+	if r.cipher == nil {
+		return 0, secrets.ErrNotConfigured
+	}
+
+	var records []profileRecord
+	if err := r.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return 0, fmt.Errorf("failed to load connection profiles: %w", err)
+	}
+
+	rotated := 0
+	for _, record := range records {
+		if !r.cipher.NeedsRotation(record.Password) {
+			continue
+		}
+
+		password, err := r.cipher.Rotate(ctx, record.Password)
+		if err != nil {
+			return rotated, fmt.Errorf("failed to rotate connection profile %s: %w", record.ID, err)
+		}
+
+		err = r.db.WithContext(ctx).Model(&profileRecord{}).
+			Where("id = ?", record.ID).
+			Update("password", password).Error
+		if err != nil {
+			return rotated, fmt.Errorf("failed to save connection profile %s: %w", record.ID, err)
+		}
+		rotated++
+	}
+
+	return rotated, nil
+}
+
+func (r *Repository) find(ctx context.Context, userID, id string) (*profileRecord, error) {
+	var record profileRecord
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND id = ?", userID, id).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load connection profile: %w", err)
+	}
+	return &record, nil
+}
+
+func toProfile(record *profileRecord) *domainConnection.Profile {
+	return &domainConnection.Profile{
+		ID:        record.ID,
+		Name:      record.Name,
+		Host:      record.Host,
+		Port:      record.Port,
+		Database:  record.Database,
+		User:      record.User,
+		SSLMode:   record.SSLMode,
+		CreatedAt: record.CreatedAt,
+	}
+}
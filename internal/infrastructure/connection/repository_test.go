@@ -0,0 +1,129 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	domainConnection "github.com/mololab/alodb/internal/domain/connection"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
+	"github.com/mololab/alodb/internal/infrastructure/storage"
+)
+
+func newTestRepository(t *testing.T, store *storage.Store, keys ...[]byte) *Repository {
+	t.Helper()
+
+	var cipher *secrets.Cipher
+	if len(keys) > 0 {
+		keyring, err := secrets.NewKeyring(keys[0], keys[1:]...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cipher = secrets.NewCipher(keyring)
+	}
+
+	repo, err := NewRepository(store.DB(), cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func createProfile(t *testing.T, repo *Repository, name, password string) *domainConnection.Profile {
+	t.Helper()
+	profile, err := repo.Create(context.Background(), domainConnection.CreateRequest{
+		UserID:   "user-1",
+		Name:     name,
+		Host:     "db.example.com",
+		Port:     5432,
+		Database: "app",
+		User:     "app",
+		Password: password,
+		SSLMode:  domainConnection.SSLModeRequire,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+func TestRepositoryRotateKeys(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(storage.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+
+	before := newTestRepository(t, store, oldKey)
+	first := createProfile(t, before, "primary", "first-s3cret")
+	second := createProfile(t, before, "replica", "second-s3cret")
+
+	after := newTestRepository(t, store, newKey, oldKey)
+	third := createProfile(t, after, "analytics", "third-s3cret")
+
+	rotated, err := after.RotateKeys(ctx)
+	if err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+	if rotated != 2 {
+		t.Errorf("rotated = %d, want 2", rotated)
+	}
+
+	// every password now decrypts without the old key
+	retired := newTestRepository(t, store, newKey)
+	for _, tt := range []struct {
+		profile  *domainConnection.Profile
+		password string
+	}{
+		{first, "first-s3cret"},
+		{second, "second-s3cret"},
+		{third, "third-s3cret"},
+	} {
+		dsn, err := retired.ConnectionString(ctx, "user-1", tt.profile.ID)
+		if err != nil {
+			t.Errorf("ConnectionString(%s): %v", tt.profile.Name, err)
+			continue
+		}
+		if !strings.Contains(dsn, ":"+tt.password+"@") {
+			t.Errorf("ConnectionString(%s) = %s, want password %s", tt.profile.Name, dsn, tt.password)
+		}
+	}
+
+	if rotated, err := after.RotateKeys(ctx); err != nil || rotated != 0 {
+		t.Errorf("RotateKeys again = %d, %v, want nothing to rotate", rotated, err)
+	}
+}
+
+func TestRepositoryRotateKeysErrors(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(storage.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, err := newTestRepository(t, store).RotateKeys(ctx); !errors.Is(err, secrets.ErrNotConfigured) {
+		t.Errorf("without a cipher: err = %v, want %v", err, secrets.ErrNotConfigured)
+	}
+
+	createProfile(t, newTestRepository(t, store, newTestKey(t)), "primary", "s3cret")
+
+	// the key the profile was encrypted with was dropped before rotating
+	rotated, err := newTestRepository(t, store, newTestKey(t)).RotateKeys(ctx)
+	if !errors.Is(err, secrets.ErrUnknownKey) || rotated != 0 {
+		t.Errorf("RotateKeys = %d, %v, want %v", rotated, err, secrets.ErrUnknownKey)
+	}
+}
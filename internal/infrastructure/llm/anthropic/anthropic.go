@@ -0,0 +1,286 @@
+// Package anthropic implements model.LLM for the Anthropic Messages API.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mololab/alodb/internal/infrastructure/llm"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+const (
+	DefaultBaseURL   = "https://api.anthropic.com"
+	APIVersion       = "2023-06-01"
+	DefaultMaxTokens = 4096
+	defaultTimeout   = 2 * time.Minute
+)
+
+// Config configures the Anthropic client
+type Config struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// Model is an Anthropic Claude model
+type Model struct {
+	name       string
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewModel creates a model.LLM for the given Claude model name
+func NewModel(modelName string, cfg Config) (model.LLM, error) {
+	if modelName == "" {
+		return nil, fmt.Errorf("model name is required")
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	return &Model{
+		name:       modelName,
+		apiKey:     cfg.APIKey,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}, nil
+}
+
+// Name returns the model name
+func (m *Model) Name() string {
+	return m.name
+}
+
+// GenerateContent sends the request to the Messages API. Streaming is not
+// used, the complete response is yielded once.
+func (m *Model) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		yield(m.generate(ctx, req))
+	}
+}
+
+// generate performs a single Messages API call
+func (m *Model) generate(ctx context.Context, req *model.LLMRequest) (*model.LLMResponse, error) {
+	body, err := m.buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", m.apiKey)
+	httpReq.Header.Set("anthropic-version", APIVersion)
+
+	httpResp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("messages request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, apiError(httpResp.StatusCode, data)
+	}
+
+	var resp messagesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return toLLMResponse(&resp)
+}
+
+// buildRequest converts an ADK request into a Messages API request
+func (m *Model) buildRequest(req *model.LLMRequest) (*messagesRequest, error) {
+	modelName := req.Model
+	if modelName == "" {
+		modelName = m.name
+	}
+
+	body := &messagesRequest{
+		Model:     modelName,
+		MaxTokens: DefaultMaxTokens,
+		System:    llm.SystemInstruction(req.Config),
+	}
+
+	callIDs := llm.NewCallIDs()
+	for _, content := range req.Contents {
+		msg, err := toMessage(content, callIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(msg.Content) == 0 {
+			continue
+		}
+
+		// the API requires alternating roles, so consecutive turns are merged
+		if n := len(body.Messages); n > 0 && body.Messages[n-1].Role == msg.Role {
+			body.Messages[n-1].Content = append(body.Messages[n-1].Content, msg.Content...)
+			continue
+		}
+		body.Messages = append(body.Messages, msg)
+	}
+
+	for _, decl := range llm.FunctionDeclarations(req.Config) {
+		schema, err := llm.ParametersSchema(decl)
+		if err != nil {
+			return nil, err
+		}
+		body.Tools = append(body.Tools, toolDef{
+			Name:        decl.Name,
+			Description: decl.Description,
+			InputSchema: schema,
+		})
+	}
+
+	if cfg := req.Config; cfg != nil {
+		body.Temperature = cfg.Temperature
+		body.TopP = cfg.TopP
+		if cfg.MaxOutputTokens > 0 {
+			body.MaxTokens = int(cfg.MaxOutputTokens)
+		}
+		body.StopSequences = cfg.StopSequences
+	}
+
+	return body, nil
+}
+
+// toMessage converts a genai content into a message with text, tool_use and tool_result blocks
+func toMessage(content *genai.Content, callIDs *llm.CallIDs) (message, error) {
+	msg := message{Role: "user"}
+	if content == nil {
+		return msg, nil
+	}
+	if content.Role == string(genai.RoleModel) {
+		msg.Role = "assistant"
+	}
+
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			input, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				return msg, fmt.Errorf("failed to encode arguments of %s: %w", part.FunctionCall.Name, err)
+			}
+			if part.FunctionCall.Args == nil {
+				input = json.RawMessage(`{}`)
+			}
+			msg.Content = append(msg.Content, contentBlock{
+				Type:  "tool_use",
+				ID:    callIDs.ForCall(part.FunctionCall),
+				Name:  part.FunctionCall.Name,
+				Input: input,
+			})
+		case part.FunctionResponse != nil:
+			result, err := json.Marshal(part.FunctionResponse.Response)
+			if err != nil {
+				return msg, fmt.Errorf("failed to encode result of %s: %w", part.FunctionResponse.Name, err)
+			}
+			_, failed := part.FunctionResponse.Response["error"]
+			msg.Content = append(msg.Content, contentBlock{
+				Type:      "tool_result",
+				ToolUseID: callIDs.ForResponse(part.FunctionResponse),
+				Content:   string(result),
+				IsError:   failed,
+			})
+		case part.Text != "" && !part.Thought:
+			msg.Content = append(msg.Content, contentBlock{
+				Type: "text",
+				Text: part.Text,
+			})
+		}
+	}
+
+	return msg, nil
+}
+
+// toLLMResponse converts a Messages API response into an ADK response
+func toLLMResponse(resp *messagesResponse) (*model.LLMResponse, error) {
+	content := &genai.Content{Role: string(genai.RoleModel)}
+
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				content.Parts = append(content.Parts, genai.NewPartFromText(block.Text))
+			}
+		case "tool_use":
+			args := make(map[string]any)
+			if len(block.Input) > 0 {
+				if err := json.Unmarshal(block.Input, &args); err != nil {
+					return nil, fmt.Errorf("invalid input for %s: %w", block.Name, err)
+				}
+			}
+			content.Parts = append(content.Parts, &genai.Part{
+				FunctionCall: &genai.FunctionCall{
+					ID:   block.ID,
+					Name: block.Name,
+					Args: args,
+				},
+			})
+		}
+	}
+
+	return &model.LLMResponse{
+		Content:       content,
+		FinishReason:  finishReason(resp.StopReason),
+		UsageMetadata: llm.UsageMetadata(resp.Usage.InputTokens, resp.Usage.OutputTokens),
+		TurnComplete:  true,
+	}, nil
+}
+
+// finishReason maps Anthropic stop reasons to genai finish reasons
+func finishReason(reason string) genai.FinishReason {
+	switch reason {
+	case "end_turn", "tool_use", "stop_sequence":
+		return genai.FinishReasonStop
+	case "max_tokens":
+		return genai.FinishReasonMaxTokens
+	case "refusal":
+		return genai.FinishReasonSafety
+	default:
+		return genai.FinishReasonUnspecified
+	}
+}
+
+// apiError builds an error from an error response body
+func apiError(status int, body []byte) error {
+	var resp errorResponse
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Error.Message != "" {
+		return fmt.Errorf("messages request failed (status %d): %s", status, resp.Error.Message)
+	}
+	return fmt.Errorf("messages request failed (status %d)", status)
+}
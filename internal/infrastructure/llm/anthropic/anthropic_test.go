@@ -0,0 +1,288 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mololab/alodb/internal/infrastructure/llm"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// recording is a recorded Messages API response served by the replay server
+type recording struct {
+	status  int
+	fixture string // file in testdata holding the response body
+}
+
+// replayServer is a Messages API test double. It answers each request with
+// the next recording and keeps the decoded requests for assertions.
+type replayServer struct {
+	*httptest.Server
+	t          *testing.T
+	mu         sync.Mutex
+	recordings []recording
+	requests   []messagesRequest
+	headers    []http.Header
+}
+
+func newReplayServer(t *testing.T, recordings ...recording) *replayServer {
+	t.Helper()
+
+	s := &replayServer{t: t, recordings: recordings}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *replayServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+		s.t.Errorf("request %s %s, want POST /v1/messages", r.Method, r.URL.Path)
+	}
+
+	var req messagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.t.Errorf("decode request: %v", err)
+	}
+	s.requests = append(s.requests, req)
+	s.headers = append(s.headers, r.Header.Clone())
+
+	if len(s.recordings) == 0 {
+		s.t.Errorf("unexpected request %d, no recordings left", len(s.requests))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rec := s.recordings[0]
+	s.recordings = s.recordings[1:]
+
+	body, err := os.ReadFile(filepath.Join("testdata", rec.fixture))
+	if err != nil {
+		s.t.Errorf("read fixture: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rec.status)
+	w.Write(body)
+}
+
+func (s *replayServer) model(t *testing.T) model.LLM {
+	t.Helper()
+
+	m, err := NewModel("claude-haiku-4-5", Config{APIKey: "test-key", BaseURL: s.URL + "/", HTTPClient: s.Client()})
+	if err != nil {
+		t.Fatalf("NewModel: %v", err)
+	}
+	return m
+}
+
+// generate runs a request and returns its single response
+func generate(t *testing.T, m model.LLM, req *model.LLMRequest) (*model.LLMResponse, error) {
+	t.Helper()
+
+	var resp *model.LLMResponse
+	var err error
+	for r, e := range m.GenerateContent(context.Background(), req, false) {
+		resp, err = r, e
+	}
+	return resp, err
+}
+
+func TestGenerateContentText(t *testing.T) {
+	server := newReplayServer(t, recording{http.StatusOK, "text.json"})
+
+	resp, err := generate(t, server.model(t), &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("how many users?", genai.RoleUser)},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText("You write SQL.", genai.RoleUser),
+			StopSequences:     []string{"</sql>"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent: %v", err)
+	}
+
+	header := server.headers[0]
+	if header.Get("x-api-key") != "test-key" || header.Get("anthropic-version") != APIVersion {
+		t.Errorf("headers = %v", header)
+	}
+	req := server.requests[0]
+	if req.Model != "claude-haiku-4-5" || req.MaxTokens != DefaultMaxTokens || req.System != "You write SQL." {
+		t.Errorf("request = %+v", req)
+	}
+	if len(req.StopSequences) != 1 || len(req.Messages) != 1 || req.Messages[0].Role != "user" ||
+		req.Messages[0].Content[0].Type != "text" || req.Messages[0].Content[0].Text != "how many users?" {
+		t.Errorf("messages = %+v", req.Messages)
+	}
+
+	if !strings.Contains(llm.ContentText(resp.Content), "SELECT count(*) FROM public.users") {
+		t.Errorf("text = %q", llm.ContentText(resp.Content))
+	}
+	if resp.FinishReason != genai.FinishReasonStop || !resp.TurnComplete {
+		t.Errorf("finish = %v, complete = %v", resp.FinishReason, resp.TurnComplete)
+	}
+	usage := resp.UsageMetadata
+	if usage == nil || usage.PromptTokenCount != 2095 || usage.CandidatesTokenCount != 48 || usage.TotalTokenCount != 2143 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestGenerateContentToolRoundTrip(t *testing.T) {
+	server := newReplayServer(t,
+		recording{http.StatusOK, "tool_use.json"},
+		recording{http.StatusOK, "tool_use_query.json"},
+		recording{http.StatusOK, "text.json"},
+	)
+	m := server.model(t)
+
+	config := &genai.GenerateContentConfig{
+		Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{
+			{Name: "read_schema", Description: "Reads the schema"},
+			{
+				Name: "query_executor",
+				Parameters: &genai.Schema{
+					Type:       genai.TypeObject,
+					Properties: map[string]*genai.Schema{"query": {Type: genai.TypeString}},
+				},
+			},
+		}}},
+	}
+	contents := []*genai.Content{genai.NewContentFromText("orders by status", genai.RoleUser)}
+
+	// the first turn asks for the schema
+	resp, err := generate(t, m, &model.LLMRequest{Contents: contents, Config: config})
+	if err != nil {
+		t.Fatalf("turn 1: %v", err)
+	}
+	if len(resp.Content.Parts) != 2 || resp.Content.Parts[1].FunctionCall == nil {
+		t.Fatalf("turn 1 parts = %+v", resp.Content.Parts)
+	}
+	call := resp.Content.Parts[1].FunctionCall
+	if call.ID != "toolu_01A09q90qw90lq917835lq9" || call.Name != "read_schema" || len(call.Args) != 0 {
+		t.Errorf("turn 1 call = %+v", call)
+	}
+
+	contents = append(contents, resp.Content, &genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{{
+		FunctionResponse: &genai.FunctionResponse{ID: call.ID, Name: call.Name, Response: map[string]any{"status": "success"}},
+	}}})
+
+	// the second turn runs a query, which fails
+	resp, err = generate(t, m, &model.LLMRequest{Contents: contents, Config: config})
+	if err != nil {
+		t.Fatalf("turn 2: %v", err)
+	}
+	call = resp.Content.Parts[0].FunctionCall
+	if call == nil || call.Name != "query_executor" || call.Args["query"] != "SELECT status, count(*) FROM public.orders GROUP BY status" {
+		t.Fatalf("turn 2 call = %+v", call)
+	}
+
+	contents = append(contents, resp.Content, &genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{{
+		FunctionResponse: &genai.FunctionResponse{ID: call.ID, Name: call.Name, Response: map[string]any{"error": "permission denied"}},
+	}}})
+
+	if _, err := generate(t, m, &model.LLMRequest{Contents: contents, Config: config}); err != nil {
+		t.Fatalf("turn 3: %v", err)
+	}
+
+	tools := server.requests[0].Tools
+	if len(tools) != 2 || string(tools[0].InputSchema) != `{"type":"object","properties":{}}` {
+		t.Errorf("tools = %+v", tools)
+	}
+
+	msgs := server.requests[2].Messages
+	if len(msgs) != 5 {
+		t.Fatalf("turn 3 messages = %+v", msgs)
+	}
+	use := msgs[1].Content[1]
+	if msgs[1].Role != "assistant" || use.Type != "tool_use" || use.ID != "toolu_01A09q90qw90lq917835lq9" || string(use.Input) != "{}" {
+		t.Errorf("tool_use block = %+v", use)
+	}
+	result := msgs[2].Content[0]
+	if msgs[2].Role != "user" || result.Type != "tool_result" || result.ToolUseID != use.ID ||
+		result.Content != `{"status":"success"}` || result.IsError {
+		t.Errorf("tool_result block = %+v", result)
+	}
+	failed := msgs[4].Content[0]
+	if failed.ToolUseID != "toolu_01T1x1fJ34qAmk2tNTrN7Up6" || !failed.IsError {
+		t.Errorf("failed tool_result block = %+v", failed)
+	}
+}
+
+func TestGenerateContentMaxTokens(t *testing.T) {
+	server := newReplayServer(t, recording{http.StatusOK, "max_tokens.json"})
+
+	resp, err := generate(t, server.model(t), &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("describe the schema", genai.RoleUser)},
+		Config:   &genai.GenerateContentConfig{MaxOutputTokens: 16},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent: %v", err)
+	}
+	if server.requests[0].MaxTokens != 16 {
+		t.Errorf("max_tokens = %d, want 16", server.requests[0].MaxTokens)
+	}
+	if resp.FinishReason != genai.FinishReasonMaxTokens {
+		t.Errorf("finish = %v, want max tokens", resp.FinishReason)
+	}
+}
+
+func TestGenerateContentAPIError(t *testing.T) {
+	tests := []struct {
+		recording recording
+		want      string
+	}{
+		{recording{529, "error_overloaded.json"}, "messages request failed (status 529): Overloaded"},
+		{recording{http.StatusUnauthorized, "error_authentication.json"}, "messages request failed (status 401): invalid x-api-key"},
+		// a proxy error page is not an API error body
+		{recording{http.StatusBadGateway, "error_proxy.html"}, "messages request failed (status 502)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.recording.fixture, func(t *testing.T) {
+			server := newReplayServer(t, tt.recording)
+
+			_, err := generate(t, server.model(t), &model.LLMRequest{
+				Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)},
+			})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildRequestMergesConsecutiveRoles(t *testing.T) {
+	m := &Model{name: "claude-haiku-4-5"}
+
+	req, err := m.buildRequest(&model.LLMRequest{Contents: []*genai.Content{
+		genai.NewContentFromText("first", genai.RoleUser),
+		genai.NewContentFromText("second", genai.RoleUser),
+		{Role: genai.RoleModel, Parts: []*genai.Part{{Text: "hidden", Thought: true}}},
+		genai.NewContentFromText("answer", genai.RoleModel),
+	}})
+	if err != nil {
+		t.Fatalf("buildRequest: %v", err)
+	}
+
+	got, _ := json.Marshal(req.Messages)
+	want := `[{"role":"user","content":[{"type":"text","text":"first"},{"type":"text","text":"second"}]},` +
+		`{"role":"assistant","content":[{"type":"text","text":"answer"}]}]`
+	if string(got) != want {
+		t.Errorf("messages = %s, want %s", got, want)
+	}
+}
+
+func TestNewModelRequiresAPIKey(t *testing.T) {
+	if _, err := NewModel("claude-haiku-4-5", Config{}); err == nil {
+		t.Error("NewModel without an API key succeeded")
+	}
+}
@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// SystemInstruction returns the text of the request's system instruction
+func SystemInstruction(cfg *genai.GenerateContentConfig) string {
+	if cfg == nil || cfg.SystemInstruction == nil {
+		return ""
+	}
+	return ContentText(cfg.SystemInstruction)
+}
+
+// ContentText joins all text parts of a content
+func ContentText(content *genai.Content) string {
+	if content == nil {
+		return ""
+	}
+
+	var texts []string
+	for _, part := range content.Parts {
+		if part.Text != "" && !part.Thought {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// FunctionDeclarations returns every function declared in the request's tools
+func FunctionDeclarations(cfg *genai.GenerateContentConfig) []*genai.FunctionDeclaration {
+	if cfg == nil {
+		return nil
+	}
+
+	var decls []*genai.FunctionDeclaration
+	for _, t := range cfg.Tools {
+		if t == nil {
+			continue
+		}
+		decls = append(decls, t.FunctionDeclarations...)
+	}
+	return decls
+}
+
+// ParametersSchema returns the JSON schema of a function's parameters
+func ParametersSchema(decl *genai.FunctionDeclaration) (json.RawMessage, error) {
+	var schema any
+	switch {
+	case decl.ParametersJsonSchema != nil:
+		schema = decl.ParametersJsonSchema
+	case decl.Parameters != nil:
+		schema = genaiSchemaToJSON(decl.Parameters)
+	default:
+		return json.RawMessage(`{"type":"object","properties":{}}`), nil
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode parameters of %s: %w", decl.Name, err)
+	}
+	return data, nil
+}
+
+// genaiSchemaToJSON converts a genai schema, which uses upper case type names, to JSON schema
+func genaiSchemaToJSON(s *genai.Schema) map[string]any {
+	out := make(map[string]any)
+	if s.Type != "" {
+		out["type"] = strings.ToLower(string(s.Type))
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if s.Items != nil {
+		out["items"] = genaiSchemaToJSON(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		props := make(map[string]any, len(s.Properties))
+		for name, prop := range s.Properties {
+			props[name] = genaiSchemaToJSON(prop)
+		}
+		out["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	if len(s.AnyOf) > 0 {
+		anyOf := make([]any, len(s.AnyOf))
+		for i, sub := range s.AnyOf {
+			anyOf[i] = genaiSchemaToJSON(sub)
+		}
+		out["anyOf"] = anyOf
+	}
+	return out
+}
+
+// CallIDs assigns IDs to function calls that arrive without one, so that
+// providers requiring IDs can pair calls with their responses
+type CallIDs struct {
+	next    int
+	pending map[string][]string
+}
+
+// NewCallIDs creates an empty call ID tracker
+func NewCallIDs() *CallIDs {
+	return &CallIDs{pending: make(map[string][]string)}
+}
+
+// ForCall returns the ID of a function call, generating one if missing
+func (c *CallIDs) ForCall(call *genai.FunctionCall) string {
+	if call.ID != "" {
+		return call.ID
+	}
+	c.next++
+	id := fmt.Sprintf("call_%d", c.next)
+	c.pending[call.Name] = append(c.pending[call.Name], id)
+	return id
+}
+
+// ForResponse returns the ID of the call a function response answers
+func (c *CallIDs) ForResponse(resp *genai.FunctionResponse) string {
+	if resp.ID != "" {
+		return resp.ID
+	}
+	ids := c.pending[resp.Name]
+	if len(ids) == 0 {
+		c.next++
+		return fmt.Sprintf("call_%d", c.next)
+	}
+	c.pending[resp.Name] = ids[1:]
+	return ids[0]
+}
+
+// UsageMetadata builds usage metadata from provider token counts
+func UsageMetadata(promptTokens, completionTokens int) *genai.GenerateContentResponseUsageMetadata {
+	return &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     int32(promptTokens),
+		CandidatesTokenCount: int32(completionTokens),
+		TotalTokenCount:      int32(promptTokens + completionTokens),
+	}
+}
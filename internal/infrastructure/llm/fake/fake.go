@@ -0,0 +1,184 @@
+// Package fake implements a scripted model.LLM for deterministic offline testing.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+
+	"github.com/mololab/alodb/internal/infrastructure/llm"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// streamChunkSize is the number of runes per partial response when streaming
+const streamChunkSize = 16
+
+// ErrScriptExhausted is returned once every scripted response was used
+var ErrScriptExhausted = errors.New("fake model script exhausted")
+
+// Model replays scripted responses, one per GenerateContent call. Every
+// session replays the script from its first response, so concurrent and later
+// conversations on the same agent do not consume each other's responses.
+type Model struct {
+	name   string
+	script *Script
+
+	mu        sync.Mutex
+	positions map[string]*position // by session ID
+}
+
+// position is how far a session has replayed the script
+type position struct {
+	next  int
+	calls int
+}
+
+// NewModel creates a fake model replaying the given script
+func NewModel(modelName string, script *Script) (model.LLM, error) {
+	if script == nil {
+		return nil, fmt.Errorf("script is required")
+	}
+	if err := script.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Model{
+		name:      modelName,
+		script:    script,
+		positions: make(map[string]*position),
+	}, nil
+}
+
+// NewModelFromFile creates a fake model replaying a script file
+func NewModelFromFile(modelName, path string) (model.LLM, error) {
+	script, err := LoadScript(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewModel(modelName, script)
+}
+
+// Name returns the model name
+func (m *Model) Name() string {
+	return m.name
+}
+
+// GenerateContent yields the next scripted response. When streaming, the text
+// is first yielded in partial chunks, like a streaming provider would.
+func (m *Model) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		if err := ctx.Err(); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		step, call, err := m.nextStep(sessionID(ctx))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		if step.Error != "" {
+			yield(nil, errors.New(step.Error))
+			return
+		}
+
+		if stream {
+			for _, chunk := range chunkText(step.Text, streamChunkSize) {
+				partial := &model.LLMResponse{
+					Content: genai.NewContentFromText(chunk, genai.RoleModel),
+					Partial: true,
+				}
+				if !yield(partial, nil) {
+					return
+				}
+			}
+		}
+
+		yield(toLLMResponse(step, call), nil)
+	}
+}
+
+// chunkText splits text into chunks of at most size runes
+func chunkText(text string, size int) []string {
+	var chunks []string
+	runes := []rune(text)
+	for len(runes) > 0 {
+		n := min(size, len(runes))
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return chunks
+}
+
+// sessionID returns the session of the agent invocation calling the model.
+// Calls made outside an invocation share the empty session.
+func sessionID(ctx context.Context) string {
+	if ictx, ok := ctx.(agent.InvocationContext); ok && ictx.Session() != nil {
+		return ictx.Session().ID()
+	}
+	return ""
+}
+
+// nextStep returns the next scripted step of a session and the call number
+func (m *Model) nextStep(session string) (Step, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pos, ok := m.positions[session]
+	if !ok {
+		pos = &position{}
+		m.positions[session] = pos
+	}
+
+	if pos.next >= len(m.script.Responses) {
+		if !m.script.Loop {
+			return Step{}, 0, ErrScriptExhausted
+		}
+		pos.next = 0
+	}
+
+	step := m.script.Responses[pos.next]
+	pos.next++
+	pos.calls++
+
+	return step, pos.calls, nil
+}
+
+// toLLMResponse converts a scripted step into an ADK response
+func toLLMResponse(step Step, call int) *model.LLMResponse {
+	content := &genai.Content{Role: string(genai.RoleModel)}
+
+	if step.Text != "" {
+		content.Parts = append(content.Parts, genai.NewPartFromText(step.Text))
+	}
+
+	for i, tc := range step.ToolCalls {
+		args := tc.Args
+		if args == nil {
+			args = make(map[string]any)
+		}
+		content.Parts = append(content.Parts, &genai.Part{
+			FunctionCall: &genai.FunctionCall{
+				ID:   fmt.Sprintf("fake_call_%d_%d", call, i+1),
+				Name: tc.Name,
+				Args: args,
+			},
+		})
+	}
+
+	resp := &model.LLMResponse{
+		Content:      content,
+		FinishReason: genai.FinishReasonStop,
+		TurnComplete: true,
+	}
+	if step.Usage != nil {
+		resp.UsageMetadata = llm.UsageMetadata(step.Usage.PromptTokens, step.Usage.OutputTokens)
+	}
+	return resp
+}
@@ -0,0 +1,187 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mololab/alodb/internal/infrastructure/llm"
+
+	"google.golang.org/adk/model"
+)
+
+// generate runs one model call and returns the complete response and the partial ones
+func generate(t *testing.T, m model.LLM, stream bool) (*model.LLMResponse, []*model.LLMResponse, error) {
+	t.Helper()
+
+	var final *model.LLMResponse
+	var partials []*model.LLMResponse
+	for resp, err := range m.GenerateContent(context.Background(), &model.LLMRequest{}, stream) {
+		if err != nil {
+			return nil, partials, err
+		}
+		if resp.Partial {
+			partials = append(partials, resp)
+			continue
+		}
+		final = resp
+	}
+	return final, partials, nil
+}
+
+func TestLoadScript(t *testing.T) {
+	for _, name := range []string{"example.yaml", "example.json"} {
+		t.Run(name, func(t *testing.T) {
+			script, err := LoadScript(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("LoadScript: %v", err)
+			}
+			if len(script.Responses) < 2 {
+				t.Errorf("got %d responses", len(script.Responses))
+			}
+		})
+	}
+}
+
+func TestLoadScriptErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(dir, "missing.yaml"), "failed to read script"},
+		{write("script.txt", "responses: []"), "unsupported script format"},
+		{write("broken.json", "{"), "failed to parse script"},
+		{write("empty.yaml", "responses: []"), "script has no responses"},
+		{write("blank.yaml", "responses:\n  - usage: {prompt_tokens: 1}"), "response 1 is empty"},
+		{write("mixed.yaml", "responses:\n  - text: hi\n    error: boom"), "response 1 mixes an error with content"},
+		{write("unnamed.yaml", "responses:\n  - tool_calls:\n      - args: {}"), "response 1 has a tool call without a name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			if _, err := LoadScript(tt.path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateContentReplaysScript(t *testing.T) {
+	m, err := NewModelFromFile("fake", filepath.Join("testdata", "example.yaml"))
+	if err != nil {
+		t.Fatalf("NewModelFromFile: %v", err)
+	}
+
+	resp, _, err := generate(t, m, false)
+	if err != nil {
+		t.Fatalf("call 1: %v", err)
+	}
+	call := resp.Content.Parts[0].FunctionCall
+	if call == nil || call.Name != "read_schema" || call.ID != "fake_call_1_1" {
+		t.Errorf("call 1 = %+v", resp.Content.Parts[0])
+	}
+	if resp.UsageMetadata.PromptTokenCount != 1800 || resp.UsageMetadata.TotalTokenCount != 1812 {
+		t.Errorf("usage = %+v", resp.UsageMetadata)
+	}
+
+	resp, _, err = generate(t, m, false)
+	if err != nil {
+		t.Fatalf("call 2: %v", err)
+	}
+	call = resp.Content.Parts[0].FunctionCall
+	if call == nil || call.Args["query"] != "SELECT count(*) FROM public.users" || call.ID != "fake_call_2_1" {
+		t.Errorf("call 2 = %+v", call)
+	}
+
+	resp, partials, err := generate(t, m, true)
+	if err != nil {
+		t.Fatalf("call 3: %v", err)
+	}
+	text := llm.ContentText(resp.Content)
+	var streamed strings.Builder
+	for _, p := range partials {
+		streamed.WriteString(llm.ContentText(p.Content))
+	}
+	if len(partials) < 2 || streamed.String() != text {
+		t.Errorf("streamed %d chunks %q, want the chunks of %q", len(partials), streamed.String(), text)
+	}
+
+	if _, _, err := generate(t, m, false); !errors.Is(err, ErrScriptExhausted) {
+		t.Errorf("call 4 err = %v, want ErrScriptExhausted", err)
+	}
+}
+
+func TestGenerateContentLoopAndError(t *testing.T) {
+	m, err := NewModelFromFile("fake", filepath.Join("testdata", "example.json"))
+	if err != nil {
+		t.Fatalf("NewModelFromFile: %v", err)
+	}
+
+	for round := 0; round < 2; round++ {
+		if _, _, err := generate(t, m, false); err != nil {
+			t.Fatalf("round %d text: %v", round, err)
+		}
+		if _, _, err := generate(t, m, false); err == nil || err.Error() != "simulated provider outage" {
+			t.Fatalf("round %d err = %v, want the scripted error", round, err)
+		}
+	}
+}
+
+func TestNextStepPerSession(t *testing.T) {
+	m, err := NewModel("fake", &Script{Responses: []Step{{Text: "first"}, {Text: "second"}}})
+	if err != nil {
+		t.Fatalf("NewModel: %v", err)
+	}
+	fake := m.(*Model)
+
+	steps := []struct {
+		session string
+		want    string
+	}{
+		{"a", "first"},
+		{"b", "first"},
+		{"a", "second"},
+		{"b", "second"},
+		{"c", "first"},
+	}
+	for _, s := range steps {
+		step, _, err := fake.nextStep(s.session)
+		if err != nil || step.Text != s.want {
+			t.Fatalf("session %s: step %q, err %v, want %q", s.session, step.Text, err, s.want)
+		}
+	}
+
+	if _, _, err := fake.nextStep("a"); !errors.Is(err, ErrScriptExhausted) {
+		t.Errorf("err = %v, want ErrScriptExhausted once session a used up the script", err)
+	}
+	if step, _, err := fake.nextStep("d"); err != nil || step.Text != "first" {
+		t.Errorf("new session after exhaustion: step %q, err %v", step.Text, err)
+	}
+}
+
+func TestGenerateContentCanceled(t *testing.T) {
+	m, err := NewModel("fake", &Script{Responses: []Step{{Text: "never"}}})
+	if err != nil {
+		t.Fatalf("NewModel: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range m.GenerateContent(ctx, &model.LLMRequest{}, false) {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	}
+}
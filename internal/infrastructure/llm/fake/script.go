@@ -0,0 +1,91 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Script is a sequence of responses replayed by the fake model
+type Script struct {
+	// Responses are returned in order, one per model call
+	Responses []Step `json:"responses" yaml:"responses"`
+	// Loop restarts from the first response once all were used
+	Loop bool `json:"loop,omitempty" yaml:"loop,omitempty"`
+}
+
+// Step is a single scripted model response. Exactly one of Text, ToolCalls
+// or Error is expected, Text and ToolCalls may be combined.
+type Step struct {
+	Text      string     `json:"text,omitempty" yaml:"text,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty" yaml:"tool_calls,omitempty"`
+	Error     string     `json:"error,omitempty" yaml:"error,omitempty"`
+	// Usage is reported as the token usage of the response
+	Usage *Usage `json:"usage,omitempty" yaml:"usage,omitempty"`
+}
+
+// Usage is the scripted token usage of a response
+type Usage struct {
+	PromptTokens int `json:"prompt_tokens" yaml:"prompt_tokens"`
+	OutputTokens int `json:"output_tokens" yaml:"output_tokens"`
+}
+
+// ToolCall is a scripted function call
+type ToolCall struct {
+	Name string         `json:"name" yaml:"name"`
+	Args map[string]any `json:"args,omitempty" yaml:"args,omitempty"`
+}
+
+// LoadScript reads a script from a .json, .yaml or .yml file
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+
+	var script Script
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &script)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &script)
+	default:
+		return nil, fmt.Errorf("unsupported script format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+
+	if err := script.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &script, nil
+}
+
+// Validate checks that every step produces a response
+func (s *Script) Validate() error {
+	if len(s.Responses) == 0 {
+		return fmt.Errorf("script has no responses")
+	}
+
+	for i, step := range s.Responses {
+		if step.Text == "" && len(step.ToolCalls) == 0 && step.Error == "" {
+			return fmt.Errorf("response %d is empty", i+1)
+		}
+		if step.Error != "" && (step.Text != "" || len(step.ToolCalls) > 0) {
+			return fmt.Errorf("response %d mixes an error with content", i+1)
+		}
+		for _, call := range step.ToolCalls {
+			if call.Name == "" {
+				return fmt.Errorf("response %d has a tool call without a name", i+1)
+			}
+		}
+	}
+
+	return nil
+}
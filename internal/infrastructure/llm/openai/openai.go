@@ -0,0 +1,288 @@
+// Package openai implements model.LLM for the OpenAI chat completions API.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mololab/alodb/internal/infrastructure/llm"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+const (
+	DefaultBaseURL = "https://api.openai.com/v1"
+	defaultTimeout = 2 * time.Minute
+)
+
+// Config configures the OpenAI client
+type Config struct {
+	APIKey string
+	// BaseURL of the API, defaults to DefaultBaseURL. Any server speaking the
+	// OpenAI chat completions protocol can be used.
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// Model is an OpenAI chat completions model
+type Model struct {
+	name       string
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewModel creates a model.LLM for the given OpenAI model name
+func NewModel(modelName string, cfg Config) (model.LLM, error) {
+	if modelName == "" {
+		return nil, fmt.Errorf("model name is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	return &Model{
+		name:       modelName,
+		apiKey:     cfg.APIKey,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}, nil
+}
+
+// Name returns the model name
+func (m *Model) Name() string {
+	return m.name
+}
+
+// GenerateContent sends the request to the chat completions endpoint. Streaming is
+// not used, the complete response is yielded once.
+func (m *Model) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		yield(m.generate(ctx, req))
+	}
+}
+
+// generate performs a single chat completion call
+func (m *Model) generate(ctx context.Context, req *model.LLMRequest) (*model.LLMResponse, error) {
+	body, err := m.buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	httpResp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("chat completion request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, apiError(httpResp.StatusCode, data)
+	}
+
+	var resp chatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return toLLMResponse(&resp)
+}
+
+// buildRequest converts an ADK request into a chat completions request
+func (m *Model) buildRequest(req *model.LLMRequest) (*chatRequest, error) {
+	modelName := req.Model
+	if modelName == "" {
+		modelName = m.name
+	}
+
+	body := &chatRequest{Model: modelName}
+
+	if system := llm.SystemInstruction(req.Config); system != "" {
+		body.Messages = append(body.Messages, chatMessage{Role: "system", Content: system})
+	}
+
+	callIDs := llm.NewCallIDs()
+	for _, content := range req.Contents {
+		messages, err := toMessages(content, callIDs)
+		if err != nil {
+			return nil, err
+		}
+		body.Messages = append(body.Messages, messages...)
+	}
+
+	for _, decl := range llm.FunctionDeclarations(req.Config) {
+		params, err := llm.ParametersSchema(decl)
+		if err != nil {
+			return nil, err
+		}
+		body.Tools = append(body.Tools, chatTool{
+			Type: "function",
+			Function: chatFunction{
+				Name:        decl.Name,
+				Description: decl.Description,
+				Parameters:  params,
+			},
+		})
+	}
+
+	if cfg := req.Config; cfg != nil {
+		body.Temperature = cfg.Temperature
+		body.TopP = cfg.TopP
+		if cfg.MaxOutputTokens > 0 {
+			body.MaxTokens = int(cfg.MaxOutputTokens)
+		}
+		body.Stop = cfg.StopSequences
+	}
+
+	return body, nil
+}
+
+// toMessages converts a genai content into chat messages. Function responses
+// become separate tool messages.
+func toMessages(content *genai.Content, callIDs *llm.CallIDs) ([]chatMessage, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	role := "user"
+	if content.Role == string(genai.RoleModel) {
+		role = "assistant"
+	}
+
+	var messages []chatMessage
+	main := chatMessage{Role: role}
+	var texts []string
+
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			args, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode arguments of %s: %w", part.FunctionCall.Name, err)
+			}
+			main.ToolCalls = append(main.ToolCalls, chatToolCall{
+				ID:   callIDs.ForCall(part.FunctionCall),
+				Type: "function",
+				Function: chatToolCallFunction{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(args),
+				},
+			})
+		case part.FunctionResponse != nil:
+			result, err := json.Marshal(part.FunctionResponse.Response)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode result of %s: %w", part.FunctionResponse.Name, err)
+			}
+			messages = append(messages, chatMessage{
+				Role:       "tool",
+				ToolCallID: callIDs.ForResponse(part.FunctionResponse),
+				Content:    string(result),
+			})
+		case part.Text != "" && !part.Thought:
+			texts = append(texts, part.Text)
+		}
+	}
+
+	main.Content = strings.Join(texts, "\n")
+	if main.Content != "" || len(main.ToolCalls) > 0 {
+		messages = append([]chatMessage{main}, messages...)
+	}
+
+	return messages, nil
+}
+
+// toLLMResponse converts the first choice into an ADK response
+func toLLMResponse(resp *chatResponse) (*model.LLMResponse, error) {
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("chat completion returned no choices")
+	}
+	choice := resp.Choices[0]
+
+	content := &genai.Content{Role: string(genai.RoleModel)}
+	if choice.Message.Content != "" {
+		content.Parts = append(content.Parts, genai.NewPartFromText(choice.Message.Content))
+	}
+
+	for _, call := range choice.Message.ToolCalls {
+		args := make(map[string]any)
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments for %s: %w", call.Function.Name, err)
+			}
+		}
+		content.Parts = append(content.Parts, &genai.Part{
+			FunctionCall: &genai.FunctionCall{
+				ID:   call.ID,
+				Name: call.Function.Name,
+				Args: args,
+			},
+		})
+	}
+
+	llmResp := &model.LLMResponse{
+		Content:      content,
+		FinishReason: finishReason(choice.FinishReason),
+		TurnComplete: true,
+	}
+	if resp.Usage != nil {
+		llmResp.UsageMetadata = llm.UsageMetadata(resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	return llmResp, nil
+}
+
+// finishReason maps OpenAI finish reasons to genai finish reasons
+func finishReason(reason string) genai.FinishReason {
+	switch reason {
+	case "stop", "tool_calls", "function_call":
+		return genai.FinishReasonStop
+	case "length":
+		return genai.FinishReasonMaxTokens
+	case "content_filter":
+		return genai.FinishReasonSafety
+	default:
+		return genai.FinishReasonUnspecified
+	}
+}
+
+// apiError builds an error from an error response body
+func apiError(status int, body []byte) error {
+	var resp errorResponse
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Error.Message != "" {
+		return fmt.Errorf("chat completion failed (status %d): %s", status, resp.Error.Message)
+	}
+	return fmt.Errorf("chat completion failed (status %d)", status)
+}
@@ -0,0 +1,285 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mololab/alodb/internal/infrastructure/llm"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// stubServer answers every chat completion with status and body and records
+// the last decoded request
+type stubServer struct {
+	*httptest.Server
+	request chatRequest
+	header  http.Header
+}
+
+func newStubServer(t *testing.T, status int, body string) *stubServer {
+	t.Helper()
+
+	s := &stubServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s, want /v1/chat/completions", r.URL.Path)
+		}
+		s.header = r.Header.Clone()
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &s.request); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubServer) model(t *testing.T) model.LLM {
+	t.Helper()
+
+	m, err := NewModel("gpt-test", Config{APIKey: "test-key", BaseURL: s.URL + "/v1/", HTTPClient: s.Client()})
+	if err != nil {
+		t.Fatalf("NewModel: %v", err)
+	}
+	return m
+}
+
+// generate runs a request and returns its single response
+func generate(t *testing.T, m model.LLM, req *model.LLMRequest) (*model.LLMResponse, error) {
+	t.Helper()
+
+	var resp *model.LLMResponse
+	var err error
+	for r, e := range m.GenerateContent(context.Background(), req, false) {
+		resp, err = r, e
+	}
+	return resp, err
+}
+
+func TestGenerateContentText(t *testing.T) {
+	server := newStubServer(t, http.StatusOK, `{
+		"id": "chatcmpl-1",
+		"choices": [{"index": 0, "message": {"role": "assistant", "content": "SELECT 1"}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}
+	}`)
+
+	temperature := float32(0.2)
+	resp, err := generate(t, server.model(t), &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("count users", genai.RoleUser)},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText("You write SQL.", genai.RoleUser),
+			Temperature:       &temperature,
+			MaxOutputTokens:   256,
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent: %v", err)
+	}
+
+	if got := server.header.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("Authorization = %q", got)
+	}
+	req := server.request
+	if req.Model != "gpt-test" || req.MaxTokens != 256 || req.Temperature == nil || *req.Temperature != 0.2 {
+		t.Errorf("request = %+v", req)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[0].Content != "You write SQL." ||
+		req.Messages[1].Role != "user" || req.Messages[1].Content != "count users" {
+		t.Errorf("messages = %+v", req.Messages)
+	}
+
+	if got := llm.ContentText(resp.Content); got != "SELECT 1" {
+		t.Errorf("text = %q", got)
+	}
+	if resp.FinishReason != genai.FinishReasonStop || !resp.TurnComplete {
+		t.Errorf("finish = %v, complete = %v", resp.FinishReason, resp.TurnComplete)
+	}
+	usage := resp.UsageMetadata
+	if usage == nil || usage.PromptTokenCount != 12 || usage.CandidatesTokenCount != 3 || usage.TotalTokenCount != 15 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestGenerateContentToolCalls(t *testing.T) {
+	server := newStubServer(t, http.StatusOK, `{
+		"choices": [{
+			"message": {
+				"role": "assistant",
+				"content": "",
+				"tool_calls": [{"id": "call_abc", "type": "function", "function": {"name": "query_executor", "arguments": "{\"query\":\"SELECT 1\"}"}}]
+			},
+			"finish_reason": "tool_calls"
+		}]
+	}`)
+
+	resp, err := generate(t, server.model(t), &model.LLMRequest{
+		Contents: []*genai.Content{
+			genai.NewContentFromText("how many users?", genai.RoleUser),
+			{Role: genai.RoleModel, Parts: []*genai.Part{{FunctionCall: &genai.FunctionCall{Name: "read_schema", Args: map[string]any{}}}}},
+			{Role: genai.RoleUser, Parts: []*genai.Part{{FunctionResponse: &genai.FunctionResponse{Name: "read_schema", Response: map[string]any{"status": "success"}}}}},
+		},
+		Config: &genai.GenerateContentConfig{
+			Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{
+				Name:        "query_executor",
+				Description: "Runs a query",
+				Parameters: &genai.Schema{
+					Type:       genai.TypeObject,
+					Properties: map[string]*genai.Schema{"query": {Type: genai.TypeString}},
+					Required:   []string{"query"},
+				},
+			}}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent: %v", err)
+	}
+
+	msgs := server.request.Messages
+	if len(msgs) != 3 {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	call := msgs[1]
+	if call.Role != "assistant" || len(call.ToolCalls) != 1 || call.ToolCalls[0].Function.Name != "read_schema" {
+		t.Fatalf("tool call message = %+v", call)
+	}
+	if msgs[2].Role != "tool" || msgs[2].ToolCallID != call.ToolCalls[0].ID || msgs[2].Content != `{"status":"success"}` {
+		t.Errorf("tool result message = %+v, want the ID %q", msgs[2], call.ToolCalls[0].ID)
+	}
+
+	tools := server.request.Tools
+	if len(tools) != 1 || tools[0].Type != "function" || tools[0].Function.Name != "query_executor" {
+		t.Fatalf("tools = %+v", tools)
+	}
+	var params map[string]any
+	if err := json.Unmarshal(tools[0].Function.Parameters, &params); err != nil || params["type"] != "object" {
+		t.Errorf("parameters = %s", tools[0].Function.Parameters)
+	}
+
+	if len(resp.Content.Parts) != 1 || resp.Content.Parts[0].FunctionCall == nil {
+		t.Fatalf("parts = %+v", resp.Content.Parts)
+	}
+	fc := resp.Content.Parts[0].FunctionCall
+	if fc.ID != "call_abc" || fc.Name != "query_executor" || fc.Args["query"] != "SELECT 1" {
+		t.Errorf("function call = %+v", fc)
+	}
+	if resp.UsageMetadata != nil {
+		t.Errorf("usage = %+v, want nil without usage in the response", resp.UsageMetadata)
+	}
+}
+
+func TestGenerateContentAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"openai error", http.StatusTooManyRequests, `{"error": {"message": "Rate limit reached", "type": "requests"}}`, "chat completion failed (status 429): Rate limit reached"},
+		{"plain body", http.StatusBadGateway, `upstream unavailable`, "chat completion failed (status 502)"},
+		{"no choices", http.StatusOK, `{"choices": []}`, "chat completion returned no choices"},
+		{"invalid arguments", http.StatusOK, `{"choices": [{"message": {"tool_calls": [{"id": "1", "function": {"name": "f", "arguments": "{"}}]}}]}`, "invalid arguments for f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubServer(t, tt.status, tt.body)
+
+			_, err := generate(t, server.model(t), &model.LLMRequest{
+				Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestToMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *genai.Content
+		want    []chatMessage
+	}{
+		{
+			name:    "nil",
+			content: nil,
+		},
+		{
+			name:    "user text",
+			content: genai.NewContentFromText("hello", genai.RoleUser),
+			want:    []chatMessage{{Role: "user", Content: "hello"}},
+		},
+		{
+			name: "thoughts are dropped",
+			content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+				{Text: "thinking", Thought: true},
+				{Text: "answer"},
+			}},
+			want: []chatMessage{{Role: "assistant", Content: "answer"}},
+		},
+		{
+			name: "call with ID",
+			content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+				{Text: "let me check"},
+				{FunctionCall: &genai.FunctionCall{ID: "c1", Name: "read_schema", Args: map[string]any{}}},
+			}},
+			want: []chatMessage{{Role: "assistant", Content: "let me check", ToolCalls: []chatToolCall{
+				{ID: "c1", Type: "function", Function: chatToolCallFunction{Name: "read_schema", Arguments: "{}"}},
+			}}},
+		},
+		{
+			name: "responses become tool messages",
+			content: &genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{
+				{FunctionResponse: &genai.FunctionResponse{ID: "c1", Name: "read_schema", Response: map[string]any{"ok": true}}},
+				{FunctionResponse: &genai.FunctionResponse{ID: "c2", Name: "query_executor", Response: map[string]any{"rows": 1}}},
+			}},
+			want: []chatMessage{
+				{Role: "tool", ToolCallID: "c1", Content: `{"ok":true}`},
+				{Role: "tool", ToolCallID: "c2", Content: `{"rows":1}`},
+			},
+		},
+		{
+			name:    "empty model turn",
+			content: &genai.Content{Role: genai.RoleModel},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toMessages(tt.content, llm.NewCallIDs())
+			if err != nil {
+				t.Fatalf("toMessages: %v", err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("toMessages = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestFinishReason(t *testing.T) {
+	tests := map[string]genai.FinishReason{
+		"stop":           genai.FinishReasonStop,
+		"tool_calls":     genai.FinishReasonStop,
+		"length":         genai.FinishReasonMaxTokens,
+		"content_filter": genai.FinishReasonSafety,
+		"":               genai.FinishReasonUnspecified,
+	}
+	for reason, want := range tests {
+		if got := finishReason(reason); got != want {
+			t.Errorf("finishReason(%q) = %v, want %v", reason, got, want)
+		}
+	}
+}
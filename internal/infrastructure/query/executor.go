@@ -0,0 +1,384 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	domainQuery "github.com/mololab/alodb/internal/domain/query"
+	"github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/sqlguard"
+	"github.com/mololab/alodb/pkg/logger"
+)
+
+// Default executor limits
+const (
+	DefaultPageSize         = 100
+	DefaultMaxPageSize      = 1000
+	DefaultStatementTimeout = 10 * time.Second
+	DefaultCursorTTL        = 5 * time.Minute
+	DefaultMaxCursors       = 64
+	DefaultMaxUserCursors   = 8
+)
+
+// cursorName is the server-side cursor name, unique per transaction
+const cursorName = "alodb_cursor"
+
+var (
+	ErrQueryFailed    = errors.New("query failed")
+	ErrCursorNotFound = errors.New("cursor not found or expired")
+	ErrTooManyCursors = errors.New("too many open cursors")
+	// ErrTooManyUserCursors is returned when the caller holds their share of the cursors
+	ErrTooManyUserCursors = errors.New("too many open cursors for this user, read or let expire the open ones")
+)
+
+// Config bounds the work done by the executor
+type Config struct {
+	MaxPageSize      int
+	StatementTimeout time.Duration
+	CursorTTL        time.Duration
+	MaxCursors       int
+	// MaxUserCursors is the share of MaxCursors one user may hold, so a
+	// single caller cannot take every connection until the cursors expire
+	MaxUserCursors int
+}
+
+// Executor runs read-only queries and keeps server-side cursors open for pagination
+type Executor struct {
+	config   Config
+	policy   *connection.Policy
+	mu       sync.Mutex
+	cursors  map[string]*cursor
+	reserved int            // slots taken by cursors being declared
+	perUser  map[string]int // open and reserved slots by owner
+	done     chan struct{}
+	// open connects to a database, the policy in production and a stub in tests
+	open func(connectionString string) (*sql.DB, error)
+}
+
+// cursor is an open read-only transaction holding a declared cursor
+type cursor struct {
+	mu       sync.Mutex
+	owner    string // user that opened the cursor, only they can read it
+	db       *sql.DB
+	tx       *sql.Tx
+	columns  []domainQuery.Column
+	pending  []any
+	lastUsed time.Time
+}
+
+// NewExecutor creates an executor that connects through the policy and
+// starts the expired cursor reaper
+func NewExecutor(config Config, policy *connection.Policy) *Executor {
+	if config.MaxPageSize <= 0 {
+		config.MaxPageSize = DefaultMaxPageSize
+	}
+	if config.StatementTimeout <= 0 {
+		config.StatementTimeout = DefaultStatementTimeout
+	}
+	if config.CursorTTL <= 0 {
+		config.CursorTTL = DefaultCursorTTL
+	}
+	if config.MaxCursors <= 0 {
+		config.MaxCursors = DefaultMaxCursors
+	}
+	if config.MaxUserCursors <= 0 {
+		config.MaxUserCursors = DefaultMaxUserCursors
+	}
+
+	e := &Executor{
+		config:  config,
+		policy:  policy,
+		cursors: make(map[string]*cursor),
+		perUser: make(map[string]int),
+		done:    make(chan struct{}),
+		open:    policy.Open,
+	}
+	go e.reapExpired()
+
+	return e
+}
+
+// Execute runs a new query or reads the next page of an open cursor
+func (e *Executor) Execute(ctx context.Context, req domainQuery.ExecuteRequest) (*domainQuery.ResultPage, error) {
+	pageSize := e.pageSize(req.PageSize)
+
+	if req.Cursor != "" {
+		return e.fetch(ctx, req.Cursor, req.UserID, pageSize)
+	}
+
+	if err := sqlguard.CheckReadOnly(req.Query); err != nil {
+		return nil, err
+	}
+
+	if err := e.reserve(req.UserID); err != nil {
+		return nil, err
+	}
+
+	c, err := e.declare(ctx, req.ConnectionString, req.Query)
+	if err != nil {
+		e.release(req.UserID)
+		return nil, err
+	}
+	c.owner = req.UserID
+
+	page, err := c.read(ctx, pageSize)
+	if err != nil {
+		e.release(req.UserID)
+		c.close()
+		return nil, err
+	}
+
+	if !page.HasMore {
+		e.release(req.UserID)
+		c.close()
+		return page, nil
+	}
+
+	// the reserved slot becomes the open cursor
+	id := uuid.New().String()
+	e.mu.Lock()
+	e.reserved--
+	e.cursors[id] = c
+	e.mu.Unlock()
+
+	page.NextCursor = id
+	logger.Debug().Str("cursor", id).Int("rows", page.RowCount).Msg("cursor opened")
+	return page, nil
+}
+
+// fetch reads the next page of an open cursor, closing it once exhausted
+func (e *Executor) fetch(ctx context.Context, id, owner string, pageSize int) (*domainQuery.ResultPage, error) {
+	e.mu.Lock()
+	c, ok := e.cursors[id]
+	e.mu.Unlock()
+	if !ok || c.owner != owner {
+		return nil, ErrCursorNotFound
+	}
+
+	page, err := c.read(ctx, pageSize)
+	if err != nil || !page.HasMore {
+		e.remove(id)
+		if err != nil {
+			return nil, err
+		}
+		logger.Debug().Str("cursor", id).Msg("cursor exhausted")
+		return page, nil
+	}
+
+	page.NextCursor = id
+	return page, nil
+}
+
+// declare opens a read-only transaction and declares a cursor for the query
+func (e *Executor) declare(ctx context.Context, connectionString, query string) (*cursor, error) {
+	db, err := e.open(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	// the transaction outlives the request, so it must not be bound to its context
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+
+	c := &cursor{db: db, tx: tx, lastUsed: time.Now()}
+
+	timeoutMs := e.config.StatementTimeout.Milliseconds()
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeoutMs)); err != nil {
+		c.close()
+		return nil, fmt.Errorf("failed to set statement timeout: %w", err)
+	}
+
+	// preparing the statement makes PostgreSQL reject multiple commands
+	declare := fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", cursorName, strings.TrimRight(strings.TrimSpace(query), "; \t\r\n"))
+	stmt, err := tx.PrepareContext(ctx, declare)
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		c.close()
+		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+	}
+
+	return c, nil
+}
+
+// read fetches up to pageSize rows, reading one row ahead to detect the end
+func (c *cursor) read(ctx context.Context, pageSize int) (*domainQuery.ResultPage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastUsed = time.Now()
+
+	page := &domainQuery.ResultPage{Rows: [][]any{}}
+	if c.pending != nil {
+		page.Rows = append(page.Rows, c.pending)
+		c.pending = nil
+	}
+
+	fetchCount := pageSize - len(page.Rows) + 1
+	rows, err := c.tx.QueryContext(ctx, fmt.Sprintf("FETCH FORWARD %d FROM %s", fetchCount, cursorName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	if c.columns == nil {
+		c.columns, err = ReadColumns(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns: %w", err)
+		}
+	}
+
+	fetched, _, err := ReadRows(rows, len(c.columns), fetchCount)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+	}
+	page.Rows = append(page.Rows, fetched...)
+
+	if len(page.Rows) > pageSize {
+		c.pending = page.Rows[pageSize]
+		page.Rows = page.Rows[:pageSize]
+		page.HasMore = true
+	}
+
+	page.Columns = c.columns
+	page.RowCount = len(page.Rows)
+	return page, nil
+}
+
+// close rolls back the transaction and releases the connection
+func (c *cursor) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logger.Warn().Err(err).Msg("failed to roll back cursor transaction")
+	}
+	c.db.Close()
+}
+
+// pageSize clamps the requested page size to the configured maximum
+func (e *Executor) pageSize(requested int) int {
+	if requested <= 0 {
+		requested = DefaultPageSize
+	}
+	if requested > e.config.MaxPageSize {
+		return e.config.MaxPageSize
+	}
+	return requested
+}
+
+// reserve takes a cursor slot of the owner before the cursor is declared, so
+// concurrent requests cannot open more than MaxCursors, or MaxUserCursors for
+// one owner, between the check and the insert
+func (e *Executor) reserve(owner string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.perUser[owner] >= e.config.MaxUserCursors {
+		return ErrTooManyUserCursors
+	}
+	if len(e.cursors)+e.reserved >= e.config.MaxCursors {
+		return ErrTooManyCursors
+	}
+	e.reserved++
+	e.perUser[owner]++
+	return nil
+}
+
+// release gives back a slot taken by reserve that did not become an open cursor
+func (e *Executor) release(owner string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.reserved--
+	e.releaseOwner(owner)
+}
+
+// releaseOwner gives back a slot of the owner, e.mu must be held
+func (e *Executor) releaseOwner(owner string) {
+	if e.perUser[owner]--; e.perUser[owner] <= 0 {
+		delete(e.perUser, owner)
+	}
+}
+
+// remove closes and forgets a cursor
+func (e *Executor) remove(id string) {
+	e.mu.Lock()
+	c, ok := e.cursors[id]
+	if ok {
+		delete(e.cursors, id)
+		e.releaseOwner(c.owner)
+	}
+	e.mu.Unlock()
+
+	if ok {
+		c.close()
+	}
+}
+
+// reapExpired periodically closes cursors that have been idle longer than the TTL
+func (e *Executor) reapExpired() {
+	ticker := time.NewTicker(e.config.CursorTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.mu.Lock()
+			var expired []string
+			for id, c := range e.cursors {
+				// a locked cursor is being read right now, so it is not idle
+				if !c.mu.TryLock() {
+					continue
+				}
+				idle := time.Since(c.lastUsed)
+				c.mu.Unlock()
+				if idle > e.config.CursorTTL {
+					expired = append(expired, id)
+				}
+			}
+			e.mu.Unlock()
+
+			for _, id := range expired {
+				logger.Debug().Str("cursor", id).Msg("cursor expired")
+				e.remove(id)
+			}
+		}
+	}
+}
+
+// Close stops the reaper and closes every open cursor
+func (e *Executor) Close() error {
+	close(e.done)
+
+	e.mu.Lock()
+	cursors := e.cursors
+	e.cursors = make(map[string]*cursor)
+	e.perUser = make(map[string]int)
+	e.mu.Unlock()
+
+	for _, c := range cursors {
+		c.close()
+	}
+	return nil
+}
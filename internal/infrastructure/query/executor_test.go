@@ -0,0 +1,437 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	domainQuery "github.com/mololab/alodb/internal/domain/query"
+	"github.com/mololab/alodb/internal/infrastructure/sqlguard"
+)
+
+// stubDatabase is a database/sql connector serving one result set through
+// DECLARE and FETCH, and recording what the executor does with it
+type stubDatabase struct {
+	mu          sync.Mutex
+	rows        [][]driver.Value
+	declareErr  error
+	fetchErr    error
+	statements  []string
+	readOnly    []bool
+	connections int // open connections
+	rollbacks   int
+}
+
+func newStubDatabase(rows int) *stubDatabase {
+	db := &stubDatabase{}
+	for i := 1; i <= rows; i++ {
+		db.rows = append(db.rows, []driver.Value{int64(i), fmt.Sprintf("user %d", i)})
+	}
+	return db
+}
+
+func (d *stubDatabase) Connect(context.Context) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.connections++
+	return &stubConn{db: d}, nil
+}
+
+func (d *stubDatabase) Driver() driver.Driver { return d }
+
+func (d *stubDatabase) Open(string) (driver.Conn, error) { return d.Connect(context.Background()) }
+
+func (d *stubDatabase) record(statement string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, statement)
+}
+
+// state returns the open connections and rollbacks so far
+func (d *stubDatabase) state() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.connections, d.rollbacks
+}
+
+// stubConn is one connection with a cursor position
+type stubConn struct {
+	db       *stubDatabase
+	position int
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.record(query)
+	if c.db.declareErr != nil {
+		return nil, c.db.declareErr
+	}
+	return stubStmt{}, nil
+}
+
+func (c *stubConn) Close() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.connections--
+	return nil
+}
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *stubConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.readOnly = append(c.db.readOnly, opts.ReadOnly)
+	return stubTx{c.db}, nil
+}
+
+func (c *stubConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *stubConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+
+	var count int
+	if _, err := fmt.Sscanf(query, "FETCH FORWARD %d FROM "+cursorName, &count); err != nil {
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	}
+	if c.db.fetchErr != nil {
+		return nil, c.db.fetchErr
+	}
+
+	end := min(c.position+count, len(c.db.rows))
+	rows := &stubRows{values: c.db.rows[c.position:end]}
+	c.position = end
+	return rows, nil
+}
+
+type stubStmt struct{}
+
+func (stubStmt) Close() error                               { return nil }
+func (stubStmt) NumInput() int                              { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (stubStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, errors.New("not supported") }
+
+type stubTx struct{ db *stubDatabase }
+
+func (stubTx) Commit() error { return errors.New("cursor transactions are never committed") }
+
+func (t stubTx) Rollback() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.rollbacks++
+	return nil
+}
+
+type stubRows struct {
+	values [][]driver.Value
+	next   int
+}
+
+func (r *stubRows) Columns() []string { return []string{"id", "name"} }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.next == len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+// newTestExecutor creates an executor whose connections go to db
+func newTestExecutor(t *testing.T, config Config, db *stubDatabase) *Executor {
+	t.Helper()
+
+	e := NewExecutor(config, nil)
+	e.open = func(string) (*sql.DB, error) { return sql.OpenDB(db), nil }
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func execute(e *Executor, user, query string, pageSize int) (*domainQuery.ResultPage, error) {
+	return e.Execute(context.Background(), domainQuery.ExecuteRequest{
+		UserID:           user,
+		ConnectionString: "postgres://db/app",
+		Query:            query,
+		PageSize:         pageSize,
+	})
+}
+
+func fetch(e *Executor, user, cursor string, pageSize int) (*domainQuery.ResultPage, error) {
+	return e.Execute(context.Background(), domainQuery.ExecuteRequest{UserID: user, Cursor: cursor, PageSize: pageSize})
+}
+
+// ids returns the first column of the rows of a page
+func ids(page *domainQuery.ResultPage) []int64 {
+	var ids []int64
+	for _, row := range page.Rows {
+		ids = append(ids, row[0].(int64))
+	}
+	return ids
+}
+
+// slots returns the reserved slots, the open cursors and the slots by owner
+func (e *Executor) slots() (int, int, map[string]int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.reserved, len(e.cursors), maps.Clone(e.perUser)
+}
+
+func TestExecutePages(t *testing.T) {
+	db := newStubDatabase(5)
+	e := newTestExecutor(t, Config{StatementTimeout: 3 * time.Second}, db)
+
+	page, err := execute(e, "alice", "SELECT id, name FROM users;", 2)
+	if err != nil {
+		t.Fatalf("page 1: %v", err)
+	}
+	if !slices.Equal(ids(page), []int64{1, 2}) || !page.HasMore || page.NextCursor == "" || page.RowCount != 2 {
+		t.Fatalf("page 1 = %+v", page)
+	}
+	if len(page.Columns) != 2 || page.Columns[1].Name != "name" || page.Rows[0][1] != "user 1" {
+		t.Errorf("columns = %+v, rows = %v", page.Columns, page.Rows)
+	}
+	cursor := page.NextCursor
+
+	// the row read ahead to detect more rows starts the next page
+	page, err = fetch(e, "alice", cursor, 2)
+	if err != nil {
+		t.Fatalf("page 2: %v", err)
+	}
+	if !slices.Equal(ids(page), []int64{3, 4}) || !page.HasMore || page.NextCursor != cursor {
+		t.Fatalf("page 2 = %+v", page)
+	}
+
+	page, err = fetch(e, "alice", cursor, 2)
+	if err != nil {
+		t.Fatalf("page 3: %v", err)
+	}
+	if !slices.Equal(ids(page), []int64{5}) || page.HasMore || page.NextCursor != "" {
+		t.Fatalf("page 3 = %+v", page)
+	}
+
+	if _, err := fetch(e, "alice", cursor, 2); !errors.Is(err, ErrCursorNotFound) {
+		t.Errorf("exhausted cursor err = %v, want ErrCursorNotFound", err)
+	}
+
+	want := []string{
+		"SET LOCAL statement_timeout = 3000",
+		"DECLARE alodb_cursor NO SCROLL CURSOR FOR SELECT id, name FROM users",
+		"FETCH FORWARD 3 FROM alodb_cursor",
+		"FETCH FORWARD 2 FROM alodb_cursor",
+		"FETCH FORWARD 2 FROM alodb_cursor",
+	}
+	if !slices.Equal(db.statements, want) {
+		t.Errorf("statements = %q, want %q", db.statements, want)
+	}
+	if !slices.Equal(db.readOnly, []bool{true}) {
+		t.Errorf("transactions read-only = %v", db.readOnly)
+	}
+	if connections, rollbacks := db.state(); connections != 0 || rollbacks != 1 {
+		t.Errorf("connections = %d, rollbacks = %d, want the exhausted cursor closed", connections, rollbacks)
+	}
+	if reserved, open, perUser := e.slots(); reserved != 0 || open != 0 || len(perUser) != 0 {
+		t.Errorf("slots = %d reserved, %d open, %v by user", reserved, open, perUser)
+	}
+}
+
+func TestExecuteSinglePage(t *testing.T) {
+	for _, rows := range []int{0, 1, 2} {
+		t.Run(fmt.Sprintf("%d rows", rows), func(t *testing.T) {
+			db := newStubDatabase(rows)
+			e := newTestExecutor(t, Config{}, db)
+
+			page, err := execute(e, "alice", "SELECT id, name FROM users", 2)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if page.RowCount != rows || page.HasMore || page.NextCursor != "" {
+				t.Errorf("page = %+v, want %d rows and no cursor", page, rows)
+			}
+			if connections, rollbacks := db.state(); connections != 0 || rollbacks != 1 {
+				t.Errorf("connections = %d, rollbacks = %d, want no cursor kept open", connections, rollbacks)
+			}
+		})
+	}
+}
+
+func TestExecutePageSize(t *testing.T) {
+	e := newTestExecutor(t, Config{MaxPageSize: 500}, newStubDatabase(0))
+
+	tests := []struct{ requested, want int }{
+		{0, DefaultPageSize},
+		{-1, DefaultPageSize},
+		{10, 10},
+		{5000, 500},
+	}
+	for _, tt := range tests {
+		if got := e.pageSize(tt.requested); got != tt.want {
+			t.Errorf("pageSize(%d) = %d, want %d", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestFetchChecksOwner(t *testing.T) {
+	e := newTestExecutor(t, Config{}, newStubDatabase(5))
+
+	page, err := execute(e, "alice", "SELECT id, name FROM users", 2)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if _, err := fetch(e, "mallory", page.NextCursor, 2); !errors.Is(err, ErrCursorNotFound) {
+		t.Errorf("other user err = %v, want ErrCursorNotFound", err)
+	}
+	if _, err := fetch(e, "alice", "00000000-0000-0000-0000-000000000000", 2); !errors.Is(err, ErrCursorNotFound) {
+		t.Errorf("unknown cursor err = %v, want ErrCursorNotFound", err)
+	}
+	// the failed attempts leave the cursor to its owner
+	if page, err := fetch(e, "alice", page.NextCursor, 2); err != nil || !slices.Equal(ids(page), []int64{3, 4}) {
+		t.Errorf("owner page = %+v, err %v", page, err)
+	}
+}
+
+func TestExecuteCursorLimits(t *testing.T) {
+	e := newTestExecutor(t, Config{MaxCursors: 3, MaxUserCursors: 2}, newStubDatabase(5))
+
+	open := func(user string) (string, error) {
+		page, err := execute(e, user, "SELECT id, name FROM users", 2)
+		if err != nil {
+			return "", err
+		}
+		return page.NextCursor, nil
+	}
+
+	first, err := open("alice")
+	if err != nil {
+		t.Fatalf("alice 1: %v", err)
+	}
+	if _, err := open("alice"); err != nil {
+		t.Fatalf("alice 2: %v", err)
+	}
+	if _, err := open("alice"); !errors.Is(err, ErrTooManyUserCursors) {
+		t.Errorf("alice 3 err = %v, want ErrTooManyUserCursors", err)
+	}
+
+	if _, err := open("bob"); err != nil {
+		t.Fatalf("bob: %v", err)
+	}
+	if _, err := open("carol"); !errors.Is(err, ErrTooManyCursors) {
+		t.Errorf("carol err = %v, want ErrTooManyCursors", err)
+	}
+
+	// reading a cursor to its end gives its slot back
+	for cursor := first; cursor != ""; {
+		page, err := fetch(e, "alice", cursor, 2)
+		if err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+		cursor = page.NextCursor
+	}
+	if _, err := open("carol"); err != nil {
+		t.Errorf("carol after alice's cursor closed: %v", err)
+	}
+
+	if reserved, cursors, perUser := e.slots(); reserved != 0 || cursors != 3 ||
+		perUser["alice"] != 1 || perUser["bob"] != 1 || perUser["carol"] != 1 {
+		t.Errorf("slots = %d reserved, %d open, %v by user", reserved, cursors, perUser)
+	}
+}
+
+func TestExecuteReleasesSlotsOnError(t *testing.T) {
+	failure := errors.New(`relation "users" does not exist`)
+
+	tests := []struct {
+		name  string
+		setup func(db *stubDatabase)
+		query string
+		want  error
+	}{
+		{"write", func(*stubDatabase) {}, "DELETE FROM users", sqlguard.ErrNotReadOnly},
+		{"multiple statements", func(*stubDatabase) {}, "SELECT 1; SELECT 2", sqlguard.ErrMultipleStatements},
+		{"declare", func(db *stubDatabase) { db.declareErr = failure }, "SELECT id, name FROM users", ErrQueryFailed},
+		{"fetch", func(db *stubDatabase) { db.fetchErr = failure }, "SELECT id, name FROM users", ErrQueryFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newStubDatabase(5)
+			tt.setup(db)
+			e := newTestExecutor(t, Config{MaxCursors: 1, MaxUserCursors: 1}, db)
+
+			// more attempts than slots, so a leaked slot fails the next attempt
+			for i := 0; i < 2; i++ {
+				if _, err := execute(e, "alice", tt.query, 2); !errors.Is(err, tt.want) {
+					t.Fatalf("attempt %d err = %v, want %v", i+1, err, tt.want)
+				}
+			}
+			if reserved, cursors, perUser := e.slots(); reserved != 0 || cursors != 0 || len(perUser) != 0 {
+				t.Errorf("slots = %d reserved, %d open, %v by user", reserved, cursors, perUser)
+			}
+			if connections, _ := db.state(); connections != 0 {
+				t.Errorf("%d connections left open", connections)
+			}
+		})
+	}
+}
+
+func TestReapExpiredCursors(t *testing.T) {
+	db := newStubDatabase(5)
+	e := newTestExecutor(t, Config{CursorTTL: 20 * time.Millisecond}, db)
+
+	page, err := execute(e, "alice", "SELECT id, name FROM users", 2)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, cursors, _ := e.slots(); cursors == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cursor was not reaped")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := fetch(e, "alice", page.NextCursor, 2); !errors.Is(err, ErrCursorNotFound) {
+		t.Errorf("expired cursor err = %v, want ErrCursorNotFound", err)
+	}
+	if connections, rollbacks := db.state(); connections != 0 || rollbacks != 1 {
+		t.Errorf("connections = %d, rollbacks = %d, want the expired cursor closed", connections, rollbacks)
+	}
+	if _, _, perUser := e.slots(); len(perUser) != 0 {
+		t.Errorf("slots by user = %v, want the expired cursor's slot released", perUser)
+	}
+}
+
+func TestCloseClosesCursors(t *testing.T) {
+	db := newStubDatabase(5)
+	e := NewExecutor(Config{}, nil)
+	e.open = func(string) (*sql.DB, error) { return sql.OpenDB(db), nil }
+
+	for i := 0; i < 2; i++ {
+		if _, err := execute(e, "alice", "SELECT id, name FROM users", 2); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	}
+	e.Close()
+
+	if connections, rollbacks := db.state(); connections != 0 || rollbacks != 2 {
+		t.Errorf("connections = %d, rollbacks = %d after Close", connections, rollbacks)
+	}
+}
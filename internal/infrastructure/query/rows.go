@@ -0,0 +1,74 @@
+package query
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	domainQuery "github.com/mololab/alodb/internal/domain/query"
+)
+
+// ReadColumns returns the name and database type of every result column
+func ReadColumns(rows *sql.Rows) ([]domainQuery.Column, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]domainQuery.Column, len(columnTypes))
+	for i, ct := range columnTypes {
+		columns[i] = domainQuery.Column{
+			Name: ct.Name(),
+			Type: strings.ToLower(ct.DatabaseTypeName()),
+		}
+	}
+	return columns, nil
+}
+
+// ReadRows reads up to maxRows rows and reports whether more rows were available
+func ReadRows(rows *sql.Rows, columnCount, maxRows int) ([][]any, bool, error) {
+	result := [][]any{}
+
+	for rows.Next() {
+		if len(result) >= maxRows {
+			return result, true, nil
+		}
+
+		row, err := scanRow(rows, columnCount)
+		if err != nil {
+			return nil, false, err
+		}
+		result = append(result, row)
+	}
+
+	return result, false, rows.Err()
+}
+
+// scanRow scans the current row into JSON friendly values
+func scanRow(rows *sql.Rows, columnCount int) ([]any, error) {
+	values := make([]any, columnCount)
+	scanArgs := make([]any, columnCount)
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		values[i] = normalizeValue(v)
+	}
+	return values, nil
+}
+
+// normalizeValue converts driver values into JSON friendly values
+func normalizeValue(v any) any {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	default:
+		return val
+	}
+}
@@ -0,0 +1,130 @@
+// Package ratelimit limits chat requests with token buckets and enforces
+// daily LLM token quotas per caller.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Config configures the limits. Zero values disable a limit.
+type Config struct {
+	// RequestsPerMinute is the rate at which a caller's bucket refills
+	RequestsPerMinute int
+	// Burst is the bucket size, the number of requests allowed at once
+	Burst int
+	// DailyTokens is the number of LLM tokens a caller may use per UTC day
+	DailyTokens int64
+}
+
+// Decision is the outcome of a limit check
+type Decision struct {
+	Allowed bool
+	// RetryAfter is how long a rejected caller should wait
+	RetryAfter time.Duration
+}
+
+// Store keeps bucket and usage state. MemoryStore serves a single replica,
+// SQLStore shares the state between replicas.
+type Store interface {
+	// Take removes one token from the bucket of key, refilled at rate tokens
+	// per second up to burst. It returns the time until a token is available
+	// when the bucket is empty.
+	Take(ctx context.Context, key string, rate float64, burst int, now time.Time) (bool, time.Duration, error)
+	// Usage returns the tokens used by key on day
+	Usage(ctx context.Context, key, day string) (int64, error)
+	// AddUsage adds tokens to the usage of key on day
+	AddUsage(ctx context.Context, key, day string, tokens int64) error
+}
+
+// Limiter applies the configured limits to callers
+type Limiter struct {
+	store Store
+	cfg   Config
+	now   func() time.Time
+}
+
+func NewLimiter(store Store, cfg Config) *Limiter {
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	return &Limiter{store: store, cfg: cfg, now: time.Now}
+}
+
+// Allow takes a request from the caller's bucket
+func (l *Limiter) Allow(ctx context.Context, caller string) (Decision, error) {
+	if l.cfg.RequestsPerMinute <= 0 {
+		return Decision{Allowed: true}, nil
+	}
+
+	rate := float64(l.cfg.RequestsPerMinute) / 60
+	allowed, wait, err := l.store.Take(ctx, caller, rate, l.cfg.Burst, l.now())
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Allowed: allowed, RetryAfter: wait}, nil
+}
+
+// CheckQuota rejects callers that used up their daily tokens. A request
+// started within the quota may exceed it, its usage counts towards the day.
+func (l *Limiter) CheckQuota(ctx context.Context, caller string) (Decision, error) {
+	if l.cfg.DailyTokens <= 0 {
+		return Decision{Allowed: true}, nil
+	}
+
+	now := l.now().UTC()
+	used, err := l.store.Usage(ctx, caller, day(now))
+	if err != nil {
+		return Decision{}, err
+	}
+	if used < l.cfg.DailyTokens {
+		return Decision{Allowed: true}, nil
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return Decision{Allowed: false, RetryAfter: midnight.Sub(now)}, nil
+}
+
+// RecordUsage counts LLM tokens used by the caller today
+func (l *Limiter) RecordUsage(ctx context.Context, caller string, tokens int64) error {
+	if l.cfg.DailyTokens <= 0 || tokens <= 0 {
+		return nil
+	}
+	return l.store.AddUsage(ctx, caller, day(l.now().UTC()), tokens)
+}
+
+// day formats the UTC day quotas are counted for
+func day(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+// refill returns the tokens of a bucket after elapsed time, capped at burst
+func refill(tokens float64, elapsed time.Duration, rate float64, burst int) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * rate
+	}
+	return math.Min(tokens, float64(burst))
+}
+
+// take removes a token from a refilled bucket, or returns the wait for one
+func take(tokens, rate float64) (float64, bool, time.Duration) {
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	wait := time.Duration((1 - tokens) / rate * float64(time.Second))
+	return tokens, false, wait
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context carrying the key limits are counted under
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller key of the context, if any
+func CallerFromContext(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	return caller, ok
+}
@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mololab/alodb/internal/infrastructure/storage"
+)
+
+// testStores returns a fresh store of every kind
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	store, err := storage.Open(storage.Config{Driver: storage.DriverSQLite, DSN: filepath.Join(t.TempDir(), "alodb.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	sqlStore, err := NewSQLStore(store.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Store{"memory": NewMemoryStore(), "sql": sqlStore}
+}
+
+// testClock is a settable clock for a limiter
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+func (c *testClock) Set(t time.Time)         { c.now = t }
+
+func newTestLimiter(store Store, cfg Config, clock *testClock) *Limiter {
+	l := NewLimiter(store, cfg)
+	l.now = clock.Now
+	return l
+}
+
+func TestLimiterAllow(t *testing.T) {
+	// the steps run in order against one bucket of 2 requests, refilled at one per second
+	steps := []struct {
+		name    string
+		advance time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{"first of burst", 0, true, 0},
+		{"second of burst", 0, true, 0},
+		{"burst used up", 0, false, time.Second},
+		{"partly refilled", 250 * time.Millisecond, false, 750 * time.Millisecond},
+		{"refilled one", 750 * time.Millisecond, true, 0},
+		{"empty again", 0, false, time.Second},
+		// an idle bucket refills up to the burst, not beyond
+		{"idle", time.Hour, true, 0},
+		{"burst after idle", 0, true, 0},
+		{"burst after idle used up", 0, false, time.Second},
+	}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+			l := newTestLimiter(store, Config{RequestsPerMinute: 60, Burst: 2}, clock)
+
+			for _, step := range steps {
+				clock.Advance(step.advance)
+				decision, err := l.Allow(context.Background(), "key:1")
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if decision.Allowed != step.allowed || decision.RetryAfter.Round(time.Millisecond) != step.wait {
+					t.Errorf("%s: decision = %+v, want allowed %v after %v", step.name, decision, step.allowed, step.wait)
+				}
+			}
+
+			// callers have separate buckets
+			if decision, err := l.Allow(context.Background(), "key:2"); err != nil || !decision.Allowed {
+				t.Errorf("other caller: decision = %+v, err %v", decision, err)
+			}
+		})
+	}
+}
+
+func TestLimiterAllowDisabled(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), Config{})
+	for range 100 {
+		if decision, err := l.Allow(context.Background(), "key:1"); err != nil || !decision.Allowed {
+			t.Fatalf("decision = %+v, err %v", decision, err)
+		}
+	}
+}
+
+func TestLimiterQuota(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2026, 10, 17, 23, 59, 30, 0, time.UTC)}
+			l := newTestLimiter(store, Config{DailyTokens: 100}, clock)
+
+			check := func(allowed bool, wait time.Duration) {
+				t.Helper()
+				decision, err := l.CheckQuota(ctx, "key:1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decision.Allowed != allowed || decision.RetryAfter != wait {
+					t.Errorf("decision = %+v, want allowed %v after %v", decision, allowed, wait)
+				}
+			}
+			record := func(tokens int64) {
+				t.Helper()
+				if err := l.RecordUsage(ctx, "key:1", tokens); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			record(60)
+			record(0)
+			record(-10)
+			check(true, 0)
+
+			// the request that crosses the quota still counts
+			record(50)
+			check(false, 30*time.Second)
+			if decision, _ := l.CheckQuota(ctx, "key:2"); !decision.Allowed {
+				t.Error("another caller shares the quota")
+			}
+
+			// the quota resets at midnight UTC, whatever the clock's zone
+			clock.Set(time.Date(2026, 10, 18, 3, 0, 0, 0, time.FixedZone("CEST", 2*60*60)))
+			check(true, 0)
+			record(100)
+			check(false, 23*time.Hour)
+		})
+	}
+}
+
+func TestLimiterQuotaDisabled(t *testing.T) {
+	store := NewMemoryStore()
+	l := NewLimiter(store, Config{})
+
+	if err := l.RecordUsage(context.Background(), "key:1", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := store.Usage(context.Background(), "key:1", day(time.Now().UTC())); used != 0 {
+		t.Errorf("usage = %d, want nothing recorded without a quota", used)
+	}
+	if decision, err := l.CheckQuota(context.Background(), "key:1"); err != nil || !decision.Allowed {
+		t.Errorf("decision = %+v, err %v", decision, err)
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets and past days are dropped
+const sweepInterval = 10 * time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rate    float64
+	burst   int
+}
+
+// MemoryStore keeps limits in process memory, each replica limits on its own
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	usage     map[string]map[string]int64 // day -> key -> tokens
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		usage:   make(map[string]map[string]int64),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rate float64, burst int, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.rate, b.burst = rate, burst
+
+	tokens := refill(b.tokens, now.Sub(b.updated), rate, burst)
+	tokens, allowed, wait := take(tokens, rate)
+	b.tokens, b.updated = tokens, now
+
+	return allowed, wait, nil
+}
+
+func (s *MemoryStore) Usage(ctx context.Context, key, day string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.usage[day][key], nil
+}
+
+func (s *MemoryStore) AddUsage(ctx context.Context, key, day string, tokens int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.usage[day] == nil {
+		s.usage[day] = make(map[string]int64)
+	}
+	s.usage[day][key] += tokens
+	return nil
+}
+
+// sweep drops buckets that refilled completely, they equal new buckets, and
+// usage of past days
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if refill(b.tokens, now.Sub(b.updated), b.rate, b.burst) >= float64(b.burst) {
+			delete(s.buckets, key)
+		}
+	}
+
+	today := day(now.UTC())
+	for d := range s.usage {
+		if d < today {
+			delete(s.usage, d)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2026, 10, 17, 23, 55, 0, 0, time.UTC)
+
+	// key:1 refills fully within the sweep interval, key:2 does not
+	for _, key := range []string{"key:1", "key:2"} {
+		if _, _, err := store.Take(ctx, key, 1, 2, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := store.Take(ctx, "key:2", 1.0/3600, 2, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddUsage(ctx, "key:1", day(now), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(sweepInterval)
+	if _, _, err := store.Take(ctx, "key:3", 1, 2, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.buckets["key:1"]; ok {
+		t.Error("refilled bucket was kept")
+	}
+	if _, ok := store.buckets["key:2"]; !ok {
+		t.Error("bucket that is still refilling was dropped")
+	}
+	if _, ok := store.usage["2026-10-17"]; ok {
+		t.Error("usage of a past day was kept")
+	}
+}
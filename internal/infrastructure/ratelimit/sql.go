@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// bucketRecord is the stored state of a token bucket. RefilledAt is in Unix
+// nanoseconds so the refill can be computed in SQL on every database.
+type bucketRecord struct {
+	Caller     string  `gorm:"primaryKey;size:255"`
+	Tokens     float64 `gorm:"not null"`
+	RefilledAt int64   `gorm:"not null"`
+}
+
+func (bucketRecord) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// usageRecord is the number of LLM tokens used by a caller on a UTC day
+type usageRecord struct {
+	Caller string `gorm:"primaryKey;size:255"`
+	Day    string `gorm:"primaryKey;size:10"`
+	Tokens int64  `gorm:"not null"`
+}
+
+func (usageRecord) TableName() string {
+	return "token_usage"
+}
+
+// SQLStore keeps limits in the storage database so replicas share them
+type SQLStore struct {
+	db *gorm.DB
+}
+
+// NewSQLStore creates a store and migrates its tables
+func NewSQLStore(db *gorm.DB) (*SQLStore, error) {
+	if err := db.AutoMigrate(&bucketRecord{}, &usageRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate rate limits: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Take refills the bucket and takes a token in a single conditional UPDATE, so
+// concurrent replicas cannot both spend the last token. A locking read would
+// not serialize them on SQLite, which ignores row locks.
+func (s *SQLStore) Take(ctx context.Context, key string, rate float64, burst int, now time.Time) (bool, time.Duration, error) {
+	db := s.db.WithContext(ctx)
+
+	// create the bucket full
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&bucketRecord{Caller: key, Tokens: float64(burst), RefilledAt: now.UnixNano()}).Error
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to update rate limit: %w", err)
+	}
+
+	tokens := refilledTokens(rate, burst, now)
+	result := db.Model(&bucketRecord{}).
+		Where("caller = ? AND ? >= 1", key, tokens).
+		Updates(map[string]any{
+			"tokens":      gorm.Expr("? - 1", tokens),
+			"refilled_at": gorm.Expr("CASE WHEN refilled_at < ? THEN ? ELSE refilled_at END", now.UnixNano(), now.UnixNano()),
+		})
+	if result.Error != nil {
+		return false, 0, fmt.Errorf("failed to update rate limit: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return true, 0, nil
+	}
+
+	// the bucket is empty, nothing changes until it refills
+	var record bucketRecord
+	if err := db.Where("caller = ?", key).First(&record).Error; err != nil {
+		return false, 0, fmt.Errorf("failed to load rate limit: %w", err)
+	}
+	refilled := refill(record.Tokens, now.Sub(time.Unix(0, record.RefilledAt)), rate, burst)
+	_, _, wait := take(refilled, rate)
+	return false, wait, nil
+}
+
+// refilledTokens is refill as a SQL expression on the bucket row. Rates and
+// burst are cast so PostgreSQL does not infer integer parameters from the
+// refilled_at column.
+func refilledTokens(rate float64, burst int, now time.Time) clause.Expr {
+	// a replica whose clock is behind the last refill adds nothing
+	elapsed := gorm.Expr("CASE WHEN refilled_at < ? THEN ? - refilled_at ELSE 0 END", now.UnixNano(), now.UnixNano())
+	tokens := gorm.Expr("tokens + ? * CAST(? AS DOUBLE PRECISION)", elapsed, rate/float64(time.Second))
+	limit := gorm.Expr("CAST(? AS DOUBLE PRECISION)", float64(burst))
+	return gorm.Expr("CASE WHEN ? < ? THEN ? ELSE ? END", tokens, limit, tokens, limit)
+}
+
+func (s *SQLStore) Usage(ctx context.Context, key, day string) (int64, error) {
+	var record usageRecord
+	err := s.db.WithContext(ctx).
+		Where("caller = ? AND day = ?", key, day).
+		Limit(1).
+		Find(&record).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to load token usage: %w", err)
+	}
+	return record.Tokens, nil
+}
+
+func (s *SQLStore) AddUsage(ctx context.Context, key, day string, tokens int64) error {
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "caller"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"tokens": gorm.Expr("token_usage.tokens + ?", tokens),
+		}),
+	}).Create(&usageRecord{Caller: key, Day: day, Tokens: tokens}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record token usage: %w", err)
+	}
+	return nil
+}
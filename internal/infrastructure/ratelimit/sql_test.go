@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mololab/alodb/internal/infrastructure/storage"
+)
+
+// replicas share the bucket through the database, so concurrent requests
+// never spend more than the burst
+func TestSQLStoreTakeConcurrently(t *testing.T) {
+	const (
+		replicas = 3
+		requests = 20
+		burst    = 10
+	)
+
+	dsn := filepath.Join(t.TempDir(), "alodb.db")
+	stores := make([]*SQLStore, replicas)
+	for i := range stores {
+		store, err := storage.Open(storage.Config{Driver: storage.DriverSQLite, DSN: dsn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+
+		if stores[i], err = NewSQLStore(store.DB()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := range replicas * requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a negligible rate, so nothing refills during the test
+			ok, _, err := stores[i%replicas].Take(context.Background(), "key:1", 1e-9, burst, now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != burst {
+		t.Errorf("allowed = %d, want %d", allowed, burst)
+	}
+}
+
+func TestSQLStoreTakeClockSkew(t *testing.T) {
+	ctx := context.Background()
+	store := testStores(t)["sql"]
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	if ok, _, err := store.Take(ctx, "key:1", 1, 1, now); err != nil || !ok {
+		t.Fatalf("Take = %v, %v", ok, err)
+	}
+
+	// a replica whose clock is behind neither refills nor moves the bucket back
+	if ok, wait, err := store.Take(ctx, "key:1", 1, 1, now.Add(-time.Minute)); err != nil || ok || wait != time.Second {
+		t.Errorf("behind: Take = %v, %v, %v, want a wait of 1s", ok, wait, err)
+	}
+	if ok, _, err := store.Take(ctx, "key:1", 1, 1, now.Add(500*time.Millisecond)); err != nil || ok {
+		t.Errorf("half refilled: Take = %v, %v", ok, err)
+	}
+	if ok, _, err := store.Take(ctx, "key:1", 1, 1, now.Add(time.Second)); err != nil || !ok {
+		t.Errorf("refilled: Take = %v, %v", ok, err)
+	}
+}
+
+func TestSQLStoreUsage(t *testing.T) {
+	ctx := context.Background()
+	store := testStores(t)["sql"]
+
+	for _, tokens := range []int64{10, 20, 30} {
+		if err := store.AddUsage(ctx, "key:1", "2026-10-17", tokens); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.AddUsage(ctx, "key:1", "2026-10-18", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, day string
+		want     int64
+	}{
+		{"key:1", "2026-10-17", 60},
+		{"key:1", "2026-10-18", 5},
+		{"key:1", "2026-10-19", 0},
+		{"key:2", "2026-10-17", 0},
+	}
+	for _, tt := range tests {
+		if used, err := store.Usage(ctx, tt.key, tt.day); err != nil || used != tt.want {
+			t.Errorf("Usage(%s, %s) = %d, %v, want %d", tt.key, tt.day, used, err, tt.want)
+		}
+	}
+}
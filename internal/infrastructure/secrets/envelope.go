@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	envelopeVersion = 1
+	// ValuePrefix marks encrypted configuration values
+	ValuePrefix = "enc:"
+)
+
+var base64URL = base64.RawURLEncoding
+
+// envelope is the stored form of an encrypted secret
+type envelope struct {
+	Version    int    `json:"v"`
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"dek"`
+	Ciphertext []byte `json:"ct"`
+}
+
+// Cipher performs envelope encryption with data keys wrapped by a KeyProvider
+type Cipher struct {
+	provider KeyProvider
+}
+
+// NewCipher creates a cipher using the provider's master keys
+func NewCipher(provider KeyProvider) *Cipher {
+	return &Cipher{provider: provider}
+}
+
+// Encrypt encrypts plaintext with a new data key. additionalData binds the
+// ciphertext to its owner, the same value is required to decrypt.
+func (c *Cipher) Encrypt(ctx context.Context, plaintext, additionalData []byte) ([]byte, error) {
+	dataKey, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(aead, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, wrapped, err := c.provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{
+		Version:    envelopeVersion,
+		KeyID:      keyID,
+		WrappedKey: wrapped,
+		Ciphertext: ciphertext,
+	})
+}
+
+// Decrypt decrypts a secret produced by Encrypt
+func (c *Cipher) Decrypt(ctx context.Context, data, additionalData []byte) ([]byte, error) {
+	env, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := c.provider.UnwrapKey(ctx, env.KeyID, env.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return open(aead, env.Ciphertext, additionalData)
+}
+
+// NeedsRotation reports whether a secret was not wrapped by the current master key
+func (c *Cipher) NeedsRotation(data []byte) bool {
+	env, err := parseEnvelope(data)
+	return err == nil && env.KeyID != c.provider.CurrentKeyID()
+}
+
+// Rotate rewraps the data key of a secret with the current master key. The
+// ciphertext itself is unchanged.
+func (c *Cipher) Rotate(ctx context.Context, data []byte) ([]byte, error) {
+	env, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := c.provider.UnwrapKey(ctx, env.KeyID, env.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	env.KeyID, env.WrappedKey, err = c.provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(env)
+}
+
+// EncryptValue encrypts a configuration value into its "enc:" form
+func (c *Cipher) EncryptValue(ctx context.Context, value string) (string, error) {
+	data, err := c.Encrypt(ctx, []byte(value), nil)
+	if err != nil {
+		return "", err
+	}
+	return ValuePrefix + base64URL.EncodeToString(data), nil
+}
+
+// DecryptValue decrypts an "enc:" configuration value, other values are returned unchanged
+func (c *Cipher) DecryptValue(ctx context.Context, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, ValuePrefix)
+	if !ok {
+		return value, nil
+	}
+
+	data, err := base64URL.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid encoding", ErrDecrypt)
+	}
+
+	plaintext, err := c.Decrypt(ctx, data, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncryptedValue reports whether a configuration value is in "enc:" form
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, ValuePrefix)
+}
+
+func parseEnvelope(data []byte) (*envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("%w: malformed envelope", ErrDecrypt)
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("%w: unsupported envelope version %d", ErrDecrypt, env.Version)
+	}
+	return &env, nil
+}
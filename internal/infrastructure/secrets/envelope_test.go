@@ -0,0 +1,194 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, current []byte, previous ...[]byte) *Cipher {
+	t.Helper()
+	keyring, err := NewKeyring(current, previous...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCipher(keyring)
+}
+
+func TestCipherDecrypt(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	c := newTestCipher(t, key)
+
+	data, err := c.Encrypt(ctx, []byte("s3cret"), []byte("profile-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Clone(data)
+	var env envelope
+	if err := json.Unmarshal(tampered, &env); err != nil {
+		t.Fatal(err)
+	}
+	env.Ciphertext[len(env.Ciphertext)-1] ^= 1
+	tampered, _ = json.Marshal(env)
+
+	tests := []struct {
+		name   string
+		cipher *Cipher
+		data   []byte
+		aad    string
+		err    error
+	}{
+		{"round trip", c, data, "profile-1", nil},
+		{"wrong additional data", c, data, "profile-2", ErrDecrypt},
+		{"missing additional data", c, data, "", ErrDecrypt},
+		{"unknown key", newTestCipher(t, newTestKey(t)), data, "profile-1", ErrUnknownKey},
+		{"tampered ciphertext", c, tampered, "profile-1", ErrDecrypt},
+		{"malformed envelope", c, []byte("s3cret"), "profile-1", ErrDecrypt},
+		{"unsupported version", c, []byte(`{"v":2}`), "profile-1", ErrDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := tt.cipher.Decrypt(ctx, tt.data, []byte(tt.aad))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && string(plaintext) != "s3cret" {
+				t.Errorf("plaintext = %q, want %q", plaintext, "s3cret")
+			}
+		})
+	}
+}
+
+func TestCipherEncryptUsesFreshDataKeys(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCipher(t, newTestKey(t))
+
+	first, err := c.Encrypt(ctx, []byte("s3cret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Encrypt(ctx, []byte("s3cret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := parseEnvelope(first)
+	b, _ := parseEnvelope(second)
+	if bytes.Equal(a.WrappedKey, b.WrappedKey) || bytes.Equal(a.Ciphertext, b.Ciphertext) {
+		t.Error("encrypting twice reused the data key or nonce")
+	}
+}
+
+func TestCipherRotate(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+
+	before := newTestCipher(t, oldKey)
+	data, err := before.Encrypt(ctx, []byte("s3cret"), []byte("profile-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.NeedsRotation(data) {
+		t.Error("NeedsRotation = true for a secret under the current key")
+	}
+
+	after := newTestCipher(t, newKey, oldKey)
+	if !after.NeedsRotation(data) {
+		t.Fatal("NeedsRotation = false for a secret under a previous key")
+	}
+	if after.NeedsRotation([]byte("not an envelope")) {
+		t.Error("NeedsRotation = true for a malformed envelope")
+	}
+
+	// old ciphertext still decrypts until it is rotated
+	if plaintext, err := after.Decrypt(ctx, data, []byte("profile-1")); err != nil || string(plaintext) != "s3cret" {
+		t.Fatalf("Decrypt before rotation = %q, %v", plaintext, err)
+	}
+
+	rotated, err := after.Rotate(ctx, data)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if after.NeedsRotation(rotated) {
+		t.Error("NeedsRotation = true after rotation")
+	}
+
+	env, err := parseEnvelope(rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.KeyID != KeyID(newKey) {
+		t.Errorf("key ID = %s, want %s", env.KeyID, KeyID(newKey))
+	}
+	original, _ := parseEnvelope(data)
+	if !bytes.Equal(env.Ciphertext, original.Ciphertext) {
+		t.Error("Rotate changed the ciphertext, only the data key should be rewrapped")
+	}
+
+	if plaintext, err := after.Decrypt(ctx, rotated, []byte("profile-1")); err != nil || string(plaintext) != "s3cret" {
+		t.Errorf("Decrypt after rotation = %q, %v", plaintext, err)
+	}
+	// once the old key is retired only the rotated secret decrypts
+	retired := newTestCipher(t, newKey)
+	if _, err := retired.Decrypt(ctx, rotated, []byte("profile-1")); err != nil {
+		t.Errorf("Decrypt with the old key retired: %v", err)
+	}
+	if _, err := retired.Decrypt(ctx, data, []byte("profile-1")); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want %v", err, ErrUnknownKey)
+	}
+
+	if _, err := newTestCipher(t, newKey).Rotate(ctx, data); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Rotate without the old key: err = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestCipherDecryptValue(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCipher(t, newTestKey(t))
+
+	encrypted, err := c.EncryptValue(ctx, "postgres://app:s3cret@db/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedValue(encrypted) {
+		t.Fatalf("EncryptValue = %q, want the %q prefix", encrypted, ValuePrefix)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   error
+	}{
+		{"encrypted", encrypted, "postgres://app:s3cret@db/app", nil},
+		{"plain", "postgres://db/app", "postgres://db/app", nil},
+		{"invalid encoding", ValuePrefix + "!!", "", ErrDecrypt},
+		{"unknown key", mustEncryptValue(t, newTestCipher(t, newTestKey(t)), "x"), "", ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.DecryptValue(ctx, tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("value = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustEncryptValue(t *testing.T, c *Cipher, value string) string {
+	t.Helper()
+	encrypted, err := c.EncryptValue(context.Background(), value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted
+}
@@ -0,0 +1,194 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of an AES-256 key in bytes
+const KeySize = 32
+
+// Config selects where master keys come from. KeyFile takes precedence over Key.
+type Config struct {
+	// Key is the current base64 encoded master key
+	Key string
+	// PreviousKeys are retired master keys, kept to decrypt until secrets are rotated
+	PreviousKeys []string
+	// KeyFile holds one base64 key per line, the first is current
+	KeyFile string
+}
+
+// Keyring is a KeyProvider holding master keys in memory, loaded from
+// configuration or a key file. Keys are identified by a fingerprint.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring wrapping with current and unwrapping with any of the keys
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+
+	for i, key := range append([][]byte{current}, previous...) {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		id := KeyID(key)
+		if i == 0 {
+			k.current = id
+		}
+		k.keys[id] = aead
+	}
+
+	return k, nil
+}
+
+// LoadKeyring builds a keyring from configuration, ErrNotConfigured when no key is set
+func LoadKeyring(cfg Config) (*Keyring, error) {
+	encoded := append([]string{cfg.Key}, cfg.PreviousKeys...)
+
+	if cfg.KeyFile != "" {
+		lines, err := readKeyFile(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = lines
+	}
+
+	if len(encoded) == 0 || strings.TrimSpace(encoded[0]) == "" {
+		return nil, ErrNotConfigured
+	}
+
+	var keys [][]byte
+	for _, s := range encoded {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		key, err := ParseKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyring(keys[0], keys[1:]...)
+}
+
+// CurrentKeyID returns the fingerprint of the current master key
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// WrapKey encrypts a data key with the current master key
+func (k *Keyring) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return k.current, wrapped, nil
+}
+
+// UnwrapKey decrypts a data key with the master key it was wrapped with
+func (k *Keyring) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// KeyID returns the fingerprint identifying a master key
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseKey decodes a base64 encoded 32 byte key
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// GenerateKey returns a random 32 byte key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// readKeyFile reads base64 keys from a file, skipping blank lines and # comments
+func readKeyFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	return keys, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext, the result is nonce followed by ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, ErrDecrypt
+	}
+
+	plaintext, err := aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
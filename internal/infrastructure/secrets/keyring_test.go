@@ -0,0 +1,132 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func writeKeyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeyring(t *testing.T) {
+	current, previous := newTestKey(t), newTestKey(t)
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		current []byte
+		keys    int
+		err     string
+	}{
+		{"key", Config{Key: encodeKey(current)}, current, 1, ""},
+		{"previous keys", Config{Key: encodeKey(current), PreviousKeys: []string{"", encodeKey(previous)}}, current, 2, ""},
+		{"key file", Config{KeyFile: writeKeyFile(t, "# rotated 2026-10-01\n"+encodeKey(current)+"\n\n  "+encodeKey(previous)+"\n")}, current, 2, ""},
+		{"key file over key", Config{Key: encodeKey(previous), KeyFile: writeKeyFile(t, encodeKey(current)+"\n")}, current, 1, ""},
+		{"not configured", Config{}, nil, 0, ErrNotConfigured.Error()},
+		{"blank key", Config{Key: "  "}, nil, 0, ErrNotConfigured.Error()},
+		{"empty key file", Config{Key: encodeKey(current), KeyFile: writeKeyFile(t, "# no keys yet\n")}, nil, 0, ErrNotConfigured.Error()},
+		{"missing key file", Config{KeyFile: filepath.Join(t.TempDir(), "missing")}, nil, 0, "failed to open key file"},
+		{"malformed key file", Config{KeyFile: writeKeyFile(t, "not base64!\n")}, nil, 0, "encryption key is not valid base64"},
+		{"short key in key file", Config{KeyFile: writeKeyFile(t, encodeKey(current[:16])+"\n")}, nil, 0, "encryption key must be 32 bytes, got 16"},
+		{"malformed previous key", Config{Key: encodeKey(current), PreviousKeys: []string{"c2hvcnQ="}}, nil, 0, "encryption key must be 32 bytes, got 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := LoadKeyring(tt.cfg)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeyring: %v", err)
+			}
+			if keyring.CurrentKeyID() != KeyID(tt.current) {
+				t.Errorf("current key = %s, want %s", keyring.CurrentKeyID(), KeyID(tt.current))
+			}
+			if len(keyring.keys) != tt.keys {
+				t.Errorf("keys = %d, want %d", len(keyring.keys), tt.keys)
+			}
+		})
+	}
+}
+
+func TestKeyringWrapKey(t *testing.T) {
+	ctx := context.Background()
+	current, previous := newTestKey(t), newTestKey(t)
+
+	old, err := NewKeyring(previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := NewKeyring(current, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataKey := newTestKey(t)
+	oldID, oldWrapped, err := old.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, wrapped, err := keyring.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != KeyID(current) || oldID != KeyID(previous) {
+		t.Fatalf("key IDs = %s, %s, want %s, %s", keyID, oldID, KeyID(current), KeyID(previous))
+	}
+
+	tests := []struct {
+		name    string
+		keyID   string
+		wrapped []byte
+		err     error
+	}{
+		{"current key", keyID, wrapped, nil},
+		{"previous key", oldID, oldWrapped, nil},
+		{"unknown key", KeyID(newTestKey(t)), wrapped, ErrUnknownKey},
+		// the key ID is authenticated, a wrapped key cannot be relabeled
+		{"wrong key ID", oldID, wrapped, ErrDecrypt},
+		{"truncated", keyID, wrapped[:8], ErrDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyring.UnwrapKey(ctx, tt.keyID, tt.wrapped)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && !bytes.Equal(got, dataKey) {
+				t.Errorf("data key = %x, want %x", got, dataKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+)
+
+// KMSClient is the subset of a key management service used to wrap data keys,
+// such as AWS KMS, Google Cloud KMS or Vault transit
+type KMSClient interface {
+	Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// KMSProvider is a KeyProvider whose master keys never leave the KMS. Rotating
+// means switching keyID, the KMS keeps decrypting with previous keys.
+type KMSProvider struct {
+	client KMSClient
+	keyID  string
+}
+
+// NewKMSProvider creates a provider wrapping data keys with the given KMS key
+func NewKMSProvider(client KMSClient, keyID string) (*KMSProvider, error) {
+	if client == nil || keyID == "" {
+		return nil, fmt.Errorf("KMS client and key ID are required")
+	}
+	return &KMSProvider{client: client, keyID: keyID}, nil
+}
+
+// CurrentKeyID returns the KMS key wrapping new data keys
+func (p *KMSProvider) CurrentKeyID() string {
+	return p.keyID
+}
+
+// WrapKey encrypts a data key with the current KMS key
+func (p *KMSProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := p.client.Encrypt(ctx, p.keyID, dataKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("KMS encrypt failed: %w", err)
+	}
+	return p.keyID, wrapped, nil
+}
+
+// UnwrapKey decrypts a data key with the KMS key it was wrapped with
+func (p *KMSProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	dataKey, err := p.client.Decrypt(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("KMS decrypt failed: %w", err)
+	}
+	return dataKey, nil
+}
@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fakeKMS wraps keys by prefixing them with the KMS key ID, and forgets
+// nothing, like a KMS that keeps previous key versions
+type fakeKMS struct {
+	fail error
+}
+
+func (k *fakeKMS) Encrypt(_ context.Context, keyID string, plaintext []byte) ([]byte, error) {
+	if k.fail != nil {
+		return nil, k.fail
+	}
+	return append([]byte(keyID+":"), plaintext...), nil
+}
+
+func (k *fakeKMS) Decrypt(_ context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	if k.fail != nil {
+		return nil, k.fail
+	}
+	plaintext, ok := bytes.CutPrefix(ciphertext, []byte(keyID+":"))
+	if !ok {
+		return nil, fmt.Errorf("ciphertext was not encrypted with %s", keyID)
+	}
+	return plaintext, nil
+}
+
+func TestNewKMSProvider(t *testing.T) {
+	if _, err := NewKMSProvider(nil, "key-1"); err == nil {
+		t.Error("err = nil without a client")
+	}
+	if _, err := NewKMSProvider(&fakeKMS{}, ""); err == nil {
+		t.Error("err = nil without a key ID")
+	}
+}
+
+func TestKMSProviderRotate(t *testing.T) {
+	ctx := context.Background()
+	kms := &fakeKMS{}
+
+	v1, err := NewKMSProvider(kms, "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := NewCipher(v1).Encrypt(ctx, []byte("s3cret"), []byte("profile-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v2, err := NewKMSProvider(kms, "key-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCipher(v2)
+	if !c.NeedsRotation(data) {
+		t.Fatal("NeedsRotation = false after switching the KMS key")
+	}
+
+	rotated, err := c.Rotate(ctx, data)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if c.NeedsRotation(rotated) {
+		t.Error("NeedsRotation = true after rotation")
+	}
+	for _, d := range [][]byte{data, rotated} {
+		if plaintext, err := c.Decrypt(ctx, d, []byte("profile-1")); err != nil || string(plaintext) != "s3cret" {
+			t.Errorf("Decrypt = %q, %v", plaintext, err)
+		}
+	}
+}
+
+func TestKMSProviderErrors(t *testing.T) {
+	ctx := context.Background()
+	unavailable := errors.New("kms unavailable")
+	p, err := NewKMSProvider(&fakeKMS{fail: unavailable}, "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewCipher(p).Encrypt(ctx, []byte("s3cret"), nil); !errors.Is(err, unavailable) {
+		t.Errorf("Encrypt: err = %v, want %v", err, unavailable)
+	}
+	if _, _, err := p.WrapKey(ctx, []byte("key")); err == nil || err.Error() != "KMS encrypt failed: kms unavailable" {
+		t.Errorf("WrapKey: err = %v", err)
+	}
+	if _, err := p.UnwrapKey(ctx, "key-1", []byte("key-1:key")); err == nil || err.Error() != "KMS decrypt failed: kms unavailable" {
+		t.Errorf("UnwrapKey: err = %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+// Package secrets encrypts credentials before they are persisted, using
+// AES-GCM envelope encryption: every secret is encrypted with its own data
+// key, and data keys are wrapped by a master key from a KeyProvider.
+package secrets
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNotConfigured is returned when no master key is configured
+	ErrNotConfigured = errors.New("secrets encryption key is not configured")
+	// ErrUnknownKey is returned when a secret was wrapped by a master key the provider does not have
+	ErrUnknownKey = errors.New("secret was encrypted with an unknown master key")
+	// ErrDecrypt is returned when a secret cannot be decrypted
+	ErrDecrypt = errors.New("failed to decrypt secret")
+)
+
+// KeyProvider wraps and unwraps data keys with master keys. Implementations
+// keep old master keys available for unwrapping so keys can be rotated.
+type KeyProvider interface {
+	// CurrentKeyID identifies the master key that wraps new data keys
+	CurrentKeyID() string
+	// WrapKey encrypts a data key with the current master key
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped by the given master key
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
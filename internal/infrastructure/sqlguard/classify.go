@@ -0,0 +1,543 @@
+package sqlguard
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mololab/alodb/internal/domain/database"
+)
+
+// Statement is the classification of a single SQL statement
+type Statement struct {
+	// Command is the leading keyword, for WITH queries the keyword of the main statement
+	Command  string
+	Kind     database.QueryKind
+	ReadOnly bool
+	Level    database.RiskLevel
+	Flags    []database.RiskFlag
+}
+
+// Analysis is the classification of a SQL text
+type Analysis struct {
+	Statements []Statement
+	// Err is set when the text could not be tokenized, e.g. an unterminated string
+	Err error
+}
+
+var levelRank = map[database.RiskLevel]int{
+	database.RiskNone:   0,
+	database.RiskLow:    1,
+	database.RiskMedium: 2,
+	database.RiskHigh:   3,
+}
+
+// mainCommands are the statements a WITH clause can precede
+var mainCommands = map[string]bool{
+	"SELECT": true,
+	"VALUES": true,
+	"TABLE":  true,
+	"INSERT": true,
+	"UPDATE": true,
+	"DELETE": true,
+	"MERGE":  true,
+}
+
+// sessionCommands only affect the session or transaction
+var sessionCommands = map[string]bool{
+	"BEGIN": true, "START": true, "COMMIT": true, "END": true, "ROLLBACK": true, "ABORT": true,
+	"SAVEPOINT": true, "RELEASE": true, "SET": true, "RESET": true, "DISCARD": true,
+	"LISTEN": true, "UNLISTEN": true, "NOTIFY": true, "PREPARE": true, "DEALLOCATE": true,
+	"DECLARE": true, "FETCH": true, "MOVE": true, "CLOSE": true,
+}
+
+// maintenanceCommands rewrite or lock data without changing its content
+var maintenanceCommands = map[string]bool{
+	"VACUUM": true, "ANALYZE": true, "ANALYSE": true, "REINDEX": true, "CLUSTER": true,
+	"REFRESH": true, "CHECKPOINT": true, "LOCK": true, "LOAD": true,
+}
+
+var ddlCommands = map[string]bool{
+	"CREATE": true, "ALTER": true, "COMMENT": true, "GRANT": true, "REVOKE": true,
+	"SECURITY": true, "IMPORT": true,
+}
+
+// writeFunctions change state even when called from a SELECT. A read-only
+// transaction blocks none of them, set_config even lifts the statement timeout
+// of the transaction.
+var writeFunctions = map[string]bool{
+	"nextval": true, "setval": true, "set_config": true,
+	"lo_import": true, "lo_export": true, "lo_unlink": true, "lo_create": true,
+	"dblink_exec":          true,
+	"pg_terminate_backend": true, "pg_cancel_backend": true, "pg_reload_conf": true,
+	"pg_rotate_logfile": true, "pg_switch_wal": true, "pg_create_restore_point": true,
+}
+
+// sleepFunctions hold a connection, and with it a cursor slot, without doing work
+var sleepFunctions = map[string]bool{
+	"pg_sleep": true, "pg_sleep_for": true, "pg_sleep_until": true,
+}
+
+// fileFunctions read files of the database server
+var fileFunctions = map[string]bool{
+	"pg_read_file": true, "pg_read_binary_file": true, "pg_ls_dir": true, "pg_stat_file": true,
+}
+
+// Analyze tokenizes a SQL text and classifies each of its statements
+func Analyze(sql string) *Analysis {
+	tokens, err := lex(sql)
+	analysis := &Analysis{Err: err}
+	for _, stmt := range splitStatements(tokens) {
+		analysis.Statements = append(analysis.Statements, classify(stmt))
+	}
+	return analysis
+}
+
+// ReadOnly reports whether the text parsed and every statement only reads data
+func (a *Analysis) ReadOnly() bool {
+	if a.Err != nil || len(a.Statements) == 0 {
+		return false
+	}
+	for _, stmt := range a.Statements {
+		if !stmt.ReadOnly {
+			return false
+		}
+	}
+	return true
+}
+
+// Risk summarizes the analysis for API responses
+func (a *Analysis) Risk() *database.QueryRisk {
+	risk := &database.QueryRisk{
+		Kind:       database.QueryKindUnknown,
+		Level:      database.RiskNone,
+		Statements: len(a.Statements),
+	}
+
+	for _, stmt := range a.Statements {
+		risk.Flags = append(risk.Flags, stmt.Flags...)
+		risk.Level = maxLevel(risk.Level, stmt.Level)
+	}
+
+	switch {
+	case len(a.Statements) == 1:
+		risk.Kind = a.Statements[0].Kind
+	case len(a.Statements) > 1:
+		risk.Kind = database.QueryKindMultiStatement
+		risk.Flags = append(risk.Flags, flag("multiple_statements", database.RiskMedium,
+			"query contains %d statements", len(a.Statements)))
+	}
+
+	if a.Err != nil {
+		risk.Kind = database.QueryKindUnknown
+		risk.Flags = append(risk.Flags, flag("unparsable", database.RiskMedium,
+			"query could not be parsed: %v", a.Err))
+	}
+
+	for _, f := range risk.Flags {
+		risk.Level = maxLevel(risk.Level, f.Severity)
+	}
+	return risk
+}
+
+// splitStatements splits tokens at semicolons, dropping empty statements
+func splitStatements(tokens []token) [][]token {
+	var statements [][]token
+	start := 0
+	for i, t := range tokens {
+		if t.isPunct(";") {
+			if i > start {
+				statements = append(statements, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		statements = append(statements, tokens[start:])
+	}
+	return statements
+}
+
+// classify classifies the tokens of a single statement
+func classify(tokens []token) Statement {
+	start := 0
+	for start < len(tokens) && tokens[start].isPunct("(") {
+		start++
+	}
+	if start == len(tokens) || tokens[start].kind != tokenWord {
+		return Statement{Kind: database.QueryKindUnknown, Level: database.RiskMedium}
+	}
+
+	command := tokens[start].upper
+	stmt := Statement{Command: command, Level: database.RiskNone}
+
+	switch {
+	case command == "SELECT" || command == "VALUES" || command == "TABLE":
+		stmt.Kind = database.QueryKindReadOnly
+		stmt.ReadOnly = true
+		classifySelect(&stmt, tokens[start:])
+
+	case command == "SHOW":
+		stmt.Kind = database.QueryKindReadOnly
+		stmt.ReadOnly = true
+
+	case command == "WITH":
+		return classifyWith(tokens[start:])
+
+	case command == "EXPLAIN":
+		return classifyExplain(tokens[start:])
+
+	case command == "INSERT" || command == "MERGE":
+		stmt.Kind = database.QueryKindDML
+		stmt.Level = database.RiskMedium
+
+	case command == "UPDATE" || command == "DELETE":
+		stmt.Kind = database.QueryKindDML
+		stmt.Level = database.RiskMedium
+		checkWhere(&stmt, tokens[start:])
+
+	case command == "TRUNCATE":
+		stmt.Kind = database.QueryKindDDL
+		stmt.addFlag(flag("truncate", database.RiskHigh,
+			"TRUNCATE removes every row of %s", targetName(tokens[start+1:], "TABLE", "ONLY")))
+
+	case command == "DROP":
+		stmt.Kind = database.QueryKindDDL
+		message := "DROP permanently removes database objects and their data"
+		if containsWord(tokens, "CASCADE") {
+			message += ", CASCADE also removes every dependent object"
+		}
+		stmt.addFlag(database.RiskFlag{Code: "drop", Severity: database.RiskHigh, Message: message})
+
+	case ddlCommands[command]:
+		stmt.Kind = database.QueryKindDDL
+		stmt.Level = database.RiskMedium
+		if command == "ALTER" && alterDrops(tokens[start:]) {
+			stmt.addFlag(flag("drop", database.RiskHigh,
+				"ALTER ... DROP permanently removes part of an object and its data"))
+		}
+
+	case command == "COPY":
+		classifyCopy(&stmt, tokens[start:])
+
+	case command == "DO" || command == "CALL" || command == "EXECUTE":
+		stmt.Kind = database.QueryKindOther
+		stmt.addFlag(flag("procedural_code", database.RiskMedium,
+			"%s runs server-side code whose effects cannot be analyzed", command))
+
+	case sessionCommands[command]:
+		stmt.Kind = database.QueryKindOther
+		stmt.Level = database.RiskLow
+
+	case maintenanceCommands[command]:
+		stmt.Kind = database.QueryKindOther
+		stmt.Level = database.RiskMedium
+
+	default:
+		stmt.Kind = database.QueryKindUnknown
+		stmt.Level = database.RiskMedium
+	}
+
+	return stmt
+}
+
+// classifySelect flags SELECT INTO, row locks and calls of functions with side effects
+func classifySelect(stmt *Statement, tokens []token) {
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+
+		case depth == 0 && t.is("INTO") && stmt.Command == "SELECT":
+			stmt.Kind = database.QueryKindDDL
+			stmt.ReadOnly = false
+			stmt.Level = maxLevel(stmt.Level, database.RiskMedium)
+
+		case t.is("FOR") && i+1 < len(tokens) && isLockStrength(tokens[i+1]):
+			if stmt.Kind == database.QueryKindReadOnly {
+				stmt.Kind = database.QueryKindDML
+			}
+			stmt.ReadOnly = false
+			stmt.addFlag(flag("row_locks", database.RiskLow,
+				"FOR %s locks the selected rows until the transaction ends", tokens[i+1].upper))
+
+		// quoted names are case-sensitive, "pg_sleep"( and U&"\0070g_sleep"( call
+		// pg_sleep, "PG_SLEEP"( does not
+		case (t.kind == tokenWord || t.kind == tokenQuotedIdent) && i+1 < len(tokens) && tokens[i+1].isPunct("("):
+			name := t.name
+			if writeFunctions[name] {
+				if stmt.Kind == database.QueryKindReadOnly {
+					stmt.Kind = database.QueryKindDML
+				}
+				stmt.ReadOnly = false
+				stmt.addFlag(flag("side_effects", database.RiskMedium,
+					"%s() changes server state", name))
+			}
+			if sleepFunctions[name] {
+				if stmt.Kind == database.QueryKindReadOnly {
+					stmt.Kind = database.QueryKindOther
+				}
+				stmt.ReadOnly = false
+				stmt.addFlag(flag("sleep", database.RiskMedium,
+					"%s() holds the connection without doing work", name))
+			}
+			if fileFunctions[name] {
+				stmt.addFlag(flag("server_file_access", database.RiskHigh,
+					"%s() reads files of the database server", name))
+			}
+		}
+	}
+}
+
+// classifyWith classifies the main statement of a WITH query and its data-modifying CTEs
+func classifyWith(tokens []token) Statement {
+	main := -1
+	depth := 0
+	for i := 1; i < len(tokens) && main < 0; i++ {
+		t := tokens[i]
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case depth == 0 && t.kind == tokenWord && mainCommands[t.upper]:
+			main = i
+		}
+	}
+	if main < 0 {
+		return Statement{Command: "WITH", Kind: database.QueryKindUnknown, Level: database.RiskMedium}
+	}
+
+	stmt := classify(tokens[main:])
+	classifySelect(&stmt, tokens[:main])
+
+	for i := 1; i < main; i++ {
+		t := tokens[i]
+		if !tokens[i-1].isPunct("(") || t.kind != tokenWord || !mainCommands[t.upper] {
+			continue
+		}
+		switch t.upper {
+		case "SELECT", "VALUES", "TABLE":
+			continue
+		case "UPDATE", "DELETE":
+			checkWhere(&stmt, tokens[i:])
+		}
+		stmt.Kind = database.QueryKindDML
+		stmt.ReadOnly = false
+		stmt.Level = maxLevel(stmt.Level, database.RiskMedium)
+	}
+
+	return stmt
+}
+
+// classifyExplain treats EXPLAIN as read-only unless ANALYZE executes the statement
+func classifyExplain(tokens []token) Statement {
+	analyze := false
+	i := 1
+
+	if i < len(tokens) && tokens[i].isPunct("(") {
+		for i++; i < len(tokens) && !tokens[i].isPunct(")"); i++ {
+			if tokens[i].is("ANALYZE") || tokens[i].is("ANALYSE") {
+				analyze = i+1 >= len(tokens) || !isFalse(tokens[i+1])
+			}
+		}
+		i++
+	} else {
+		for ; i < len(tokens) && (tokens[i].is("ANALYZE") || tokens[i].is("ANALYSE") || tokens[i].is("VERBOSE")); i++ {
+			if !tokens[i].is("VERBOSE") {
+				analyze = true
+			}
+		}
+	}
+
+	if !analyze || i >= len(tokens) {
+		return Statement{Command: "EXPLAIN", Kind: database.QueryKindReadOnly, ReadOnly: true, Level: database.RiskNone}
+	}
+
+	stmt := classify(tokens[i:])
+	stmt.Command = "EXPLAIN"
+	return stmt
+}
+
+// classifyCopy classifies COPY by direction and flags server-side files and programs
+func classifyCopy(stmt *Statement, tokens []token) {
+	stmt.Kind = database.QueryKindOther
+	stmt.Level = database.RiskMedium
+
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case depth == 0 && (t.is("FROM") || t.is("TO")):
+			if t.is("FROM") {
+				stmt.Kind = database.QueryKindDML
+			} else {
+				stmt.Kind = database.QueryKindReadOnly
+				stmt.ReadOnly = true
+				stmt.Level = database.RiskNone
+			}
+
+			if i+1 < len(tokens) {
+				switch target := tokens[i+1]; {
+				case target.is("PROGRAM"):
+					stmt.ReadOnly = false
+					stmt.addFlag(flag("copy_program", database.RiskHigh,
+						"COPY ... PROGRAM runs a shell command on the database server"))
+				case target.kind == tokenString:
+					access := "writes"
+					if t.is("FROM") {
+						access = "reads"
+					}
+					stmt.ReadOnly = false
+					stmt.addFlag(flag("server_file_access", database.RiskHigh,
+						"COPY %s a file on the database server", access))
+				}
+			}
+			if !stmt.ReadOnly && stmt.Kind == database.QueryKindReadOnly {
+				stmt.Kind = database.QueryKindOther
+			}
+			return
+		}
+	}
+}
+
+// checkWhere flags UPDATE and DELETE statements that affect every row
+func checkWhere(stmt *Statement, tokens []token) {
+	command := tokens[0].upper
+	skip := []string{"ONLY"}
+	if command == "DELETE" {
+		skip = []string{"FROM", "ONLY"}
+	}
+	table := targetName(tokens[1:], skip...)
+
+	where, ok := whereClause(tokens[1:])
+	switch {
+	case !ok:
+		stmt.addFlag(flag(strings.ToLower(command)+"_without_where", database.RiskHigh,
+			"%s without WHERE affects every row of %s", command, table))
+	case isTautology(where):
+		stmt.addFlag(flag(strings.ToLower(command)+"_without_where", database.RiskHigh,
+			"%s with an always true WHERE affects every row of %s", command, table))
+	}
+}
+
+// whereClause returns the tokens of the WHERE clause at the top level of a
+// statement, stopping at the end of the enclosing parentheses
+func whereClause(tokens []token) ([]token, bool) {
+	depth := 0
+	start := -1
+	for i, t := range tokens {
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+			if depth < 0 {
+				if start < 0 {
+					return nil, false
+				}
+				return tokens[start:i], true
+			}
+		case depth == 0 && start < 0 && t.is("WHERE"):
+			start = i + 1
+		case depth == 0 && start >= 0 && t.is("RETURNING"):
+			return tokens[start:i], true
+		}
+	}
+	if start < 0 {
+		return nil, false
+	}
+	return tokens[start:], true
+}
+
+// isTautology recognizes the trivially true conditions WHERE true and WHERE 1 = 1
+func isTautology(where []token) bool {
+	switch len(where) {
+	case 1:
+		return where[0].is("TRUE")
+	case 3:
+		return where[1].isPunct("=") && where[0].kind != tokenWord &&
+			where[0].kind == where[2].kind && where[0].text == where[2].text
+	}
+	return false
+}
+
+// alterDrops reports whether an ALTER statement drops a column, constraint or other part
+func alterDrops(tokens []token) bool {
+	for i, t := range tokens {
+		if !t.is("DROP") || i+1 >= len(tokens) {
+			continue
+		}
+		// DROP NOT NULL, DROP DEFAULT and DROP IDENTITY keep the data
+		switch tokens[i+1].upper {
+		case "NOT", "DEFAULT", "IDENTITY", "EXPRESSION":
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// targetName returns the possibly qualified name at the start of tokens after skipping keywords
+func targetName(tokens []token, skip ...string) string {
+	i := 0
+	for i < len(tokens) && tokens[i].kind == tokenWord && slices.Contains(skip, tokens[i].upper) {
+		i++
+	}
+
+	var name strings.Builder
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != tokenWord && t.kind != tokenQuotedIdent && !t.isPunct(".") {
+			break
+		}
+		if t.kind != tokenPunct && name.Len() > 0 && !strings.HasSuffix(name.String(), ".") {
+			break
+		}
+		name.WriteString(t.text)
+	}
+
+	if name.Len() == 0 {
+		return "the table"
+	}
+	return name.String()
+}
+
+func isLockStrength(t token) bool {
+	return t.is("UPDATE") || t.is("SHARE") || t.is("NO") || t.is("KEY")
+}
+
+func isFalse(t token) bool {
+	return t.is("FALSE") || t.is("OFF") || t.text == "0"
+}
+
+func containsWord(tokens []token, keyword string) bool {
+	for _, t := range tokens {
+		if t.is(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Statement) addFlag(f database.RiskFlag) {
+	s.Flags = append(s.Flags, f)
+	s.Level = maxLevel(s.Level, f.Severity)
+}
+
+func flag(code string, severity database.RiskLevel, format string, args ...any) database.RiskFlag {
+	return database.RiskFlag{Code: code, Severity: severity, Message: fmt.Sprintf(format, args...)}
+}
+
+func maxLevel(a, b database.RiskLevel) database.RiskLevel {
+	if levelRank[b] > levelRank[a] {
+		return b
+	}
+	return a
+}
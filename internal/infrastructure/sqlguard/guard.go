@@ -0,0 +1,43 @@
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmptyQuery         = errors.New("query is empty")
+	ErrInvalidSyntax      = errors.New("query could not be parsed")
+	ErrMultipleStatements = errors.New("only a single statement is allowed")
+	ErrNotReadOnly        = errors.New("only read-only SELECT queries are allowed")
+)
+
+// cursorCommands are the leading keywords of read-only statements that can be run through a cursor
+var cursorCommands = map[string]bool{
+	"SELECT": true,
+	"VALUES": true,
+	"TABLE":  true,
+}
+
+// CheckReadOnly verifies that the query is a single read-only statement
+func CheckReadOnly(query string) error {
+	analysis := Analyze(query)
+	if analysis.Err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSyntax, analysis.Err)
+	}
+
+	switch len(analysis.Statements) {
+	case 0:
+		return ErrEmptyQuery
+	case 1:
+	default:
+		return ErrMultipleStatements
+	}
+
+	stmt := analysis.Statements[0]
+	if !stmt.ReadOnly || !cursorCommands[stmt.Command] {
+		return ErrNotReadOnly
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package sqlguard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want error
+	}{
+		{"select", "SELECT * FROM users", nil},
+		{"select with trailing semicolon", "SELECT 1;", nil},
+		{"with select", "WITH x AS (SELECT 1) SELECT * FROM x", nil},
+		{"semicolon in dollar quote", "SELECT $$;$$", nil},
+		{"semicolon in escape string", `SELECT E'\';'`, nil},
+		{"semicolon in quoted identifier", `SELECT 1 AS "a;b"`, nil},
+		{"empty", " -- only a comment", ErrEmptyQuery},
+		{"unterminated dollar quote", "SELECT $$;", ErrInvalidSyntax},
+		{"unterminated comment", "SELECT 1 /* ;", ErrInvalidSyntax},
+		{"statement behind a comment", "SELECT 1 /* */; DELETE FROM users", ErrMultipleStatements},
+		{"with delete", "WITH x AS (DELETE FROM users RETURNING *) SELECT * FROM x", ErrNotReadOnly},
+		{"select for update", "SELECT * FROM users FOR UPDATE", ErrNotReadOnly},
+		{"update", "UPDATE users SET name = 'x' WHERE id = 1", ErrNotReadOnly},
+		{"delete", "DELETE FROM users", ErrNotReadOnly},
+		{"truncate", "TRUNCATE users", ErrNotReadOnly},
+		{"drop", "DROP TABLE users", ErrNotReadOnly},
+		// read-only, but not a statement a cursor can be declared for
+		{"explain", "EXPLAIN SELECT 1", ErrNotReadOnly},
+		{"explain analyze delete", "EXPLAIN ANALYZE DELETE FROM users", ErrNotReadOnly},
+		{"show", "SHOW search_path", ErrNotReadOnly},
+		{"set_config", "SELECT set_config('statement_timeout', '0', true)", ErrNotReadOnly},
+		{"quoted function", `SELECT "pg_terminate_backend"(123)`, ErrNotReadOnly},
+		{"unicode function", `SELECT U&"\0070g_sleep"(1)`, ErrNotReadOnly},
+		{"copy to program", "COPY users TO PROGRAM 'sh'", ErrNotReadOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckReadOnly(tt.sql); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
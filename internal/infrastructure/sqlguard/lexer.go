@@ -0,0 +1,317 @@
+package sqlguard
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	errUnterminatedString  = errors.New("unterminated quoted string")
+	errUnterminatedIdent   = errors.New("unterminated quoted identifier")
+	errUnterminatedComment = errors.New("unterminated comment")
+	errUnterminatedDollar  = errors.New("unterminated dollar-quoted string")
+	errInvalidUnicode      = errors.New("invalid Unicode escape in identifier")
+)
+
+type tokenKind int
+
+const (
+	tokenWord        tokenKind = iota // keyword or identifier
+	tokenQuotedIdent                  // "identifier", U&"identifier"
+	tokenString                       // 'text', E'text', $tag$text$tag$
+	tokenNumber
+	tokenParam // $1
+	tokenPunct // any other single character, including ; ( ) , .
+)
+
+// token is a lexical token of PostgreSQL SQL. Comments and whitespace are dropped.
+type token struct {
+	kind tokenKind
+	text string
+	// upper is the upper-cased text of words, used for keyword matching
+	upper string
+	// name is the identifier PostgreSQL resolves a word or quoted identifier
+	// to: words folded to lower case, quoted identifiers unquoted and unescaped
+	name string
+}
+
+func (t token) is(keyword string) bool {
+	return t.kind == tokenWord && t.upper == keyword
+}
+
+func (t token) isPunct(c string) bool {
+	return t.kind == tokenPunct && t.text == c
+}
+
+// lex splits a SQL text into tokens, following the PostgreSQL rules for
+// comments (nested block comments), quoted strings and identifiers, escape
+// strings and dollar quoting, so that keywords and semicolons inside them are
+// never mistaken for SQL
+func lex(sql string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for i < len(sql) {
+		c := sql[i]
+
+		switch {
+		case isSpace(c):
+			i++
+
+		case c == '-' && peek(sql, i+1) == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && peek(sql, i+1) == '*':
+			end, err := skipBlockComment(sql, i)
+			if err != nil {
+				return tokens, err
+			}
+			i = end
+
+		case c == '\'':
+			end, err := scanString(sql, i, false)
+			if err != nil {
+				return tokens, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sql[i:end]})
+			i = end
+
+		case (c == 'E' || c == 'e') && peek(sql, i+1) == '\'':
+			end, err := scanString(sql, i+1, true)
+			if err != nil {
+				return tokens, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sql[i:end]})
+			i = end
+
+		case c == '"':
+			end, err := scanQuotedIdent(sql, i)
+			if err != nil {
+				return tokens, err
+			}
+			name := strings.ReplaceAll(sql[i+1:end-1], `""`, `"`)
+			tokens = append(tokens, token{kind: tokenQuotedIdent, text: sql[i:end], name: name})
+			i = end
+
+		case (c == 'U' || c == 'u') && peek(sql, i+1) == '&' && peek(sql, i+2) == '"':
+			end, err := scanQuotedIdent(sql, i+2)
+			if err != nil {
+				return tokens, err
+			}
+			body := strings.ReplaceAll(sql[i+3:end-1], `""`, `"`)
+			end, escape := scanUEscape(sql, end)
+			name, err := decodeUnicodeEscapes(body, escape)
+			if err != nil {
+				return tokens, err
+			}
+			tokens = append(tokens, token{kind: tokenQuotedIdent, text: sql[i:end], name: name})
+			i = end
+
+		case c == '$' && isDigit(peek(sql, i+1)):
+			end := i + 1
+			for end < len(sql) && isDigit(sql[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: sql[i:end]})
+			i = end
+
+		case c == '$':
+			end, ok, err := scanDollarString(sql, i)
+			if err != nil {
+				return tokens, err
+			}
+			if !ok {
+				tokens = append(tokens, token{kind: tokenPunct, text: "$"})
+				i++
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sql[i:end]})
+			i = end
+
+		case isDigit(c) || (c == '.' && isDigit(peek(sql, i+1))):
+			end := i + 1
+			for end < len(sql) && (isDigit(sql[end]) || sql[end] == '.' || sql[end] == '_' ||
+				sql[end] == 'e' || sql[end] == 'E' ||
+				((sql[end] == '+' || sql[end] == '-') && (sql[end-1] == 'e' || sql[end-1] == 'E'))) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: sql[i:end]})
+			i = end
+
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(sql) && isIdentPart(sql[end]) {
+				end++
+			}
+			word := sql[i:end]
+			tokens = append(tokens, token{kind: tokenWord, text: word, upper: strings.ToUpper(word), name: strings.ToLower(word)})
+			i = end
+
+		default:
+			tokens = append(tokens, token{kind: tokenPunct, text: string(c)})
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+// skipBlockComment returns the end of a possibly nested /* */ comment starting at i
+func skipBlockComment(sql string, i int) (int, error) {
+	depth := 0
+	for i < len(sql) {
+		switch {
+		case sql[i] == '/' && peek(sql, i+1) == '*':
+			depth++
+			i += 2
+		case sql[i] == '*' && peek(sql, i+1) == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+	return i, errUnterminatedComment
+}
+
+// scanString returns the end of a quoted string starting at the quote at i.
+// A doubled quote is an escaped quote, in escape strings so is a backslash.
+func scanString(sql string, i int, backslashEscapes bool) (int, error) {
+	i++
+	for i < len(sql) {
+		switch {
+		case backslashEscapes && sql[i] == '\\':
+			i += 2
+		case sql[i] == '\'' && peek(sql, i+1) == '\'':
+			i += 2
+		case sql[i] == '\'':
+			return i + 1, nil
+		default:
+			i++
+		}
+	}
+	return i, errUnterminatedString
+}
+
+// scanQuotedIdent returns the end of a quoted identifier starting at i
+func scanQuotedIdent(sql string, i int) (int, error) {
+	i++
+	for i < len(sql) {
+		if sql[i] == '"' {
+			if peek(sql, i+1) == '"' {
+				i += 2
+				continue
+			}
+			return i + 1, nil
+		}
+		i++
+	}
+	return i, errUnterminatedIdent
+}
+
+// scanUEscape returns the end of the UESCAPE 'c' clause following a Unicode
+// identifier ending at i and its escape character, the default is a backslash
+func scanUEscape(sql string, i int) (int, byte) {
+	j := i
+	for j < len(sql) && isSpace(sql[j]) {
+		j++
+	}
+	if !strings.EqualFold(sql[j:min(j+7, len(sql))], "UESCAPE") || isIdentPart(peek(sql, j+7)) {
+		return i, '\\'
+	}
+	j += 7
+	for j < len(sql) && isSpace(sql[j]) {
+		j++
+	}
+	if peek(sql, j) != '\'' || peek(sql, j+2) != '\'' {
+		return i, '\\'
+	}
+	return j + 3, sql[j+1]
+}
+
+// decodeUnicodeEscapes replaces the escapes of a Unicode identifier: the
+// escape character followed by 4 hex digits, or by + and 6 hex digits, and a
+// doubled escape character
+func decodeUnicodeEscapes(body string, escape byte) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != escape {
+			b.WriteByte(body[i])
+			continue
+		}
+		if peek(body, i+1) == escape {
+			b.WriteByte(escape)
+			i++
+			continue
+		}
+
+		digits, start := 4, i+1
+		if peek(body, i+1) == '+' {
+			digits, start = 6, i+2
+		}
+		if start+digits > len(body) {
+			return "", errInvalidUnicode
+		}
+		code, err := strconv.ParseUint(body[start:start+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", errInvalidUnicode
+		}
+		b.WriteRune(rune(code))
+		i = start + digits - 1
+	}
+	return b.String(), nil
+}
+
+// scanDollarString returns the end of a $tag$ quoted string starting at i,
+// or false when the $ does not start one
+func scanDollarString(sql string, i int) (int, bool, error) {
+	end := i + 1
+	for end < len(sql) && sql[end] != '$' {
+		if !isIdentPart(sql[end]) || sql[end] == '$' || (end == i+1 && isDigit(sql[end])) {
+			return 0, false, nil
+		}
+		end++
+	}
+	if end >= len(sql) {
+		return 0, false, nil
+	}
+
+	tag := sql[i : end+1]
+	closing := strings.Index(sql[end+1:], tag)
+	if closing < 0 {
+		return len(sql), true, errUnterminatedDollar
+	}
+	return end + 1 + closing + len(tag), true, nil
+}
+
+func peek(sql string, i int) byte {
+	if i < len(sql) {
+		return sql[i]
+	}
+	return 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentStart reports whether c starts an identifier, bytes of multi-byte
+// UTF-8 characters are letters to PostgreSQL
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
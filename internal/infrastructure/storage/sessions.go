@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"fmt"
+
+	"google.golang.org/adk/session"
+	"google.golang.org/adk/session/database"
+)
+
+// SessionService returns an ADK session service persisting sessions, events
+// and state in the store. The schema is migrated on first use.
+func (s *Store) SessionService() (session.Service, error) {
+	dialector, err := s.dialector()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session service: %w", err)
+	}
+
+	service, err := database.NewSessionService(dialector, gormConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session service: %w", err)
+	}
+
+	if err := database.AutoMigrate(service); err != nil {
+		return nil, fmt.Errorf("failed to migrate session storage: %w", err)
+	}
+
+	return service, nil
+}
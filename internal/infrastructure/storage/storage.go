@@ -0,0 +1,133 @@
+// Package storage provides the relational store for persisted server state
+// such as agent sessions.
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+const (
+	// DriverMemory keeps everything in an in-memory SQLite database, lost on restart
+	DriverMemory = "memory"
+	// DriverSQLite stores data in a SQLite file, for single-node setups
+	DriverSQLite = "sqlite"
+	// DriverPostgres stores data in PostgreSQL, shared by all replicas
+	DriverPostgres = "postgres"
+
+	DefaultSQLitePath = "alodb.db"
+
+	sqlitePragmas = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+)
+
+// Config selects the storage backend
+type Config struct {
+	Driver string
+	// DSN is the SQLite file path or the PostgreSQL connection string
+	DSN string
+}
+
+// Store is an open storage backend
+type Store struct {
+	db     *gorm.DB
+	driver string
+}
+
+// Open connects to the configured storage backend
+func Open(cfg Config) (*Store, error) {
+	driver := strings.ToLower(strings.TrimSpace(cfg.Driver))
+	if driver == "" {
+		driver = DriverMemory
+	}
+
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverMemory:
+		dialector = sqlite.Open(":memory:")
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(cfg.DSN))
+	case DriverPostgres:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("storage DSN is required for driver %s", driver)
+		}
+		dialector = postgres.Open(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s", cfg.Driver)
+	}
+
+	db, err := gorm.Open(dialector, gormConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", driver, err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s storage: %w", driver, err)
+	}
+
+	// SQLite allows a single writer, and every connection to :memory: would
+	// open a separate empty database
+	if driver != DriverPostgres {
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	return &Store{db: db, driver: driver}, nil
+}
+
+// DB returns the gorm handle of the store
+func (s *Store) DB() *gorm.DB {
+	return s.db
+}
+
+// Driver returns the name of the storage backend
+func (s *Store) Driver() string {
+	return s.driver
+}
+
+// Close closes the underlying database connections
+func (s *Store) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// dialector returns a dialector reusing the store's connection pool, so
+// services opening their own gorm handle share it
+func (s *Store) dialector() (gorm.Dialector, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.driver == DriverPostgres {
+		return postgres.New(postgres.Config{Conn: sqlDB}), nil
+	}
+	return &sqlite.Dialector{Conn: sqlDB}, nil
+}
+
+// sqliteDSN adds the default pragmas to a SQLite file path
+func sqliteDSN(dsn string) string {
+	if dsn == "" {
+		dsn = DefaultSQLitePath
+	}
+	if strings.Contains(dsn, "_pragma=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + sqlitePragmas
+	}
+	return dsn + "?" + sqlitePragmas
+}
+
+func gormConfig() *gorm.Config {
+	return &gorm.Config{
+		Logger: gormLogger.Default.LogMode(gormLogger.Silent),
+	}
+}
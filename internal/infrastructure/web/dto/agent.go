@@ -1,27 +1,108 @@
 package dto
 
-import domainAgent "github.com/mololab/alodb/internal/domain/agent"
+import (
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	"github.com/mololab/alodb/internal/domain/database"
+)
 
 type ChatRequest struct {
-	SessionID        string `json:"session_id,omitempty"`
-	Message          string `json:"message" binding:"required"`
-	ConnectionString string `json:"connection_string" binding:"required"`
-	Model            string `json:"model,omitempty"`
+	SessionID        string        `json:"session_id,omitempty"`
+	Message          string        `json:"message" binding:"required"`
+	ConnectionString string        `json:"connection_string,omitempty"`
+	ConnectionID     string        `json:"connection_id,omitempty"`
+	Model            string        `json:"model,omitempty"`
+	Schemas          *SchemaFilter `json:"schemas,omitempty"`
 }
 
-func (r *ChatRequest) ToDomain() domainAgent.ChatRequest {
+// SchemaFilter selects the database schemas the agent reads by name pattern
+type SchemaFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func (f *SchemaFilter) toDomain() database.SchemaFilter {
+	if f == nil {
+		return database.SchemaFilter{}
+	}
+	return database.SchemaFilter{Include: f.Include, Exclude: f.Exclude}
+}
+
+func (r *ChatRequest) ToDomain(userID string) domainAgent.ChatRequest {
 	return domainAgent.ChatRequest{
+		UserID:           userID,
 		SessionID:        r.SessionID,
 		Message:          r.Message,
 		ConnectionString: r.ConnectionString,
+		ConnectionID:     r.ConnectionID,
 		Model:            r.Model,
+		SchemaFilter:     r.Schemas.toDomain(),
+	}
+}
+
+// Validate checks that exactly one of a connection string or a saved connection
+// is present and that the schema patterns are valid
+func (r *ChatRequest) Validate() string {
+	if r.ConnectionString == "" && r.ConnectionID == "" {
+		return "connection_string or connection_id is required"
+	}
+	if r.ConnectionString != "" && r.ConnectionID != "" {
+		return "connection_string and connection_id are mutually exclusive"
 	}
+	if err := r.Schemas.toDomain().Validate(); err != nil {
+		return err.Error()
+	}
+	return ""
 }
 
 type Query struct {
-	Title       string `json:"title"`
-	Query       string `json:"query"`
-	Description string `json:"description"`
+	Title       string     `json:"title"`
+	Query       string     `json:"query"`
+	Description string     `json:"description"`
+	IsReadOnly  bool       `json:"is_read_only"`
+	Risk        *QueryRisk `json:"risk,omitempty"`
+}
+
+// QueryRisk is the static analysis of a generated query
+type QueryRisk struct {
+	Kind       string     `json:"kind"`
+	Level      string     `json:"level"`
+	Statements int        `json:"statements"`
+	Flags      []RiskFlag `json:"flags,omitempty"`
+}
+
+// RiskFlag is a dangerous pattern found in a query
+type RiskFlag struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func queriesFromDomain(queries []domainAgent.Query) []Query {
+	var result []Query
+	for _, q := range queries {
+		query := Query{
+			Title:       q.Title,
+			Query:       q.Query,
+			Description: q.Description,
+			IsReadOnly:  q.IsReadOnly,
+		}
+		if q.Risk != nil {
+			query.Risk = &QueryRisk{
+				Kind:       string(q.Risk.Kind),
+				Level:      string(q.Risk.Level),
+				Statements: q.Risk.Statements,
+			}
+			for _, f := range q.Risk.Flags {
+				query.Risk.Flags = append(query.Risk.Flags, RiskFlag{
+					Code:     f.Code,
+					Severity: string(f.Severity),
+					Message:  f.Message,
+				})
+			}
+		}
+		result = append(result, query)
+	}
+	return result
 }
 
 type ChatResponse struct {
@@ -29,24 +110,28 @@ type ChatResponse struct {
 	SessionID string  `json:"session_id,omitempty"`
 	Message   string  `json:"message,omitempty"`
 	Queries   []Query `json:"queries,omitempty"`
+	Usage     *Usage  `json:"usage,omitempty"`
 	Error     string  `json:"error,omitempty"`
 }
 
-func ChatResponseFromDomain(resp *domainAgent.ChatResponse) ChatResponse {
-	var queries []Query
-	for _, q := range resp.Queries {
-		queries = append(queries, Query{
-			Title:       q.Title,
-			Query:       q.Query,
-			Description: q.Description,
-		})
-	}
+// Usage is the number of LLM tokens a chat used
+type Usage struct {
+	PromptTokens int64 `json:"prompt_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
 
+func ChatResponseFromDomain(resp *domainAgent.ChatResponse) ChatResponse {
 	return ChatResponse{
 		Success:   true,
 		SessionID: resp.SessionID,
 		Message:   resp.Message,
-		Queries:   queries,
+		Queries:   queriesFromDomain(resp.Queries),
+		Usage: &Usage{
+			PromptTokens: resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		},
 	}
 }
 
@@ -57,6 +142,25 @@ func ErrorResponse(err string) ChatResponse {
 	}
 }
 
+// StreamEvent is the data of a tool_call, tool_result or text event of a streamed chat
+type StreamEvent struct {
+	Tool    string         `json:"tool,omitempty"`
+	Args    map[string]any `json:"args,omitempty"`
+	Status  string         `json:"status,omitempty"`
+	Message string         `json:"message,omitempty"`
+	Text    string         `json:"text,omitempty"`
+}
+
+func StreamEventFromDomain(event domainAgent.StreamEvent) StreamEvent {
+	return StreamEvent{
+		Tool:    event.Tool,
+		Args:    event.Args,
+		Status:  event.Status,
+		Message: event.Message,
+		Text:    event.Text,
+	}
+}
+
 type Model struct {
 	Slug     string `json:"slug"`
 	Name     string `json:"name"`
@@ -0,0 +1,113 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+)
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	UserID string   `json:"user_id,omitempty"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// Validate checks the name and that every scope is known
+func (r *CreateAPIKeyRequest) Validate() string {
+	if strings.TrimSpace(r.Name) == "" {
+		return "name is required"
+	}
+	if len(r.Scopes) == 0 {
+		return "at least one scope is required"
+	}
+	for _, scope := range r.Scopes {
+		if !domainAuth.IsValidScope(domainAuth.Scope(scope)) {
+			return "scopes must be chat, execute or admin"
+		}
+	}
+	return ""
+}
+
+func (r *CreateAPIKeyRequest) ToDomain() domainAuth.CreateAPIKeyRequest {
+	scopes := make([]domainAuth.Scope, len(r.Scopes))
+	for i, scope := range r.Scopes {
+		scopes[i] = domainAuth.Scope(scope)
+	}
+
+	return domainAuth.CreateAPIKeyRequest{
+		Name:   strings.TrimSpace(r.Name),
+		UserID: strings.TrimSpace(r.UserID),
+		Scopes: scopes,
+	}
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	UserID     string     `json:"user_id"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type APIKeyResponse struct {
+	Success bool    `json:"success"`
+	APIKey  *APIKey `json:"api_key,omitempty"`
+	// Key is the secret, only returned when the key is created
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type APIKeyListResponse struct {
+	Success bool     `json:"success"`
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+func APIKeyCreatedResponse(key string, apiKey *domainAuth.APIKey) APIKeyResponse {
+	k := apiKeyFromDomain(apiKey)
+	return APIKeyResponse{
+		Success: true,
+		APIKey:  &k,
+		Key:     key,
+	}
+}
+
+func APIKeyListResponseFromDomain(apiKeys []domainAuth.APIKey) APIKeyListResponse {
+	keys := make([]APIKey, len(apiKeys))
+	for i := range apiKeys {
+		keys[i] = apiKeyFromDomain(&apiKeys[i])
+	}
+
+	return APIKeyListResponse{
+		Success: true,
+		APIKeys: keys,
+	}
+}
+
+func APIKeyErrorResponse(err string) APIKeyResponse {
+	return APIKeyResponse{
+		Success: false,
+		Error:   err,
+	}
+}
+
+func apiKeyFromDomain(apiKey *domainAuth.APIKey) APIKey {
+	scopes := make([]string, len(apiKey.Scopes))
+	for i, scope := range apiKey.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	return APIKey{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		UserID:     apiKey.UserID,
+		Scopes:     scopes,
+		CreatedAt:  apiKey.CreatedAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		RevokedAt:  apiKey.RevokedAt,
+	}
+}
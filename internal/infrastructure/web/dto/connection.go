@@ -0,0 +1,107 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	domainConnection "github.com/mololab/alodb/internal/domain/connection"
+)
+
+type CreateConnectionRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Host     string `json:"host" binding:"required"`
+	Port     int    `json:"port,omitempty"`
+	Database string `json:"database" binding:"required"`
+	User     string `json:"user" binding:"required"`
+	Password string `json:"password,omitempty"`
+	SSLMode  string `json:"sslmode,omitempty"`
+}
+
+func (r *CreateConnectionRequest) ToDomain(userID string) domainConnection.CreateRequest {
+	return domainConnection.CreateRequest{
+		UserID:   userID,
+		Name:     strings.TrimSpace(r.Name),
+		Host:     strings.TrimSpace(r.Host),
+		Port:     r.Port,
+		Database: r.Database,
+		User:     r.User,
+		Password: r.Password,
+		SSLMode:  r.SSLMode,
+	}
+}
+
+// Validate checks the port range and SSL mode
+func (r *CreateConnectionRequest) Validate() string {
+	if strings.TrimSpace(r.Name) == "" {
+		return "name is required"
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		return "port must be between 1 and 65535"
+	}
+	if r.SSLMode != "" && !domainConnection.IsValidSSLMode(r.SSLMode) {
+		return "sslmode must be one of disable, require, verify-ca, verify-full"
+	}
+	return ""
+}
+
+type Connection struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	Database  string    `json:"database"`
+	User      string    `json:"user"`
+	SSLMode   string    `json:"sslmode"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ConnectionResponse struct {
+	Success    bool        `json:"success"`
+	Connection *Connection `json:"connection,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type ConnectionListResponse struct {
+	Success     bool         `json:"success"`
+	Connections []Connection `json:"connections"`
+}
+
+func ConnectionResponseFromDomain(profile *domainConnection.Profile) ConnectionResponse {
+	conn := connectionFromDomain(profile)
+	return ConnectionResponse{
+		Success:    true,
+		Connection: &conn,
+	}
+}
+
+func ConnectionListResponseFromDomain(profiles []domainConnection.Profile) ConnectionListResponse {
+	connections := make([]Connection, len(profiles))
+	for i := range profiles {
+		connections[i] = connectionFromDomain(&profiles[i])
+	}
+
+	return ConnectionListResponse{
+		Success:     true,
+		Connections: connections,
+	}
+}
+
+func ConnectionErrorResponse(err string) ConnectionResponse {
+	return ConnectionResponse{
+		Success: false,
+		Error:   err,
+	}
+}
+
+func connectionFromDomain(profile *domainConnection.Profile) Connection {
+	return Connection{
+		ID:        profile.ID,
+		Name:      profile.Name,
+		Host:      profile.Host,
+		Port:      profile.Port,
+		Database:  profile.Database,
+		User:      profile.User,
+		SSLMode:   profile.SSLMode,
+		CreatedAt: profile.CreatedAt,
+	}
+}
@@ -0,0 +1,80 @@
+package dto
+
+import domainQuery "github.com/mololab/alodb/internal/domain/query"
+
+type ExecuteRequest struct {
+	ConnectionString string `json:"connection_string,omitempty"`
+	ConnectionID     string `json:"connection_id,omitempty"`
+	Query            string `json:"query,omitempty"`
+	Cursor           string `json:"cursor,omitempty"`
+	PageSize         int    `json:"page_size,omitempty"`
+}
+
+func (r *ExecuteRequest) ToDomain(userID string) domainQuery.ExecuteRequest {
+	return domainQuery.ExecuteRequest{
+		UserID:           userID,
+		ConnectionString: r.ConnectionString,
+		ConnectionID:     r.ConnectionID,
+		Query:            r.Query,
+		Cursor:           r.Cursor,
+		PageSize:         r.PageSize,
+	}
+}
+
+// Validate checks that either a cursor or a connection and query are present
+func (r *ExecuteRequest) Validate() string {
+	if r.Cursor != "" {
+		return ""
+	}
+	if r.ConnectionString == "" && r.ConnectionID == "" {
+		return "connection_string or connection_id is required"
+	}
+	if r.ConnectionString != "" && r.ConnectionID != "" {
+		return "connection_string and connection_id are mutually exclusive"
+	}
+	if r.Query == "" {
+		return "query is required"
+	}
+	return ""
+}
+
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type ExecuteResponse struct {
+	Success    bool     `json:"success"`
+	Columns    []Column `json:"columns,omitempty"`
+	Rows       [][]any  `json:"rows,omitempty"`
+	RowCount   int      `json:"row_count"`
+	HasMore    bool     `json:"has_more"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+func ExecuteResponseFromDomain(page *domainQuery.ResultPage) ExecuteResponse {
+	columns := make([]Column, len(page.Columns))
+	for i, c := range page.Columns {
+		columns[i] = Column{
+			Name: c.Name,
+			Type: c.Type,
+		}
+	}
+
+	return ExecuteResponse{
+		Success:    true,
+		Columns:    columns,
+		Rows:       page.Rows,
+		RowCount:   page.RowCount,
+		HasMore:    page.HasMore,
+		NextCursor: page.NextCursor,
+	}
+}
+
+func ExecuteErrorResponse(err string) ExecuteResponse {
+	return ExecuteResponse{
+		Success: false,
+		Error:   err,
+	}
+}
@@ -0,0 +1,109 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	domainSession "github.com/mololab/alodb/internal/domain/session"
+)
+
+const maxSessionTitleLength = 200
+
+type RenameSessionRequest struct {
+	Title string `json:"title" binding:"required"`
+}
+
+// Validate checks that the title is not blank and not too long
+func (r *RenameSessionRequest) Validate() string {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" {
+		return "title is required"
+	}
+	if len([]rune(r.Title)) > maxSessionTitleLength {
+		return "title is too long"
+	}
+	return ""
+}
+
+type SessionSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SessionMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Queries   []Query   `json:"queries,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SessionListResponse struct {
+	Success  bool             `json:"success"`
+	Sessions []SessionSummary `json:"sessions"`
+}
+
+type SessionResponse struct {
+	Success  bool             `json:"success"`
+	Session  *SessionSummary  `json:"session,omitempty"`
+	Messages []SessionMessage `json:"messages,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+func SessionListResponseFromDomain(summaries []domainSession.Summary) SessionListResponse {
+	sessions := make([]SessionSummary, len(summaries))
+	for i, s := range summaries {
+		sessions[i] = sessionSummaryFromDomain(s)
+	}
+
+	return SessionListResponse{
+		Success:  true,
+		Sessions: sessions,
+	}
+}
+
+func SessionResponseFromDomain(s *domainSession.Session) SessionResponse {
+	summary := sessionSummaryFromDomain(s.Summary)
+	messages := make([]SessionMessage, len(s.Messages))
+	for i, m := range s.Messages {
+		messages[i] = SessionMessage{
+			Role:      m.Role,
+			Content:   m.Content,
+			Queries:   queriesFromDomain(m.Queries),
+			CreatedAt: m.CreatedAt,
+		}
+	}
+
+	return SessionResponse{
+		Success:  true,
+		Session:  &summary,
+		Messages: messages,
+	}
+}
+
+func SessionSummaryResponseFromDomain(s *domainSession.Summary) SessionResponse {
+	summary := sessionSummaryFromDomain(*s)
+	return SessionResponse{
+		Success: true,
+		Session: &summary,
+	}
+}
+
+func SessionErrorResponse(err string) SessionResponse {
+	return SessionResponse{
+		Success: false,
+		Error:   err,
+	}
+}
+
+func sessionSummaryFromDomain(s domainSession.Summary) SessionSummary {
+	return SessionSummary{
+		ID:        s.ID,
+		Title:     s.Title,
+		Model:     s.Model,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
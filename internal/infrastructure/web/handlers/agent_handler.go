@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	agentApp "github.com/mololab/alodb/internal/application/agent"
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
 	"github.com/mololab/alodb/internal/infrastructure/web/dto"
+	"github.com/mololab/alodb/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,20 +27,77 @@ func NewAgentHandler(agentService *agentApp.Service) *AgentHandler {
 func (h *AgentHandler) Chat(c *gin.Context) {
 	var req dto.ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse("invalid request: "+err.Error()))
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse("invalid request: "+logger.RedactError(err)))
+		return
+	}
+	if msg := req.Validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse("invalid request: "+msg))
 		return
 	}
 
-	resp, err := h.agentService.Chat(c.Request.Context(), req.ToDomain())
+	resp, err := h.agentService.Chat(c.Request.Context(), req.ToDomain(userID(c)))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse("failed to process message: "+err.Error()))
+		c.JSON(chatErrorStatus(err), dto.ErrorResponse("failed to process message: "+logger.RedactError(err)))
 		return
 	}
 
 	c.JSON(http.StatusOK, dto.ChatResponseFromDomain(resp))
 }
 
+// ChatStream runs a chat like Chat, streaming progress as Server-Sent Events.
+// The stream ends with a done event carrying the chat response, or an error event.
+func (h *AgentHandler) ChatStream(c *gin.Context) {
+	var req dto.ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse("invalid request: "+logger.RedactError(err)))
+		return
+	}
+	if msg := req.Validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse("invalid request: "+msg))
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	send := func(name string, data any) {
+		c.SSEvent(name, data)
+		c.Writer.Flush()
+	}
+
+	resp, err := h.agentService.ChatStream(c.Request.Context(), req.ToDomain(userID(c)), func(event domainAgent.StreamEvent) {
+		send(string(event.Type), dto.StreamEventFromDomain(event))
+	})
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			return // client went away
+		}
+		send("error", dto.ErrorResponse("failed to process message: "+logger.RedactError(err)))
+		return
+	}
+
+	send("done", dto.ChatResponseFromDomain(resp))
+}
+
 func (h *AgentHandler) GetModels(c *gin.Context) {
 	models := h.agentService.GetAvailableModels()
 	c.JSON(http.StatusOK, dto.ModelsResponseFromDomain(models))
 }
+
+// chatErrorStatus maps chat errors to HTTP status codes
+func chatErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, infraConnection.ErrTargetNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, infraConnection.ErrInvalidDSN),
+		errors.Is(err, infraConnection.ErrHostNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, infraConnection.ErrProfileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, secrets.ErrNotConfigured):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
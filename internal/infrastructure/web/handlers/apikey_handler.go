@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	authApp "github.com/mololab/alodb/internal/application/auth"
+	infraAuth "github.com/mololab/alodb/internal/infrastructure/auth"
+	"github.com/mololab/alodb/internal/infrastructure/web/dto"
+	"github.com/mololab/alodb/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	authService *authApp.Service
+}
+
+func NewAPIKeyHandler(authService *authApp.Service) *APIKeyHandler {
+	return &APIKeyHandler{
+		authService: authService,
+	}
+}
+
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.APIKeyErrorResponse("invalid request: "+logger.RedactError(err)))
+		return
+	}
+	if msg := req.Validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, dto.APIKeyErrorResponse("invalid request: "+msg))
+		return
+	}
+
+	key, apiKey, err := h.authService.CreateAPIKey(c.Request.Context(), req.ToDomain())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.APIKeyErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.APIKeyCreatedResponse(key, apiKey))
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	apiKeys, err := h.authService.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.APIKeyErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.APIKeyListResponseFromDomain(apiKeys))
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	if err := h.authService.RevokeAPIKey(c.Request.Context(), c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, infraAuth.ErrAPIKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, dto.APIKeyErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
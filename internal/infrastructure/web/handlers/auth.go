@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userID returns the authenticated user of the request, empty for the default user
+func userID(c *gin.Context) string {
+	if principal, ok := domainAuth.PrincipalFromContext(c.Request.Context()); ok {
+		return principal.UserID
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	connectionApp "github.com/mololab/alodb/internal/application/connection"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
+	"github.com/mololab/alodb/internal/infrastructure/web/dto"
+	"github.com/mololab/alodb/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConnectionHandler struct {
+	connectionService *connectionApp.Service
+}
+
+func NewConnectionHandler(connectionService *connectionApp.Service) *ConnectionHandler {
+	return &ConnectionHandler{
+		connectionService: connectionService,
+	}
+}
+
+func (h *ConnectionHandler) Create(c *gin.Context) {
+	var req dto.CreateConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ConnectionErrorResponse("invalid request: "+logger.RedactError(err)))
+		return
+	}
+	if msg := req.Validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, dto.ConnectionErrorResponse("invalid request: "+msg))
+		return
+	}
+
+	profile, err := h.connectionService.Create(c.Request.Context(), req.ToDomain(userID(c)))
+	if err != nil {
+		c.JSON(connectionErrorStatus(err), dto.ConnectionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.ConnectionResponseFromDomain(profile))
+}
+
+func (h *ConnectionHandler) List(c *gin.Context) {
+	profiles, err := h.connectionService.List(c.Request.Context(), userID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ConnectionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ConnectionListResponseFromDomain(profiles))
+}
+
+func (h *ConnectionHandler) Get(c *gin.Context) {
+	profile, err := h.connectionService.Get(c.Request.Context(), userID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(connectionErrorStatus(err), dto.ConnectionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ConnectionResponseFromDomain(profile))
+}
+
+func (h *ConnectionHandler) Delete(c *gin.Context) {
+	if err := h.connectionService.Delete(c.Request.Context(), userID(c), c.Param("id")); err != nil {
+		c.JSON(connectionErrorStatus(err), dto.ConnectionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// connectionErrorStatus maps connection profile errors to HTTP status codes
+func connectionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, infraConnection.ErrTargetNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, infraConnection.ErrConnectionTestFailed),
+		errors.Is(err, infraConnection.ErrInvalidDSN),
+		errors.Is(err, infraConnection.ErrHostNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, infraConnection.ErrProfileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, infraConnection.ErrDuplicateName):
+		return http.StatusConflict
+	case errors.Is(err, secrets.ErrNotConfigured):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	queryApp "github.com/mololab/alodb/internal/application/query"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	infraQuery "github.com/mololab/alodb/internal/infrastructure/query"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
+	"github.com/mololab/alodb/internal/infrastructure/sqlguard"
+	"github.com/mololab/alodb/internal/infrastructure/web/dto"
+	"github.com/mololab/alodb/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueryHandler struct {
+	queryService *queryApp.Service
+}
+
+func NewQueryHandler(queryService *queryApp.Service) *QueryHandler {
+	return &QueryHandler{
+		queryService: queryService,
+	}
+}
+
+func (h *QueryHandler) Execute(c *gin.Context) {
+	var req dto.ExecuteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ExecuteErrorResponse("invalid request: "+logger.RedactError(err)))
+		return
+	}
+	if msg := req.Validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, dto.ExecuteErrorResponse("invalid request: "+msg))
+		return
+	}
+
+	page, err := h.queryService.Execute(c.Request.Context(), req.ToDomain(userID(c)))
+	if err != nil {
+		c.JSON(executeErrorStatus(err), dto.ExecuteErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ExecuteResponseFromDomain(page))
+}
+
+// executeErrorStatus maps executor errors to HTTP status codes
+func executeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, infraConnection.ErrTargetNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, sqlguard.ErrEmptyQuery),
+		errors.Is(err, infraConnection.ErrInvalidDSN),
+		errors.Is(err, infraConnection.ErrHostNotFound),
+		errors.Is(err, sqlguard.ErrInvalidSyntax),
+		errors.Is(err, sqlguard.ErrMultipleStatements),
+		errors.Is(err, sqlguard.ErrNotReadOnly),
+		errors.Is(err, infraQuery.ErrQueryFailed):
+		return http.StatusBadRequest
+	case errors.Is(err, infraQuery.ErrCursorNotFound),
+		errors.Is(err, infraConnection.ErrProfileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, infraQuery.ErrTooManyUserCursors):
+		return http.StatusTooManyRequests
+	case errors.Is(err, infraQuery.ErrTooManyCursors),
+		errors.Is(err, secrets.ErrNotConfigured):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	agentApp "github.com/mololab/alodb/internal/application/agent"
+	infraAgent "github.com/mololab/alodb/internal/infrastructure/agent"
+	"github.com/mololab/alodb/internal/infrastructure/web/dto"
+	"github.com/mololab/alodb/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	agentService *agentApp.Service
+}
+
+func NewSessionHandler(agentService *agentApp.Service) *SessionHandler {
+	return &SessionHandler{
+		agentService: agentService,
+	}
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	sessions, err := h.agentService.ListSessions(c.Request.Context(), userID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.SessionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SessionListResponseFromDomain(sessions))
+}
+
+func (h *SessionHandler) Get(c *gin.Context) {
+	session, err := h.agentService.GetSession(c.Request.Context(), userID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), dto.SessionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SessionResponseFromDomain(session))
+}
+
+func (h *SessionHandler) Rename(c *gin.Context) {
+	var req dto.RenameSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.SessionErrorResponse("invalid request: "+logger.RedactError(err)))
+		return
+	}
+	if msg := req.Validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, dto.SessionErrorResponse("invalid request: "+msg))
+		return
+	}
+
+	summary, err := h.agentService.RenameSession(c.Request.Context(), userID(c), c.Param("id"), req.Title)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), dto.SessionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SessionSummaryResponseFromDomain(summary))
+}
+
+func (h *SessionHandler) Delete(c *gin.Context) {
+	if err := h.agentService.DeleteSession(c.Request.Context(), userID(c), c.Param("id")); err != nil {
+		c.JSON(sessionErrorStatus(err), dto.SessionErrorResponse(logger.RedactError(err)))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// sessionErrorStatus maps session errors to HTTP status codes
+func sessionErrorStatus(err error) int {
+	if errors.Is(err, infraAgent.ErrSessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	agentApp "github.com/mololab/alodb/internal/application/agent"
+	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	"github.com/mololab/alodb/internal/infrastructure/web/dto"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// failingSessions is a session store whose database is unreachable
+type failingSessions struct {
+	session.Service
+}
+
+func (failingSessions) List(context.Context, *session.ListRequest) (*session.ListResponse, error) {
+	return nil, errors.New("dial postgres://alodb:s3cret@db:5432/alodb: connection refused")
+}
+
+// newSessionRouter serves the session routes, authenticating the user named
+// by the X-User header like the auth middleware would
+func newSessionRouter(sessions session.Service) *gin.Engine {
+	handler := NewSessionHandler(agentApp.NewService(domainAgent.AgentConfig{}, sessions, nil, nil, nil))
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			principal := &domainAuth.Principal{UserID: user, Scopes: []domainAuth.Scope{domainAuth.ScopeChat}}
+			c.Request = c.Request.WithContext(domainAuth.WithPrincipal(c.Request.Context(), principal))
+		}
+	})
+	router.GET("/sessions", handler.List)
+	router.GET("/sessions/:id", handler.Get)
+	router.PATCH("/sessions/:id", handler.Rename)
+	router.DELETE("/sessions/:id", handler.Delete)
+	return router
+}
+
+// seedSession stores a session the way a chat does
+func seedSession(t *testing.T, sessions session.Service, userID, sessionID, title string, messages ...*genai.Content) {
+	t.Helper()
+	ctx := context.Background()
+
+	resp, err := sessions.Create(ctx, &session.CreateRequest{
+		AppName:   "alodb_agent",
+		UserID:    userID,
+		SessionID: sessionID,
+		State:     map[string]any{"session_title": title, "session_model": "fake"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range messages {
+		event := session.NewEvent("invocation")
+		event.Author = "alodb_agent"
+		if content.Role == genai.RoleUser {
+			event.Author = "user"
+		}
+		event.Content = content
+		if err := sessions.AppendEvent(ctx, resp.Session, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func serve(router *gin.Engine, method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("invalid body %s: %v", rec.Body, err)
+	}
+	return v
+}
+
+func TestSessionHandlerList(t *testing.T) {
+	sessions := session.InMemoryService()
+	seedSession(t, sessions, "alice", "s1", "Users")
+	seedSession(t, sessions, "bob", "s2", "Orders")
+	seedSession(t, sessions, domainAgent.DefaultUserID, "s3", "Anonymous")
+	router := newSessionRouter(sessions)
+
+	tests := []struct {
+		name string
+		user string
+		ids  []string
+	}{
+		{"own sessions", "alice", []string{"s1"}},
+		{"other user", "bob", []string{"s2"}},
+		{"new user", "carol", []string{}},
+		// without authentication every client is the default user
+		{"unauthenticated", "", []string{"s3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodGet, "/sessions", tt.user, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			resp := decode[dto.SessionListResponse](t, rec)
+			ids := []string{}
+			for _, s := range resp.Sessions {
+				ids = append(ids, s.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.ids, ",") {
+				t.Errorf("sessions = %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestSessionHandlerListError(t *testing.T) {
+	rec := serve(newSessionRouter(failingSessions{session.InMemoryService()}), http.MethodGet, "/sessions", "alice", "")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if resp := decode[dto.SessionResponse](t, rec); resp.Success || resp.Error == "" || strings.Contains(resp.Error, "s3cret") {
+		t.Errorf("error = %q, want it without the password", resp.Error)
+	}
+}
+
+func TestSessionHandlerGet(t *testing.T) {
+	sessions := session.InMemoryService()
+	seedSession(t, sessions, "alice", "s1", "Users",
+		genai.NewContentFromText("How many users do we have?", genai.RoleUser),
+		genai.NewContentFromText(`{"message": "There are 42 users.", "queries": [{"title": "Count", "query": "SELECT count(*) FROM users"}]}`, genai.RoleModel),
+	)
+	router := newSessionRouter(sessions)
+
+	rec := serve(router, http.MethodGet, "/sessions/s1", "alice", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	resp := decode[dto.SessionResponse](t, rec)
+	if resp.Session == nil || resp.Session.Title != "Users" || resp.Session.Model != "fake" {
+		t.Errorf("session = %+v", resp.Session)
+	}
+	if len(resp.Messages) != 2 || resp.Messages[0].Content != "How many users do we have?" ||
+		resp.Messages[1].Content != "There are 42 users." || len(resp.Messages[1].Queries) != 1 {
+		t.Errorf("messages = %+v", resp.Messages)
+	}
+
+	for _, tt := range []struct{ name, user, path string }{
+		{"unknown session", "alice", "/sessions/missing"},
+		{"other user's session", "bob", "/sessions/s1"},
+		{"unauthenticated", "", "/sessions/s1"},
+	} {
+		if rec := serve(router, http.MethodGet, tt.path, tt.user, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestSessionHandlerRename(t *testing.T) {
+	sessions := session.InMemoryService()
+	seedSession(t, sessions, "alice", "s1", "Users")
+	router := newSessionRouter(sessions)
+
+	tests := []struct {
+		name   string
+		user   string
+		path   string
+		body   string
+		status int
+		title  string
+		error  string
+	}{
+		{"rename", "alice", "/sessions/s1", `{"title": "  User count "}`, http.StatusOK, "User count", ""},
+		{"missing title", "alice", "/sessions/s1", `{}`, http.StatusBadRequest, "", "invalid request: "},
+		{"blank title", "alice", "/sessions/s1", `{"title": "   "}`, http.StatusBadRequest, "", "invalid request: title is required"},
+		{"long title", "alice", "/sessions/s1", `{"title": "` + strings.Repeat("a", 201) + `"}`, http.StatusBadRequest, "", "invalid request: title is too long"},
+		{"malformed body", "alice", "/sessions/s1", `{"title":`, http.StatusBadRequest, "", "invalid request: "},
+		{"unknown session", "alice", "/sessions/missing", `{"title": "x"}`, http.StatusNotFound, "", "session not found"},
+		{"other user's session", "bob", "/sessions/s1", `{"title": "x"}`, http.StatusNotFound, "", "session not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodPatch, tt.path, tt.user, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			resp := decode[dto.SessionResponse](t, rec)
+			if tt.error != "" {
+				if !strings.HasPrefix(resp.Error, tt.error) {
+					t.Errorf("error = %q, want %q", resp.Error, tt.error)
+				}
+				return
+			}
+			if resp.Session == nil || resp.Session.Title != tt.title {
+				t.Errorf("session = %+v, want title %q", resp.Session, tt.title)
+			}
+		})
+	}
+
+	resp := decode[dto.SessionResponse](t, serve(router, http.MethodGet, "/sessions/s1", "alice", ""))
+	if resp.Session == nil || resp.Session.Title != "User count" {
+		t.Errorf("stored session = %+v, want the new title", resp.Session)
+	}
+}
+
+func TestSessionHandlerDelete(t *testing.T) {
+	sessions := session.InMemoryService()
+	seedSession(t, sessions, "alice", "s1", "Users")
+	router := newSessionRouter(sessions)
+
+	steps := []struct {
+		name   string
+		user   string
+		status int
+	}{
+		{"other user", "bob", http.StatusNotFound},
+		{"owner", "alice", http.StatusNoContent},
+		{"already deleted", "alice", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if rec := serve(router, http.MethodDelete, "/sessions/s1", step.user, ""); rec.Code != step.status {
+			t.Errorf("%s: status = %d, want %d", step.name, rec.Code, step.status)
+		}
+	}
+
+	if rec := serve(router, http.MethodGet, "/sessions/s1", "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("after delete: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
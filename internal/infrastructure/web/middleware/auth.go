@@ -0,0 +1,98 @@
+// Package middleware holds gin middleware of the HTTP API.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	infraAuth "github.com/mololab/alodb/internal/infrastructure/auth"
+	"github.com/mololab/alodb/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticator resolves a credential presented by a client to a principal
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*domainAuth.Principal, error)
+}
+
+// Authenticate rejects requests without a valid credential, an API key or a
+// JWT read from the Authorization bearer token or the X-API-Key header, and
+// stores the principal in the request context
+func Authenticate(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential := credentialFromRequest(c.Request)
+		if credential == "" {
+			abortUnauthorized(c, "missing credentials")
+			return
+		}
+
+		principal, err := authenticator.Authenticate(c.Request.Context(), credential)
+		if err != nil {
+			if !errors.Is(err, infraAuth.ErrInvalidAPIKey) && !errors.Is(err, infraAuth.ErrInvalidToken) {
+				logger.Error().Err(err).Msg("authentication failed")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, errorBody("authentication failed"))
+				return
+			}
+			logger.Debug().Err(err).Msg("request rejected")
+			abortUnauthorized(c, "invalid credentials")
+			return
+		}
+
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// Anonymous grants every request full access as the default user, used when
+// authentication is disabled
+func Anonymous() gin.HandlerFunc {
+	principal := &domainAuth.Principal{Scopes: []domainAuth.Scope{domainAuth.ScopeAdmin}}
+	return func(c *gin.Context) {
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose principal lacks the scope
+func RequireScope(scope domainAuth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := domainAuth.PrincipalFromContext(c.Request.Context())
+		if !ok {
+			abortUnauthorized(c, "authentication required")
+			return
+		}
+		if !principal.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, errorBody("credentials lack the "+string(scope)+" scope"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// credentialFromRequest returns the bearer token, falling back to X-API-Key
+func credentialFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+func setPrincipal(c *gin.Context, principal *domainAuth.Principal) {
+	c.Request = c.Request.WithContext(domainAuth.WithPrincipal(c.Request.Context(), principal))
+}
+
+func abortUnauthorized(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", `Bearer realm="alodb"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(msg))
+}
+
+func errorBody(msg string) gin.H {
+	return gin.H{"success": false, "error": msg}
+}
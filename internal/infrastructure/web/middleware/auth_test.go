@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	infraAuth "github.com/mololab/alodb/internal/infrastructure/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// stubAuthenticator accepts the credentials it was given principals for
+type stubAuthenticator map[string]*domainAuth.Principal
+
+func (a stubAuthenticator) Authenticate(_ context.Context, credential string) (*domainAuth.Principal, error) {
+	if credential == "broken" {
+		return nil, errors.New("database is down")
+	}
+	if principal, ok := a[credential]; ok {
+		return principal, nil
+	}
+	return nil, infraAuth.ErrInvalidAPIKey
+}
+
+func newAuthRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	handlers = append(handlers, func(c *gin.Context) {
+		principal, _ := domainAuth.PrincipalFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"user_id": principal.UserID})
+	})
+	router.GET("/", handlers...)
+	return router
+}
+
+func TestAuthenticate(t *testing.T) {
+	router := newAuthRouter(Authenticate(stubAuthenticator{
+		"chat-key":    {UserID: "chat-user", Scopes: []domainAuth.Scope{domainAuth.ScopeChat}},
+		"execute-key": {UserID: "execute-user", Scopes: []domainAuth.Scope{domainAuth.ScopeExecute}},
+		"admin-key":   {UserID: "admin-user", Scopes: []domainAuth.Scope{domainAuth.ScopeAdmin}},
+	}), RequireScope(domainAuth.ScopeExecute))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		user    string
+		error   string
+	}{
+		{"bearer token", map[string]string{"Authorization": "Bearer execute-key"}, http.StatusOK, "execute-user", ""},
+		{"lower-case scheme", map[string]string{"Authorization": "bearer  execute-key "}, http.StatusOK, "execute-user", ""},
+		{"X-API-Key", map[string]string{"X-API-Key": "execute-key"}, http.StatusOK, "execute-user", ""},
+		{"bearer token over X-API-Key", map[string]string{"Authorization": "Bearer execute-key", "X-API-Key": "admin-key"}, http.StatusOK, "execute-user", ""},
+		{"invalid bearer token over X-API-Key", map[string]string{"Authorization": "Bearer unknown", "X-API-Key": "execute-key"}, http.StatusUnauthorized, "", "invalid credentials"},
+		{"other scheme falls back to X-API-Key", map[string]string{"Authorization": "Basic dXNlcjpwYXNz", "X-API-Key": "execute-key"}, http.StatusOK, "execute-user", ""},
+		{"other scheme alone", map[string]string{"Authorization": "Basic execute-key"}, http.StatusUnauthorized, "", "missing credentials"},
+		{"admin implies every scope", map[string]string{"X-API-Key": "admin-key"}, http.StatusOK, "admin-user", ""},
+		{"missing scope", map[string]string{"Authorization": "Bearer chat-key"}, http.StatusForbidden, "", "credentials lack the execute scope"},
+		{"no credentials", nil, http.StatusUnauthorized, "", "missing credentials"},
+		{"empty bearer token", map[string]string{"Authorization": "Bearer "}, http.StatusUnauthorized, "", "missing credentials"},
+		{"unknown key", map[string]string{"X-API-Key": "unknown"}, http.StatusUnauthorized, "", "invalid credentials"},
+		{"authenticator failure", map[string]string{"X-API-Key": "broken"}, http.StatusInternalServerError, "", "authentication failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			var body struct {
+				UserID string `json:"user_id"`
+				Error  string `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.UserID != tt.user || body.Error != tt.error {
+				t.Errorf("body = %s, want user %q, error %q", rec.Body, tt.user, tt.error)
+			}
+			if challenge := rec.Header().Get("WWW-Authenticate"); (rec.Code == http.StatusUnauthorized) != (challenge != "") {
+				t.Errorf("WWW-Authenticate = %q on status %d", challenge, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireScopeWithoutPrincipal(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newAuthRouter(RequireScope(domainAuth.ScopeChat)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newAuthRouter(Anonymous(), RequireScope(domainAuth.ScopeAdmin)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	"github.com/mololab/alodb/internal/infrastructure/ratelimit"
+	"github.com/mololab/alodb/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit takes a request from the caller's bucket and rejects callers that
+// used up their daily token quota. Callers are API keys, authenticated users
+// or, without authentication, client IPs. The caller is stored in the request
+// context so token usage can be recorded against it.
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller := callerKey(c)
+		ctx := ratelimit.WithCaller(c.Request.Context(), caller)
+		c.Request = c.Request.WithContext(ctx)
+
+		// limits protect the LLM budget, an unavailable store must not take the API down
+		decision, err := limiter.Allow(ctx, caller)
+		if err != nil {
+			logger.Error().Err(err).Msg("rate limit check failed")
+		} else if !decision.Allowed {
+			abortTooManyRequests(c, decision.RetryAfter, "rate limit exceeded")
+			return
+		}
+
+		decision, err = limiter.CheckQuota(ctx, caller)
+		if err != nil {
+			logger.Error().Err(err).Msg("token quota check failed")
+		} else if !decision.Allowed {
+			abortTooManyRequests(c, decision.RetryAfter, "daily token quota exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// callerKey identifies the caller limits are counted for
+func callerKey(c *gin.Context) string {
+	if principal, ok := domainAuth.PrincipalFromContext(c.Request.Context()); ok {
+		if principal.KeyID != "" {
+			return "key:" + principal.KeyID
+		}
+		if principal.UserID != "" {
+			return "user:" + principal.UserID
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func abortTooManyRequests(c *gin.Context, retryAfter time.Duration, msg string) {
+	seconds := max(1, int(math.Ceil(retryAfter.Seconds())))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, errorBody(msg))
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	"github.com/mololab/alodb/internal/infrastructure/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingStore is a rate limit store whose database is unavailable
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, float64, int, time.Time) (bool, time.Duration, error) {
+	return false, 0, errors.New("database is down")
+}
+
+func (failingStore) Usage(context.Context, string, string) (int64, error) {
+	return 0, errors.New("database is down")
+}
+
+func (failingStore) AddUsage(context.Context, string, string, int64) error {
+	return errors.New("database is down")
+}
+
+func newRateLimitRouter(limiter *ratelimit.Limiter, principal *domainAuth.Principal) *gin.Engine {
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		if principal != nil {
+			setPrincipal(c, principal)
+		}
+	}, RateLimit(limiter), func(c *gin.Context) {
+		caller, _ := ratelimit.CallerFromContext(c.Request.Context())
+		c.String(http.StatusOK, caller)
+	})
+	return router
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Config{RequestsPerMinute: 30, Burst: 1})
+	router := newRateLimitRouter(limiter, nil)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(); rec.Code != http.StatusOK || rec.Body.String() != "ip:192.0.2.1" {
+		t.Fatalf("first request: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec := get()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	// one request every two seconds, the wait is rounded up to whole seconds
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want %q", got, "2")
+	}
+}
+
+func TestRateLimitQuota(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Config{DailyTokens: 100})
+	principal := &domainAuth.Principal{UserID: "user-1", KeyID: "key-1"}
+	router := newRateLimitRouter(limiter, principal)
+
+	ctx := context.Background()
+	if err := limiter.RecordUsage(ctx, "key:key-1", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	// the quota resets at the next UTC midnight
+	seconds, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if err != nil || seconds < 1 || seconds > int(midnight.Sub(now).Seconds())+1 {
+		t.Errorf("Retry-After = %q, want the seconds until midnight UTC", rec.Header().Get("Retry-After"))
+	}
+
+	// the quota is counted per caller
+	rec = httptest.NewRecorder()
+	newRateLimitRouter(limiter, &domainAuth.Principal{UserID: "user-1"}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "user:user-1" {
+		t.Errorf("other caller: status = %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRateLimitStoreUnavailable(t *testing.T) {
+	limiter := ratelimit.NewLimiter(failingStore{}, ratelimit.Config{RequestsPerMinute: 1, DailyTokens: 1})
+
+	rec := httptest.NewRecorder()
+	newRateLimitRouter(limiter, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -1,18 +1,37 @@
 package web
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	agentApp "github.com/mololab/alodb/internal/application/agent"
+	authApp "github.com/mololab/alodb/internal/application/auth"
+	connectionApp "github.com/mololab/alodb/internal/application/connection"
+	queryApp "github.com/mololab/alodb/internal/application/query"
 	domainAgent "github.com/mololab/alodb/internal/domain/agent"
+	domainAuth "github.com/mololab/alodb/internal/domain/auth"
+	infraAuth "github.com/mololab/alodb/internal/infrastructure/auth"
 	"github.com/mololab/alodb/internal/infrastructure/config"
+	infraConnection "github.com/mololab/alodb/internal/infrastructure/connection"
+	infraQuery "github.com/mololab/alodb/internal/infrastructure/query"
+	"github.com/mololab/alodb/internal/infrastructure/ratelimit"
+	"github.com/mololab/alodb/internal/infrastructure/secrets"
+	"github.com/mololab/alodb/internal/infrastructure/storage"
 	"github.com/mololab/alodb/internal/infrastructure/web/handlers"
+	"github.com/mololab/alodb/internal/infrastructure/web/middleware"
+	"github.com/mololab/alodb/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	router       *gin.Engine
-	config       *config.Config
-	agentService *agentApp.Service
+	router            *gin.Engine
+	config            *config.Config
+	agentService      *agentApp.Service
+	queryService      *queryApp.Service
+	connectionService *connectionApp.Service
+	store             *storage.Store
 }
 
 func CORSMiddleware() gin.HandlerFunc {
@@ -31,39 +50,281 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-func NewServer(cfg *config.Config) *Server {
+func NewServer(cfg *config.Config) (*Server, error) {
+	store, err := storage.Open(storage.Config{
+		Driver: cfg.Storage.Driver,
+		DSN:    cfg.Storage.DSN,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessionService, err := store.SessionService()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	logger.Info().Str("driver", store.Driver()).Msg("storage opened")
+
+	cipher, err := newSecretsCipher(cfg.Secrets, store.Driver())
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	connectionRepository, err := infraConnection.NewRepository(store.DB(), cipher)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	policy, err := newConnectionPolicy(cfg.Connections)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	connectionService := connectionApp.NewService(connectionRepository, policy)
+
+	authService, err := newAuthService(cfg.Auth, store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	limiter, err := newLimiter(cfg.RateLimit, store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("invalid SERVER_TRUSTED_PROXIES: %w", err)
+	}
 
 	router.Use(CORSMiddleware())
 
 	agentService := agentApp.NewService(domainAgent.AgentConfig{
 		Providers:      cfg.Providers,
 		SchemaCacheTTL: cfg.Agent.SchemaCacheTTL,
-	})
+		QueryMaxRows:   cfg.Agent.QueryMaxRows,
+		QueryTimeout:   cfg.Agent.QueryTimeout,
+
+		SchemaConnectTimeout:   cfg.Agent.SchemaConnectTimeout,
+		SchemaStatementTimeout: cfg.Agent.SchemaStatementTimeout,
+		SchemaTimeout:          cfg.Agent.SchemaTimeout,
+	}, sessionService, connectionService, limiter, policy)
 
-	setupRoutes(router, agentService)
+	queryService := queryApp.NewService(infraQuery.Config{
+		MaxPageSize:      cfg.Query.MaxPageSize,
+		StatementTimeout: cfg.Agent.QueryTimeout,
+		CursorTTL:        cfg.Query.CursorTTL,
+		MaxUserCursors:   cfg.Query.MaxUserCursors,
+	}, connectionService, policy)
+
+	setupRoutes(router, authMiddleware(cfg, authService), middleware.RateLimit(limiter), agentService, queryService, connectionService, authService)
 
 	return &Server{
-		router:       router,
-		config:       cfg,
-		agentService: agentService,
+		router:            router,
+		config:            cfg,
+		agentService:      agentService,
+		queryService:      queryService,
+		connectionService: connectionService,
+		store:             store,
+	}, nil
+}
+
+// newSecretsCipher creates the cipher for stored credentials. Without a key,
+// in-memory storage gets a random one since nothing outlives the process,
+// persistent storage gets none and saving credentials is disabled.
+func newSecretsCipher(cfg config.SecretsConfig, storageDriver string) (*secrets.Cipher, error) {
+	keyring, err := secrets.LoadKeyring(cfg.Keys())
+	if err == nil {
+		logger.Info().Str("key_id", keyring.CurrentKeyID()).Msg("secrets key loaded")
+		return secrets.NewCipher(keyring), nil
+	}
+	if !errors.Is(err, secrets.ErrNotConfigured) {
+		return nil, fmt.Errorf("invalid secrets key: %w", err)
+	}
+
+	if storageDriver != storage.DriverMemory {
+		logger.Warn().Msg("SECRETS_KEY is not set, saved connections are disabled")
+		return nil, nil
+	}
+
+	generated, err := secrets.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	keyring, err = secrets.NewKeyring(generated)
+	if err != nil {
+		return nil, err
 	}
+	return secrets.NewCipher(keyring), nil
 }
 
-func setupRoutes(router *gin.Engine, agentService *agentApp.Service) {
+// newAuthService creates the service managing API keys and authenticating
+// the credentials accepted by the auth config
+func newAuthService(cfg config.AuthConfig, store *storage.Store) (*authApp.Service, error) {
+	if cfg.Has(config.AuthModeAPIKey) && store.Driver() == storage.DriverMemory {
+		return nil, fmt.Errorf("AUTH_MODE=%s requires persistent storage for API keys", config.AuthModeAPIKey)
+	}
+
+	apiKeyRepository, err := infraAuth.NewAPIKeyRepository(store.DB())
+	if err != nil {
+		return nil, err
+	}
+
+	authConfig := authApp.Config{APIKeys: cfg.Has(config.AuthModeAPIKey)}
+	if cfg.Has(config.AuthModeJWT) {
+		var scopes []domainAuth.Scope
+		for _, scope := range cfg.JWT.Scopes {
+			if !domainAuth.IsValidScope(domainAuth.Scope(scope)) {
+				return nil, fmt.Errorf("unknown scope %q in AUTH_JWT_SCOPES", scope)
+			}
+			scopes = append(scopes, domainAuth.Scope(scope))
+		}
+
+		// configuration loading only lets this through with AUTH_JWT_INSECURE_SKIP_ISSUER_AUDIENCE
+		if cfg.JWT.Issuer == "" || cfg.JWT.Audience == "" {
+			logger.Warn().Msg("AUTH_JWT_ISSUER or AUTH_JWT_AUDIENCE is not set, tokens issued for other applications are accepted")
+		}
+
+		authConfig.Tokens, err = infraAuth.NewTokenValidator(context.Background(), infraAuth.TokenConfig{
+			JWKSFile:      cfg.JWT.JWKSFile,
+			JWKSURL:       cfg.JWT.JWKSURL,
+			Issuer:        cfg.JWT.Issuer,
+			Audience:      cfg.JWT.Audience,
+			DefaultScopes: scopes,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure JWT validation: %w", err)
+		}
+	}
+
+	return authApp.NewService(apiKeyRepository, authConfig), nil
+}
+
+// newLimiter creates the chat rate limiter with in-memory or shared SQL state
+func newLimiter(cfg config.RateLimitConfig, store *storage.Store) (*ratelimit.Limiter, error) {
+	var limitStore ratelimit.Store
+	switch cfg.Store {
+	case "memory":
+		limitStore = ratelimit.NewMemoryStore()
+	case "sql":
+		sqlStore, err := ratelimit.NewSQLStore(store.DB())
+		if err != nil {
+			return nil, err
+		}
+		limitStore = sqlStore
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q, expected memory or sql", cfg.Store)
+	}
+
+	logger.Info().
+		Str("store", cfg.Store).
+		Int("chat_per_minute", cfg.ChatRequestsPerMinute).
+		Int64("daily_tokens", cfg.DailyTokens).
+		Msg("rate limits configured")
+
+	return ratelimit.NewLimiter(limitStore, ratelimit.Config{
+		RequestsPerMinute: cfg.ChatRequestsPerMinute,
+		Burst:             cfg.ChatBurst,
+		DailyTokens:       cfg.DailyTokens,
+	}), nil
+}
+
+// newConnectionPolicy creates the policy restricting the databases the server connects to
+func newConnectionPolicy(cfg config.ConnectionPolicyConfig) (*infraConnection.Policy, error) {
+	policy, err := infraConnection.NewPolicy(infraConnection.PolicyConfig{
+		AllowedHosts: cfg.AllowedHosts,
+		AllowedCIDRs: cfg.AllowedCIDRs,
+		DeniedCIDRs:  cfg.DeniedCIDRs,
+		AllowedPorts: cfg.AllowedPorts,
+		MinSSLMode:   cfg.MinSSLMode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection policy: %w", err)
+	}
+
+	logger.Info().
+		Strs("allowed_hosts", cfg.AllowedHosts).
+		Strs("allowed_cidrs", cfg.AllowedCIDRs).
+		Ints("allowed_ports", cfg.AllowedPorts).
+		Str("min_sslmode", cfg.MinSSLMode).
+		Msg("connection policy configured")
+
+	return policy, nil
+}
+
+// authMiddleware returns the middleware authenticating API requests
+func authMiddleware(cfg *config.Config, authService *authApp.Service) gin.HandlerFunc {
+	if !cfg.Auth.Enabled() {
+		if cfg.Server.Env == "production" {
+			logger.Warn().Msg("AUTH_MODE is none, the API is open to anyone who can reach it")
+		}
+		return middleware.Anonymous()
+	}
+
+	logger.Info().Strs("modes", cfg.Auth.Modes).Msg("API authentication enabled")
+	return middleware.Authenticate(authService)
+}
+
+func setupRoutes(router *gin.Engine, authenticate, rateLimit gin.HandlerFunc, agentService *agentApp.Service, queryService *queryApp.Service, connectionService *connectionApp.Service, authService *authApp.Service) {
 	agentHandler := handlers.NewAgentHandler(agentService)
+	queryHandler := handlers.NewQueryHandler(queryService)
+	sessionHandler := handlers.NewSessionHandler(agentService)
+	connectionHandler := handlers.NewConnectionHandler(connectionService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(authService)
 
 	v1 := router.Group("/v1")
 	{
 		v1.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "healthy"})
 		})
+	}
 
-		v1.GET("/models", agentHandler.GetModels)
+	chat := v1.Group("", authenticate, middleware.RequireScope(domainAuth.ScopeChat))
+	{
+		chat.GET("/models", agentHandler.GetModels)
 
-		agent := v1.Group("/agent")
+		agent := chat.Group("/agent", rateLimit)
 		{
 			agent.POST("/chat", agentHandler.Chat)
+			agent.POST("/chat/stream", agentHandler.ChatStream)
+		}
+
+		sessions := chat.Group("/sessions")
+		{
+			sessions.GET("", sessionHandler.List)
+			sessions.GET("/:id", sessionHandler.Get)
+			sessions.PATCH("/:id", sessionHandler.Rename)
+			sessions.DELETE("/:id", sessionHandler.Delete)
+		}
+
+		connections := chat.Group("/connections")
+		{
+			connections.POST("", connectionHandler.Create)
+			connections.GET("", connectionHandler.List)
+			connections.GET("/:id", connectionHandler.Get)
+			connections.DELETE("/:id", connectionHandler.Delete)
+		}
+
+	}
+
+	execute := v1.Group("", authenticate, middleware.RequireScope(domainAuth.ScopeExecute))
+	{
+		execute.POST("/query/execute", queryHandler.Execute)
+	}
+
+	admin := v1.Group("/admin", authenticate, middleware.RequireScope(domainAuth.ScopeAdmin))
+	{
+		apiKeys := admin.Group("/api-keys")
+		{
+			apiKeys.GET("", apiKeyHandler.List)
+			apiKeys.POST("", apiKeyHandler.Create)
+			apiKeys.DELETE("/:id", apiKeyHandler.Revoke)
 		}
 	}
 }
@@ -73,8 +334,20 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) Stop() error {
+	if s.queryService != nil {
+		if err := s.queryService.Close(); err != nil {
+			return err
+		}
+	}
 	if s.agentService != nil {
-		return s.agentService.Close()
+		if err := s.agentService.Close(); err != nil {
+			return err
+		}
+	}
+	if s.store != nil {
+		if err := s.store.Close(); err != nil {
+			return fmt.Errorf("failed to close storage: %w", err)
+		}
 	}
 	return nil
 }
@@ -12,16 +12,18 @@ import (
 var once sync.Once
 
 // Init initializes the global logger. Should be called once at application startup.
+// Every line is passed through Redact before it is written.
 func Init(isDevelopment bool) {
 	once.Do(func() {
+		out := redactWriter{out: os.Stdout}
 		if isDevelopment {
 			log.Logger = log.Output(zerolog.ConsoleWriter{
-				Out:        os.Stdout,
+				Out:        out,
 				TimeFormat: time.RFC3339,
 			})
 		} else {
 			zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-			log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
+			log.Logger = zerolog.New(out).With().Timestamp().Logger()
 		}
 	})
 }